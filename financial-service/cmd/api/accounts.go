@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"financial-service/data"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// setAccountStatus freezes or unfreezes a user's account
+func (app *Config) setAccountStatus(c *gin.Context) {
+	userID, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	var requestPayload struct {
+		Status data.AccountStatus `json:"Status"`
+		Reason string             `json:"Reason"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+
+	change, err := app.Repo.SetAccountStatus(c.Request.Context(), userID, requestPayload.Status, requestPayload.Reason)
+	if err != nil {
+		c.JSON(accountErrorStatus(err), gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't change the account status"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Account status changed",
+		"data":    change,
+	})
+}
+
+// closeAccount closes a user's account, sweeping any money left on it to SweepTo
+func (app *Config) closeAccount(c *gin.Context) {
+	userID, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	var requestPayload struct {
+		Reason  string `json:"Reason"`
+		SweepTo int    `json:"SweepTo"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+
+	change, err := app.Repo.CloseAccount(c.Request.Context(), &data.CloseAccountRequest{
+		UserID:  userID,
+		SweepTo: requestPayload.SweepTo,
+		Reason:  requestPayload.Reason,
+	})
+	if err != nil {
+		c.JSON(accountErrorStatus(err), gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't close the account"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Account closed",
+		"data":    change,
+	})
+}
+
+// accountErrorStatus maps an account lifecycle error to its HTTP status
+func accountErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, data.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrAccountClosed), errors.Is(err, data.ErrAccountNotClosable):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
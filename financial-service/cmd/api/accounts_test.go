@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"financial-service/data"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (m *MockRepository) SetAccountStatus(ctx context.Context, userID int, status data.AccountStatus, reason string) (*data.AccountStatusChange, error) {
+	args := m.Called(userID, status, reason)
+	if change, ok := args.Get(0).(*data.AccountStatusChange); ok {
+		return change, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) CloseAccount(ctx context.Context, req *data.CloseAccountRequest) (*data.AccountStatusChange, error) {
+	args := m.Called(req)
+	if change, ok := args.Get(0).(*data.AccountStatusChange); ok {
+		return change, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// TestSetAccountStatus_Freeze checks that the status and reason reach the repository
+func TestSetAccountStatus_Freeze(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	change := &data.AccountStatusChange{ID: 1, UserID: 3, From: data.AccountActive, To: data.AccountFrozenOut, Reason: "fraud review"}
+	mockRepo.On("SetAccountStatus", 3, data.AccountFrozenOut, "fraud review").Return(change, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Status": "frozen_out", "Reason": "fraud review"})
+
+	httpReq, _ := http.NewRequest("PUT", "/users/3/status", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Data *data.AccountStatusChange `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, data.AccountFrozenOut, response.Data.To)
+	assert.Equal(t, "fraud review", response.Data.Reason)
+}
+
+// TestSetAccountStatus_RequiresAdmin checks that only admins can freeze accounts
+func TestSetAccountStatus_RequiresAdmin(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	body, _ := json.Marshal(map[string]interface{}{"Status": "frozen_all", "Reason": "fraud review"})
+
+	httpReq, _ := http.NewRequest("PUT", "/users/3/status", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	mockRepo.AssertNotCalled(t, "SetAccountStatus", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestSetAccountStatus_MissingReason checks that a status change without a reason is refused
+func TestSetAccountStatus_MissingReason(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	mockRepo.On("SetAccountStatus", 3, data.AccountFrozenIn, "").
+		Return(nil, fmt.Errorf("%w: a reason is required", data.ErrInvalidStatusChange))
+
+	body, _ := json.Marshal(map[string]interface{}{"Status": "frozen_in"})
+
+	httpReq, _ := http.NewRequest("PUT", "/users/3/status", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "a reason is required")
+}
+
+// TestCloseAccount_Sweep checks that closing an account reports where its money went
+func TestCloseAccount_Sweep(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	req := &data.CloseAccountRequest{UserID: 3, SweepTo: 1, Reason: "customer left"}
+	change := &data.AccountStatusChange{ID: 2, UserID: 3, From: data.AccountFrozenAll, To: data.AccountClosed,
+		Reason: "customer left", SweptTo: intPtr(1), Sweeps: []int{40, 41}}
+	mockRepo.On("CloseAccount", req).Return(change, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Reason": "customer left", "SweepTo": 1})
+
+	httpReq, _ := http.NewRequest("POST", "/users/3/close", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Data *data.AccountStatusChange `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, data.AccountClosed, response.Data.To)
+	assert.Equal(t, []int{40, 41}, response.Data.Sweeps)
+	mockRepo.AssertCalled(t, "CloseAccount", req)
+}
+
+// TestCloseAccount_NotEmpty checks that an account with money left needs somewhere to sweep it
+func TestCloseAccount_NotEmpty(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	mockRepo.On("CloseAccount", mock.Anything).
+		Return(nil, fmt.Errorf("%w: money is left on the account and no account to sweep it to was given", data.ErrAccountNotClosable))
+
+	body, _ := json.Marshal(map[string]interface{}{"Reason": "customer left"})
+
+	httpReq, _ := http.NewRequest("POST", "/users/3/close", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "no account to sweep it to")
+}
+
+// TestDepositMoney_FrozenAccount checks that movements into frozen accounts are refused
+func TestDepositMoney_FrozenAccount(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("Deposit", &data.DepositRequest{UserID: 3, Amount: decimal.NewFromInt(10), Currency: data.DefaultCurrency}).
+		Return(0, fmt.Errorf("%w: user 3 is frozen_in and can't receive money", data.ErrAccountFrozen))
+
+	body, _ := json.Marshal(map[string]interface{}{"Id": 3, "Amount": "10"})
+
+	httpReq, _ := http.NewRequest("POST", "/depositMoney", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "can't receive money")
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"financial-service/data"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// userIDHeader carries the id of the user making a request. The service runs behind a gateway that
+// authenticates users and sets it; the header of the client itself never reaches the service
+const userIDHeader = "X-User-Id"
+
+// isAdmin reports whether the request carries the admin token as a bearer token. Without a configured
+// token nobody is an admin
+func (app *Config) isAdmin(c *gin.Context) bool {
+	if app.AdminToken == "" {
+		return false
+	}
+
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(app.AdminToken)) == 1
+}
+
+// requireAdmin rejects requests that don't carry the admin token
+func (app *Config) requireAdmin(c *gin.Context) {
+	if !app.isAdmin(c) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error":   true,
+			"message": "Admin authorization required",
+		})
+		return
+	}
+
+	c.Next()
+}
+
+// callerID returns the id of the user making the request, as set by the gateway
+func callerID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.GetHeader(userIDHeader))
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+
+	return id, true
+}
+
+// requireAccountHolder rejects requests for the resources of another user: the id path parameter must be
+// the caller's own id, unless the caller is an admin
+func (app *Config) requireAccountHolder(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if !app.authorizeAccountHolder(c, id) {
+		return
+	}
+
+	c.Next()
+}
+
+// requireWalletHolder rejects requests for the wallet of another user: the wallet in the id path parameter
+// must belong to the caller, unless the caller is an admin
+func (app *Config) requireWalletHolder(c *gin.Context) {
+	id, ok := pathID(c, "wallet")
+	if !ok {
+		c.Abort()
+		return
+	}
+
+	if !app.isAdmin(c) {
+		holder, ok := app.walletHolder(c, id)
+		if !ok || !app.authorizeAccountHolder(c, holder) {
+			return
+		}
+	}
+
+	c.Next()
+}
+
+// walletHolder returns the owner of a wallet, or 0 when there is no such wallet, so that only admins
+// learn whether it exists. When the wallet can't be looked up the request is aborted with 500
+func (app *Config) walletHolder(c *gin.Context, walletID int) (int, bool) {
+	wallet, err := app.Repo.GetWallet(c.Request.Context(), walletID)
+	if errors.Is(err, data.ErrWalletNotFound) {
+		return 0, true
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't fetch the wallet"),
+		})
+		return 0, false
+	}
+
+	return wallet.UserID, true
+}
+
+// authorizeAccountHolder reports whether the caller may act on the account of userID: admins may act on
+// any account and users only on their own. Otherwise the request is aborted with 401 or 403
+func (app *Config) authorizeAccountHolder(c *gin.Context, userID int) bool {
+	if app.isAdmin(c) {
+		return true
+	}
+
+	caller, ok := callerID(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error":   true,
+			"message": "Authorization required",
+		})
+		return false
+	}
+	if caller != userID {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":   true,
+			"message": "Access to another user's account is not allowed",
+		})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"financial-service/data"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// snapshotLag is how long after midnight the nightly snapshot waits, so that entries of the previous day
+// still being committed are included
+const snapshotLag = 10 * time.Minute
+
+// getBalance returns the current balance of a user in the currency query parameter. With asOf, an
+// RFC 3339 instant, it returns the balance the user had then instead, rebuilt from the ledger
+func (app *Config) getBalance(c *gin.Context) {
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	var balance any
+	var err error
+	if value := c.Query("asOf"); value != "" {
+		asOf, parseErr := time.Parse(time.RFC3339, value)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   true,
+				"message": "asOf must be an RFC 3339 timestamp",
+			})
+			return
+		}
+		balance, err = app.Repo.BalanceAsOf(c.Request.Context(), id, c.Query("currency"), asOf)
+	} else {
+		balance, err = app.Repo.GetBalance(c.Request.Context(), id, c.Query("currency"))
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, data.ErrUserNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't get the balance"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Balance found",
+		"data":    balance,
+	})
+}
+
+// getBalances returns the current balances of the users in the userIds query parameter, which may be
+// repeated or comma separated, in the currency query parameter. Users that don't exist are listed under
+// missing
+func (app *Config) getBalances(c *gin.Context) {
+	var ids []int
+	for _, value := range c.QueryArray("userIds") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+			id, err := strconv.Atoi(field)
+			if err != nil || id <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   true,
+					"message": "Invalid user id",
+				})
+				return
+			}
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 || len(ids) > data.MaxBalanceBatch {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": fmt.Sprintf("userIds must list between 1 and %d users", data.MaxBalanceBatch),
+		})
+		return
+	}
+
+	balances, err := app.Repo.GetBalances(c.Request.Context(), ids, c.Query("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't get the balances"),
+		})
+		return
+	}
+
+	found := make(map[int]bool, len(balances))
+	for _, balance := range balances {
+		found[balance.UserID] = true
+	}
+	missing := []int{}
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+			found[id] = true
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Balances found",
+		"data":    balances,
+		"missing": missing,
+	})
+}
+
+// snapshotBalances snapshots every account as of the last midnight, once per day. interval is how often
+// it checks whether a new day has started
+func (app *Config) snapshotBalances(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last time.Time
+	for now := range ticker.C {
+		lagged := now.Add(-snapshotLag)
+		asOf := time.Date(lagged.Year(), lagged.Month(), lagged.Day(), 0, 0, 0, 0, lagged.Location())
+		if asOf.Equal(last) {
+			continue
+		}
+
+		written, err := app.Repo.SnapshotBalances(context.Background(), asOf)
+		if err != nil {
+			log.Printf("Couldn't snapshot balances: %v\n", err)
+			continue
+		}
+		last = asOf
+		log.Printf("Snapshotted %d balances as of %s\n", written, asOf.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"financial-service/data"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (m *MockRepository) GetBalance(ctx context.Context, userID int, currency string) (*data.Balance, error) {
+	args := m.Called(userID, currency)
+	if balance, ok := args.Get(0).(*data.Balance); ok {
+		return balance, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) GetBalances(ctx context.Context, userIDs []int, currency string) ([]*data.Balance, error) {
+	args := m.Called(userIDs, currency)
+	if balances, ok := args.Get(0).([]*data.Balance); ok {
+		return balances, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) BalanceAsOf(ctx context.Context, userID int, currency string, asOf time.Time) (*data.HistoricalBalance, error) {
+	args := m.Called(userID, currency, asOf)
+	if balance, ok := args.Get(0).(*data.HistoricalBalance); ok {
+		return balance, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// TestGetBalance_AsOf checks that the asOf instant and currency reach the repository
+func TestGetBalance_AsOf(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	asOf := time.Date(2024, time.May, 31, 23, 59, 59, 0, time.UTC)
+	snapshotAsOf := time.Date(2024, time.May, 31, 0, 0, 0, 0, time.UTC)
+	balance := &data.HistoricalBalance{
+		UserID:         4,
+		Currency:       "USD",
+		AsOf:           asOf,
+		Balance:        decimal.RequireFromString("150.25"),
+		SnapshotAsOf:   &snapshotAsOf,
+		SnapshotAmount: decimal.NewFromInt(100),
+		EntriesApplied: 2,
+	}
+
+	mockRepo.On("BalanceAsOf", 4, "USD", asOf).Return(balance, nil)
+
+	httpReq, _ := http.NewRequest("GET", "/users/4/balance?currency=USD&asOf=2024-05-31T23:59:59Z", nil)
+	httpReq.Header.Set("X-User-Id", "4")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Error   bool                    `json:"error"`
+		Message string                  `json:"message"`
+		Data    *data.HistoricalBalance `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.True(t, response.Data.Balance.Equal(decimal.RequireFromString("150.25")))
+	assert.Equal(t, snapshotAsOf, *response.Data.SnapshotAsOf)
+	assert.Equal(t, 2, response.Data.EntriesApplied)
+
+	mockRepo.AssertCalled(t, "BalanceAsOf", 4, "USD", asOf)
+}
+
+// TestGetBalance_InvalidAsOf checks that timestamps that aren't RFC 3339 are rejected
+func TestGetBalance_InvalidAsOf(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	httpReq, _ := http.NewRequest("GET", "/users/4/balance?asOf=yesterday", nil)
+	httpReq.Header.Set("X-User-Id", "4")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	mockRepo.AssertNotCalled(t, "BalanceAsOf", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestGetBalance_UserNotFound checks that unknown users answer with 404
+func TestGetBalance_UserNotFound(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("GetBalance", 9, "").Return(nil, fmt.Errorf("user 9: %w", data.ErrUserNotFound))
+
+	httpReq, _ := http.NewRequest("GET", "/users/9/balance", nil)
+	httpReq.Header.Set("X-User-Id", "9")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.JSONEq(t, `{"error":true,"message":"User doesn't exist"}`, resp.Body.String())
+}
+
+// TestGetBalance_Live checks that without asOf the current balance is returned, holds included
+func TestGetBalance_Live(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	updatedAt := time.Date(2024, time.June, 3, 10, 0, 0, 0, time.UTC)
+	mockRepo.On("GetBalance", 4, "EUR").Return(&data.Balance{
+		UserID:    4,
+		Currency:  "EUR",
+		Ledger:    decimal.NewFromInt(100),
+		Held:      decimal.NewFromInt(30),
+		Available: decimal.NewFromInt(70),
+		UpdatedAt: updatedAt,
+	}, nil)
+
+	httpReq, _ := http.NewRequest("GET", "/users/4/balance?currency=EUR", nil)
+	httpReq.Header.Set("X-User-Id", "4")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Data *data.Balance `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+	assert.True(t, response.Data.Ledger.Equal(decimal.NewFromInt(100)))
+	assert.True(t, response.Data.Available.Equal(decimal.NewFromInt(70)))
+	assert.Equal(t, updatedAt, response.Data.UpdatedAt)
+
+	mockRepo.AssertNotCalled(t, "BalanceAsOf", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestGetBalance_Authorization checks that a balance is shown only to its holder and admins
+func TestGetBalance_Authorization(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	for caller, status := range map[string]int{"": http.StatusUnauthorized, "5": http.StatusForbidden} {
+		httpReq, _ := http.NewRequest("GET", "/users/4/balance", nil)
+		if caller != "" {
+			httpReq.Header.Set("X-User-Id", caller)
+		}
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, httpReq)
+
+		assert.Equal(t, status, resp.Code, caller)
+	}
+	mockRepo.AssertNotCalled(t, "GetBalance", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "BalanceAsOf", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestGetBalances checks that the batch endpoint reads every listed user and reports the missing ones
+func TestGetBalances(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	mockRepo.On("GetBalances", []int{1, 2, 3, 2}, "USD").Return([]*data.Balance{
+		{UserID: 1, Currency: "USD", Ledger: decimal.NewFromInt(10), Available: decimal.NewFromInt(10)},
+		{UserID: 3, Currency: "USD", Ledger: decimal.NewFromInt(5), Available: decimal.NewFromInt(5)},
+	}, nil)
+
+	httpReq, _ := http.NewRequest("GET", "/balances?userIds=1,2&userIds=3,2&currency=USD", nil)
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Data    []*data.Balance `json:"data"`
+		Missing []int           `json:"missing"`
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+	if assert.Len(t, response.Data, 2) {
+		assert.Equal(t, 1, response.Data[0].UserID)
+		assert.Equal(t, 3, response.Data[1].UserID)
+	}
+	assert.Equal(t, []int{2}, response.Missing)
+}
+
+// TestGetBalances_Invalid checks that the batch endpoint needs an admin and a short list of valid ids
+func TestGetBalances_Invalid(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	tooMany := make([]string, data.MaxBalanceBatch+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprint(i + 1)
+	}
+
+	tests := []struct {
+		query  string
+		admin  bool
+		status int
+	}{
+		{"userIds=1", false, http.StatusUnauthorized},
+		{"", true, http.StatusBadRequest},
+		{"userIds=1,abc", true, http.StatusBadRequest},
+		{"userIds=0", true, http.StatusBadRequest},
+		{"userIds=" + strings.Join(tooMany, ","), true, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		httpReq, _ := http.NewRequest("GET", "/balances?"+tt.query, nil)
+		if tt.admin {
+			httpReq.Header.Set("Authorization", "Bearer secret")
+		}
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, httpReq)
+
+		assert.Equal(t, tt.status, resp.Code, tt.query)
+	}
+	mockRepo.AssertNotCalled(t, "GetBalances", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"financial-service/data"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"net/http"
+)
+
+// quoteFee returns the fee a transfer or withdrawal would be charged, without executing it
+func (app *Config) quoteFee(c *gin.Context) {
+	var requestPayload struct {
+		Type     string          `json:"Type"`
+		Amount   decimal.Decimal `json:"Amount"`
+		Currency string          `json:"Currency"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+
+	quote, err := app.Repo.QuoteFee(c.Request.Context(), &data.FeeRequest{
+		Type:     data.TransactionType(requestPayload.Type),
+		Amount:   requestPayload.Amount,
+		Currency: requestPayload.Currency,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't compute the fee"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Fee computed",
+		"data":    quote,
+	})
+}
+
+// createFeeRule adds a fee rule
+func (app *Config) createFeeRule(c *gin.Context) {
+	var requestPayload data.FeeRule
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+
+	rule, err := app.Repo.CreateFeeRule(c.Request.Context(), &requestPayload)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't create the fee rule"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Fee rule created",
+		"data":    rule,
+	})
+}
+
+// listFeeRules returns the active fee rules
+func (app *Config) listFeeRules(c *gin.Context) {
+	rules, err := app.Repo.ListFeeRules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   true,
+			"message": "Couldn't get fee rules",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Fee rules found",
+		"data":    rules,
+	})
+}
+
+// deactivateFeeRule stops a fee rule from applying to new transactions
+func (app *Config) deactivateFeeRule(c *gin.Context) {
+	id, ok := pathID(c, "fee rule")
+	if !ok {
+		return
+	}
+
+	if err := app.Repo.DeactivateFeeRule(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't deactivate the fee rule"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Fee rule deactivated",
+	})
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"financial-service/data"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func (m *MockRepository) QuoteFee(ctx context.Context, req *data.FeeRequest) (*data.FeeQuote, error) {
+	args := m.Called(req)
+	if quote, ok := args.Get(0).(*data.FeeQuote); ok {
+		return quote, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) CreateFeeRule(ctx context.Context, r *data.FeeRule) (*data.FeeRule, error) {
+	args := m.Called(r)
+	if rule, ok := args.Get(0).(*data.FeeRule); ok {
+		return rule, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// TestQuoteFee_Success checks that the dry run returns the fee and the total debit
+func TestQuoteFee_Success(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	req := &data.FeeRequest{Type: data.TransactionTransfer, Amount: decimal.RequireFromString("1000"), Currency: "RUB"}
+	quote := &data.FeeQuote{
+		Type:     data.TransactionTransfer,
+		Amount:   decimal.NewFromInt(1000),
+		Currency: "RUB",
+		Fee:      decimal.NewFromInt(25),
+		Total:    decimal.NewFromInt(1025),
+		RuleID:   intPtr(3),
+	}
+
+	mockRepo.On("QuoteFee", req).Return(quote, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Type": "transfer", "Amount": "1000", "Currency": "RUB"})
+
+	httpReq, _ := http.NewRequest("POST", "/fees/quote", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Error   bool           `json:"error"`
+		Message string         `json:"message"`
+		Data    *data.FeeQuote `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, false, response.Error)
+	assert.True(t, response.Data.Fee.Equal(decimal.NewFromInt(25)))
+	assert.True(t, response.Data.Total.Equal(decimal.NewFromInt(1025)))
+	assert.Equal(t, 3, *response.Data.RuleID)
+
+	mockRepo.AssertCalled(t, "QuoteFee", req)
+}
+
+// TestCreateFeeRule_RequiresAdmin checks that only admins can configure fees
+func TestCreateFeeRule_RequiresAdmin(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	body, _ := json.Marshal(map[string]interface{}{"TransactionType": "transfer", "Flat": "10"})
+
+	httpReq, _ := http.NewRequest("POST", "/fees/rules", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	mockRepo.AssertNotCalled(t, "CreateFeeRule")
+}
+
+// TestCreateFeeRule_Success checks that an admin can add a rule
+func TestCreateFeeRule_Success(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	req := &data.FeeRule{TransactionType: data.TransactionWithdrawal, Flat: decimal.RequireFromString("10")}
+	created := *req
+	created.ID = 4
+	created.Active = true
+
+	mockRepo.On("CreateFeeRule", req).Return(&created, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"TransactionType": "withdrawal", "Flat": "10"})
+
+	httpReq, _ := http.NewRequest("POST", "/fees/rules", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockRepo.AssertCalled(t, "CreateFeeRule", req)
+}
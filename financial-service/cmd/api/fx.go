@@ -0,0 +1,94 @@
+package main
+
+import (
+	"financial-service/data"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"net/http"
+	"time"
+)
+
+const defaultFXQuoteTTL = time.Minute
+
+// defaultFXSpread is the share of the market rate the service keeps on conversions
+var defaultFXSpread = decimal.RequireFromString("0.005")
+
+// validFXSpread reports whether spread can be kept on conversions: a share of the rate from zero up to,
+// but not including, one
+func validFXSpread(spread decimal.Decimal) bool {
+	return !spread.IsNegative() && spread.LessThan(decimal.NewFromInt(1))
+}
+
+// setRates loads conversion rates into the rates table
+func (app *Config) setRates(c *gin.Context) {
+	var requestPayload []*data.FXRate
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil || len(requestPayload) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+
+	if err := app.Repo.SetRates(c.Request.Context(), requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't set rates"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Rates updated",
+		"data":    requestPayload,
+	})
+}
+
+// createQuote prices a currency conversion that a transfer can then execute against
+func (app *Config) createQuote(c *gin.Context) {
+	var requestPayload struct {
+		Amount decimal.Decimal `json:"Amount"`
+		From   string          `json:"From"`
+		To     string          `json:"To"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+
+	spread := defaultFXSpread
+	if app.FXSpread.Valid {
+		spread = app.FXSpread.Decimal
+	}
+	ttl := app.FXQuoteTTL
+	if ttl <= 0 {
+		ttl = defaultFXQuoteTTL
+	}
+
+	quote, err := app.Repo.CreateQuote(c.Request.Context(), &data.QuoteRequest{
+		From:   requestPayload.From,
+		To:     requestPayload.To,
+		Amount: requestPayload.Amount,
+		Spread: spread,
+		TTL:    ttl,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't create quote"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Quote created",
+		"data":    quote,
+	})
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"financial-service/data"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func (m *MockRepository) CreateQuote(ctx context.Context, req *data.QuoteRequest) (*data.FXQuote, error) {
+	args := m.Called(req)
+	if quote, ok := args.Get(0).(*data.FXQuote); ok {
+		return quote, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// TestCreateQuote_Success checks that a quote is priced with the configured spread and TTL
+func TestCreateQuote_Success(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, FXSpread: decimal.NewNullDecimal(decimal.RequireFromString("0.01")), FXQuoteTTL: 30 * time.Second}
+	app.routes(router)
+
+	req := &data.QuoteRequest{
+		From:   "EUR",
+		To:     "USD",
+		Amount: decimal.RequireFromString("100"),
+		Spread: decimal.RequireFromString("0.01"),
+		TTL:    30 * time.Second,
+	}
+	quote := &data.FXQuote{
+		ID:              "abc",
+		From:            "EUR",
+		To:              "USD",
+		Rate:            decimal.RequireFromString("1.1"),
+		Spread:          req.Spread,
+		AppliedRate:     decimal.RequireFromString("1.089"),
+		Amount:          req.Amount,
+		ConvertedAmount: decimal.RequireFromString("108.9"),
+	}
+
+	mockRepo.On("CreateQuote", req).Return(quote, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Amount": "100", "From": "EUR", "To": "USD"})
+
+	httpReq, _ := http.NewRequest("POST", "/fx/quotes", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Error bool          `json:"error"`
+		Data  *data.FXQuote `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, false, response.Error)
+	assert.Equal(t, "abc", response.Data.ID)
+	assert.True(t, response.Data.ConvertedAmount.Equal(quote.ConvertedAmount))
+
+	mockRepo.AssertCalled(t, "CreateQuote", req)
+}
+
+// TestCreateQuote_NoRate checks the error when there is no rate for the pair
+func TestCreateQuote_NoRate(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	req := &data.QuoteRequest{
+		From:   "EUR",
+		To:     "KZT",
+		Amount: decimal.RequireFromString("100"),
+		Spread: defaultFXSpread,
+		TTL:    defaultFXQuoteTTL,
+	}
+
+	mockRepo.On("CreateQuote", req).Return(nil, fmt.Errorf("%w: no rate for EUR/KZT", data.ErrConversionUnavailable))
+
+	body, _ := json.Marshal(map[string]interface{}{"Amount": "100", "From": "EUR", "To": "KZT"})
+
+	httpReq, _ := http.NewRequest("POST", "/fx/quotes", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, true, response["error"])
+	assert.Equal(t, "Currency conversion is not available", response["message"])
+}
+
+// TestTransferMoney_WithQuote checks that the quote id is passed through to the repository
+func TestTransferMoney_WithQuote(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	req := &data.TransferRequest{
+		From:                123,
+		To:                  456,
+		Amount:              decimal.RequireFromString("100"),
+		Currency:            "EUR",
+		DestinationCurrency: "USD",
+		QuoteID:             "abc",
+	}
+
+	mockRepo.On("Transfer", req).Return(0, fmt.Errorf("%w: abc expired", data.ErrInvalidQuote))
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"Amount":              "100",
+		"IdSource":            123,
+		"IdEndpoint":          456,
+		"Currency":            "EUR",
+		"DestinationCurrency": "USD",
+		"QuoteId":             "abc",
+	})
+
+	httpReq, _ := http.NewRequest("POST", "/transferMoney", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, "Quote doesn't exist, has expired, was already used or doesn't match the transfer", response["message"])
+
+	mockRepo.AssertCalled(t, "Transfer", req)
+}
+
+// TestSetRates_RequiresAdmin checks that loading rates needs the admin token
+func TestSetRates_RequiresAdmin(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	body, _ := json.Marshal([]map[string]interface{}{{"Base": "EUR", "Quote": "USD", "Rate": "1.08"}})
+
+	httpReq, _ := http.NewRequest("POST", "/fx/rates", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+// TestCreateQuote_ZeroSpread checks that a spread configured as zero isn't replaced by the default
+func TestCreateQuote_ZeroSpread(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, FXSpread: decimal.NewNullDecimal(decimal.Zero)}
+	app.routes(router)
+
+	req := &data.QuoteRequest{
+		From:   "EUR",
+		To:     "USD",
+		Amount: decimal.RequireFromString("100"),
+		Spread: decimal.Zero,
+		TTL:    defaultFXQuoteTTL,
+	}
+	mockRepo.On("CreateQuote", req).Return(&data.FXQuote{ID: "q1"}, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Amount": "100", "From": "EUR", "To": "USD"})
+
+	httpReq, _ := http.NewRequest("POST", "/fx/quotes", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockRepo.AssertCalled(t, "CreateQuote", req)
+}
+
+// TestFXSpreadFromEnv checks that an unset spread is told apart from zero and that out of range spreads
+// are refused
+func TestFXSpreadFromEnv(t *testing.T) {
+	t.Setenv("FX_SPREAD", "")
+	spread, err := decimalFromEnv("FX_SPREAD")
+	assert.NoError(t, err)
+	assert.False(t, spread.Valid)
+
+	t.Setenv("FX_SPREAD", "0")
+	spread, err = decimalFromEnv("FX_SPREAD")
+	assert.NoError(t, err)
+	assert.True(t, spread.Valid)
+	assert.True(t, spread.Decimal.IsZero())
+
+	t.Setenv("FX_SPREAD", "half")
+	_, err = decimalFromEnv("FX_SPREAD")
+	assert.Error(t, err)
+
+	assert.True(t, validFXSpread(decimal.Zero))
+	assert.True(t, validFXSpread(decimal.RequireFromString("0.005")))
+	assert.False(t, validFXSpread(decimal.RequireFromString("-0.01")))
+	assert.False(t, validFXSpread(decimal.NewFromInt(1)))
+}
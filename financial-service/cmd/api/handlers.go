@@ -1,25 +1,25 @@
 package main
 
 import (
+	"errors"
+	"financial-service/data"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/shopspring/decimal"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
-type Transactions struct {
-	ID             int             `json:"ID"`
-	UserIDSource   int             `json:"UserIDSource"`
-	UserIDEndpoint int             `json:"UserIDEndpoint"`
-	Amount         decimal.Decimal `json:"Amount"`
-	CreatedAt      time.Time       `json:"CreatedAt"`
-}
-
-// GetLastTransactions retrieves 10 last transactions for user from the database, sort them by points
+// GetLastTransactions retrieves 10 last transactions for user from the database, sort them by points.
+// With a WalletId only the transactions of that wallet are returned. Like the other history routes it
+// is open only to the account holder and admins; for a wallet the holder is its owner. Deprecated in
+// favour of getTransactionHistory, since proxies and browsers drop the body of a GET
 func (app *Config) GetLastTransactions(c *gin.Context) {
 	var requestPayload struct {
-		ID int `json:"Id"`
+		ID       int `json:"Id"`
+		WalletID int `json:"WalletId"`
 	}
 
 	if err := c.ShouldBindJSON(&requestPayload); err != nil {
@@ -30,11 +30,27 @@ func (app *Config) GetLastTransactions(c *gin.Context) {
 		return
 	}
 
-	transactions, err := app.Repo.GetLastTransactions(requestPayload.ID)
+	if !app.isAdmin(c) {
+		holder, ok := requestPayload.ID, true
+		if requestPayload.WalletID != 0 {
+			holder, ok = app.walletHolder(c, requestPayload.WalletID)
+		}
+		if !ok || !app.authorizeAccountHolder(c, holder) {
+			return
+		}
+	}
+
+	var transactions []*data.Transactions
+	var err error
+	if requestPayload.WalletID != 0 {
+		transactions, err = app.Repo.GetWalletTransactions(requestPayload.WalletID)
+	} else {
+		transactions, err = app.Repo.GetLastTransactions(requestPayload.ID)
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   true,
-			"message": "Couldn't fetch last 10 transactions ",
+			"message": errorMessage(err, "Couldn't fetch last 10 transactions "),
 		})
 		return
 	}
@@ -46,11 +62,188 @@ func (app *Config) GetLastTransactions(c *gin.Context) {
 	})
 }
 
-// depositMoney deposits money to the users balance
+// getTransactionHistory returns a page of a user's transactions, newest first. The limit query parameter
+// sets the page size and cursor continues from the next_cursor or prev_cursor of an earlier page. The
+// other parameters, read by historyFilter, narrow the history
+func (app *Config) getTransactionHistory(c *gin.Context) {
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(data.DefaultHistoryLimit)))
+	if err != nil || limit <= 0 || limit > data.MaxHistoryLimit {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": fmt.Sprintf("limit must be between 1 and %d", data.MaxHistoryLimit),
+		})
+		return
+	}
+
+	filter, err := historyFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	req := &data.HistoryRequest{
+		UserID: id,
+		Limit:  limit,
+		Filter: filter,
+	}
+	if value := c.Query("cursor"); value != "" {
+		if req.Cursor, err = data.ParseHistoryCursor(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   true,
+				"message": "Invalid cursor",
+			})
+			return
+		}
+	}
+
+	page, err := app.Repo.GetTransactionHistory(c.Request.Context(), req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, data.ErrInvalidFilter) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't fetch transactions"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":       false,
+		"message":     "Fetched transactions",
+		"data":        page.Transactions,
+		"next_cursor": optionalString(page.NextCursor),
+		"prev_cursor": optionalString(page.PrevCursor),
+	})
+}
+
+// getTransaction returns a single transaction with its status and linked transactions. Only the users
+// on either side of it and admins can read it; anyone else is told it doesn't exist
+func (app *Config) getTransaction(c *gin.Context) {
+	id, ok := pathID(c, "transaction")
+	if !ok {
+		return
+	}
+
+	admin := app.isAdmin(c)
+	caller, authenticated := callerID(c)
+	if !admin && !authenticated {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   true,
+			"message": "Authorization required",
+		})
+		return
+	}
+
+	record, err := app.Repo.GetTransaction(c.Request.Context(), id)
+	if err == nil && !admin && !isParty(record.Transactions, caller) {
+		err = fmt.Errorf("transaction %d: %w", id, data.ErrTransactionNotFound)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, data.ErrTransactionNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't fetch the transaction"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Fetched transaction",
+		"data":    record,
+	})
+}
+
+// isParty reports whether userID sent or received the transaction
+func isParty(t *data.Transactions, userID int) bool {
+	return (t.UserIDSource != nil && *t.UserIDSource == userID) || (t.UserIDEndpoint != nil && *t.UserIDEndpoint == userID)
+}
+
+// historyFilter reads the history filter from the query parameters: from and to as RFC 3339 timestamps,
+// minAmount and maxAmount, direction (incoming or outgoing), counterparty as a user id, type, which may
+// be repeated or comma separated, and reference
+func historyFilter(c *gin.Context) (data.HistoryFilter, error) {
+	filter := data.HistoryFilter{
+		Direction: data.HistoryDirection(c.Query("direction")),
+		Reference: c.Query("reference"),
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if value := c.Query(bound.name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 timestamp", bound.name)
+			}
+			*bound.target = &parsed
+		}
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target *decimal.NullDecimal
+	}{{"minAmount", &filter.MinAmount}, {"maxAmount", &filter.MaxAmount}} {
+		if value := c.Query(bound.name); value != "" {
+			parsed, err := decimal.NewFromString(value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be a number", bound.name)
+			}
+			*bound.target = decimal.NewNullDecimal(parsed)
+		}
+	}
+
+	if value := c.Query("counterparty"); value != "" {
+		counterparty, err := strconv.Atoi(value)
+		if err != nil || counterparty <= 0 {
+			return filter, errors.New("counterparty must be a user id")
+		}
+		filter.CounterpartyID = counterparty
+	}
+
+	for _, value := range c.QueryArray("type") {
+		for _, t := range strings.Split(value, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, data.TransactionType(t))
+			}
+		}
+	}
+
+	return filter, nil
+}
+
+// optionalString returns nil for an empty string, so that it is written as null
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// depositMoney deposits money to the users balance, or to one of their wallets when WalletId is given
 func (app *Config) depositMoney(c *gin.Context) {
 	var requestPayload struct {
-		Amount decimal.Decimal `json:"Amount"`
-		ID     int             `json:"Id"`
+		Amount      decimal.Decimal `json:"Amount"`
+		ID          int             `json:"Id"`
+		WalletID    int             `json:"WalletId"`
+		Currency    string          `json:"Currency"`
+		Description string          `json:"Description"`
+		Reference   string          `json:"Reference"`
+		Metadata    map[string]any  `json:"Metadata"`
 	}
 
 	if err := c.ShouldBindJSON(&requestPayload); err != nil {
@@ -61,36 +254,63 @@ func (app *Config) depositMoney(c *gin.Context) {
 		return
 	}
 
-	err := app.Repo.AddMoney(requestPayload.ID, requestPayload.Amount)
+	// A wallet without a currency is credited in its own currency
+	if requestPayload.WalletID == 0 || requestPayload.Currency != "" {
+		requestPayload.Currency = data.NormalizeCurrency(requestPayload.Currency)
+	}
+
+	response := gin.H{
+		"error":   false,
+		"message": fmt.Sprintf("Deposit money worked for user with id %d, added money %s", requestPayload.ID, requestPayload.Amount.String()),
+	}
+	if requestPayload.WalletID != 0 {
+		response["message"] = fmt.Sprintf("Deposit money worked for wallet with id %d, added money %s", requestPayload.WalletID, requestPayload.Amount.String())
+	}
 
+	ctx, err := app.idempotentContext(c, requestPayload, http.StatusOK, response)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   true,
-			"message": "Couldn't add money to the user",
+			"message": "Invalid Idempotency-Key",
 		})
 		return
 	}
-	err = app.Repo.AddTransaction(requestPayload.Amount, requestPayload.ID)
+
+	transactionID, err := app.Repo.Deposit(ctx, &data.DepositRequest{
+		UserID:   requestPayload.ID,
+		WalletID: requestPayload.WalletID,
+		Amount:   requestPayload.Amount,
+		Currency: requestPayload.Currency,
+		TransactionDetails: data.TransactionDetails{
+			Description: requestPayload.Description,
+			Reference:   requestPayload.Reference,
+			Metadata:    requestPayload.Metadata,
+		},
+	})
 	if err != nil {
+		if app.writeIdempotencyError(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   true,
-			"message": "Couldn't add transaction",
+			"message": errorMessage(err, "Couldn't add money to the user"),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"error":   false,
-		"message": fmt.Sprintf("Deposit money worked for user with id %d, added money %s", requestPayload.ID, requestPayload.Amount.String()),
-	})
+	created(c, response, transactionID)
 }
 
-// transferMoney transfers money from one user to another
-func (app *Config) transferMoney(c *gin.Context) {
+// withdrawMoney takes money out of the users balance, or out of one of their wallets when WalletId is given
+func (app *Config) withdrawMoney(c *gin.Context) {
 	var requestPayload struct {
-		Amount     decimal.Decimal `json:"Amount"`
-		IDSource   int             `json:"IdSource"`
-		IDEndpoint int             `json:"IdEndpoint"`
+		Amount      decimal.Decimal `json:"Amount"`
+		ID          int             `json:"Id"`
+		WalletID    int             `json:"WalletId"`
+		Currency    string          `json:"Currency"`
+		Description string          `json:"Description"`
+		Reference   string          `json:"Reference"`
+		Metadata    map[string]any  `json:"Metadata"`
 	}
 
 	if err := c.ShouldBindJSON(&requestPayload); err != nil {
@@ -100,35 +320,227 @@ func (app *Config) transferMoney(c *gin.Context) {
 		})
 		return
 	}
-	err := app.Repo.DecreaseMoney(requestPayload.IDSource, requestPayload.Amount)
+
+	// A wallet without a currency is debited in its own currency
+	if requestPayload.WalletID == 0 || requestPayload.Currency != "" {
+		requestPayload.Currency = data.NormalizeCurrency(requestPayload.Currency)
+	}
+
+	response := gin.H{
+		"error":   false,
+		"message": fmt.Sprintf("Withdraw money worked for user with id %d, withdrawn money %s", requestPayload.ID, requestPayload.Amount.String()),
+	}
+	if requestPayload.WalletID != 0 {
+		response["message"] = fmt.Sprintf("Withdraw money worked for wallet with id %d, withdrawn money %s", requestPayload.WalletID, requestPayload.Amount.String())
+	}
+
+	ctx, err := app.idempotentContext(c, requestPayload, http.StatusOK, response)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   true,
-			"message": "Couldn't decrease money from the source user",
+			"message": "Invalid Idempotency-Key",
 		})
 		return
 	}
 
-	err = app.Repo.AddMoney(requestPayload.IDEndpoint, requestPayload.Amount)
+	transactionID, err := app.Repo.Withdraw(ctx, &data.WithdrawalRequest{
+		UserID:   requestPayload.ID,
+		WalletID: requestPayload.WalletID,
+		Amount:   requestPayload.Amount,
+		Currency: requestPayload.Currency,
+		TransactionDetails: data.TransactionDetails{
+			Description: requestPayload.Description,
+			Reference:   requestPayload.Reference,
+			Metadata:    requestPayload.Metadata,
+		},
+	})
 	if err != nil {
+		if app.writeIdempotencyError(c, err) || writeLimitError(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   true,
-			"message": "Couldn't add money to the destination user",
+			"message": errorMessage(err, "Couldn't withdraw money from the user"),
 		})
 		return
 	}
 
-	err = app.Repo.AddTransaction(requestPayload.Amount, requestPayload.IDSource, requestPayload.IDEndpoint)
-	if err != nil {
+	created(c, response, transactionID)
+}
+
+// transferMoney transfers money from one user to another. FromWalletId and ToWalletId pick wallets other
+// than the users' defaults, which also moves money between the wallets of one user
+func (app *Config) transferMoney(c *gin.Context) {
+	var requestPayload struct {
+		Amount              decimal.Decimal `json:"Amount"`
+		IDSource            int             `json:"IdSource"`
+		IDEndpoint          int             `json:"IdEndpoint"`
+		FromWalletID        int             `json:"FromWalletId"`
+		ToWalletID          int             `json:"ToWalletId"`
+		Currency            string          `json:"Currency"`
+		DestinationCurrency string          `json:"DestinationCurrency"`
+		Convert             bool            `json:"Convert"`
+		QuoteID             string          `json:"QuoteId"`
+		Description         string          `json:"Description"`
+		Reference           string          `json:"Reference"`
+		Metadata            map[string]any  `json:"Metadata"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   true,
-			"message": "Couldn't add transaction",
+			"message": "Invalid JSON",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	// A source wallet without a currency is debited in its own currency
+	if requestPayload.FromWalletID == 0 || requestPayload.Currency != "" {
+		requestPayload.Currency = data.NormalizeCurrency(requestPayload.Currency)
+	}
+	if requestPayload.DestinationCurrency != "" {
+		requestPayload.DestinationCurrency = data.NormalizeCurrency(requestPayload.DestinationCurrency)
+	}
+
+	response := gin.H{
 		"error":   false,
 		"message": "Transfer money worked successfully",
+	}
+
+	ctx, err := app.idempotentContext(c, requestPayload, http.StatusOK, response)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid Idempotency-Key",
+		})
+		return
+	}
+
+	transactionID, err := app.Repo.Transfer(ctx, &data.TransferRequest{
+		From:                requestPayload.IDSource,
+		To:                  requestPayload.IDEndpoint,
+		FromWalletID:        requestPayload.FromWalletID,
+		ToWalletID:          requestPayload.ToWalletID,
+		Amount:              requestPayload.Amount,
+		Currency:            requestPayload.Currency,
+		DestinationCurrency: requestPayload.DestinationCurrency,
+		Convert:             requestPayload.Convert,
+		QuoteID:             requestPayload.QuoteID,
+		TransactionDetails: data.TransactionDetails{
+			Description: requestPayload.Description,
+			Reference:   requestPayload.Reference,
+			Metadata:    requestPayload.Metadata,
+		},
 	})
+	if err != nil {
+		if app.writeIdempotencyError(c, err) || writeLimitError(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't transfer money"),
+		})
+		return
+	}
+
+	created(c, response, transactionID)
+}
+
+// created answers a request that recorded a transaction with response, adding the transaction's id to it
+// and pointing Location at the transaction
+func created(c *gin.Context, response gin.H, transactionID int) {
+	response["transaction_id"] = transactionID
+	c.Header("Location", fmt.Sprintf("/v1/transactions/%d", transactionID))
+	c.JSON(http.StatusOK, response)
+}
+
+// errorMessage maps a repository error to a message that is safe to show to the client, falling back to
+// fallback for errors the client can't act on
+func errorMessage(err error, fallback string) string {
+	switch {
+	case errors.Is(err, data.ErrInsufficientBalance):
+		return "Insufficient balance on the source user"
+	case errors.Is(err, data.ErrUserNotFound):
+		return "User doesn't exist"
+	case errors.Is(err, data.ErrInvalidAmount):
+		return "Amount must be positive"
+	case errors.Is(err, data.ErrSameAccount):
+		return "Source and destination users must differ"
+	case errors.Is(err, data.ErrUnsupportedCurrency):
+		return "Unsupported currency"
+	case errors.Is(err, data.ErrInvalidScale):
+		return "Amount has too many decimal places for its currency"
+	case errors.Is(err, data.ErrCurrencyMismatch):
+		return "Source and destination currencies differ, request a conversion to transfer between them"
+	case errors.Is(err, data.ErrConversionUnavailable):
+		return "Currency conversion is not available"
+	case errors.Is(err, data.ErrQuoteRequired):
+		return "Currency conversion requires a quote"
+	case errors.Is(err, data.ErrInvalidQuote):
+		return "Quote doesn't exist, has expired, was already used or doesn't match the transfer"
+	case errors.Is(err, data.ErrTransactionNotFound):
+		return "Transaction doesn't exist"
+	case errors.Is(err, data.ErrNotRefundable):
+		return "Transaction can't be refunded"
+	case errors.Is(err, data.ErrRefundExceedsOriginal):
+		return "Refund exceeds the amount left on the transaction"
+	case errors.Is(err, data.ErrHoldNotFound):
+		return "Hold doesn't exist"
+	case errors.Is(err, data.ErrHoldNotActive):
+		return "Hold was already captured, voided or has expired"
+	case errors.Is(err, data.ErrCaptureExceedsHold):
+		return "Capture exceeds the held amount"
+	case errors.Is(err, data.ErrInvalidSchedule):
+		return err.Error()
+	case errors.Is(err, data.ErrScheduleNotFound):
+		return "Schedule doesn't exist"
+	case errors.Is(err, data.ErrScheduleNotActive):
+		return "Schedule was already completed or cancelled"
+	case errors.Is(err, data.ErrUnsupportedTransactionType):
+		return "Unsupported transaction type"
+	case errors.Is(err, data.ErrInvalidFeeRule):
+		return err.Error()
+	case errors.Is(err, data.ErrFeeRuleNotFound):
+		return "Fee rule doesn't exist"
+	case errors.Is(err, data.ErrLimitExceeded):
+		return "Spending limit exceeded"
+	case errors.Is(err, data.ErrInvalidPeriod):
+		return "Period must be a month in yyyy-mm format that has already started"
+	case errors.Is(err, data.ErrUnsupportedFormat):
+		return "Format must be json, csv or pdf"
+	case errors.Is(err, data.ErrInvalidPayoutBatch):
+		return err.Error()
+	case errors.Is(err, data.ErrPayoutBatchNotFound):
+		return "Payout batch doesn't exist"
+	case errors.Is(err, data.ErrInvalidWallet):
+		return err.Error()
+	case errors.Is(err, data.ErrWalletNameTaken):
+		return "A wallet with this name already exists in this currency"
+	case errors.Is(err, data.ErrWalletNotFound):
+		return "Wallet doesn't exist"
+	case errors.Is(err, data.ErrWalletClosed):
+		return "Wallet is closed"
+	case errors.Is(err, data.ErrWalletNotClosable):
+		return "Only empty wallets other than the default one can be closed"
+	case errors.Is(err, data.ErrAccountFrozen):
+		return err.Error()
+	case errors.Is(err, data.ErrAccountClosed):
+		return "Account is closed"
+	case errors.Is(err, data.ErrInvalidStatusChange):
+		return err.Error()
+	case errors.Is(err, data.ErrAccountNotClosable):
+		return err.Error()
+	case errors.Is(err, data.ErrInvalidUser):
+		return err.Error()
+	case errors.Is(err, data.ErrEmailTaken):
+		return "Another user already has this email"
+	case errors.Is(err, data.ErrUserNotDeletable):
+		return err.Error()
+	case errors.Is(err, data.ErrInvalidDetails):
+		return err.Error()
+	case errors.Is(err, data.ErrInvalidFilter):
+		return err.Error()
+	default:
+		return fallback
+	}
 }
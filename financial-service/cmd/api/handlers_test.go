@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"financial-service/data"
@@ -9,6 +10,7 @@ import (
 	"github.com/shopspring/decimal"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -22,9 +24,9 @@ type MockRepository struct {
 	data.PostgresTestRepository
 }
 
-func (m *MockRepository) GetLastTransactions(id int) ([]*Transactions, error) {
+func (m *MockRepository) GetLastTransactions(id int) ([]*data.Transactions, error) {
 	args := m.Called(id)
-	return args.Get(0).([]*Transactions), args.Error(1)
+	return args.Get(0).([]*data.Transactions), args.Error(1)
 }
 
 func (m *MockRepository) AddMoney(id int, amount decimal.Decimal) error {
@@ -32,16 +34,35 @@ func (m *MockRepository) AddMoney(id int, amount decimal.Decimal) error {
 	return args.Error(0)
 }
 
-func (m *MockRepository) AddTransaction(amount decimal.Decimal, id ...int) error {
-	args := m.Called(amount, id)
+func (m *MockRepository) AddTransaction(ctx context.Context, t *data.Transactions) error {
+	args := m.Called(t)
 	return args.Error(0)
 }
 
+func (m *MockRepository) Withdraw(ctx context.Context, req *data.WithdrawalRequest) (int, error) {
+	args := m.Called(req)
+	return args.Int(0), args.Error(1)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
 func (m *MockRepository) DecreaseMoney(id int, amount decimal.Decimal) error {
 	args := m.Called(id, amount)
 	return args.Error(0)
 }
 
+func (m *MockRepository) Deposit(ctx context.Context, req *data.DepositRequest) (int, error) {
+	args := m.Called(req)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) Transfer(ctx context.Context, req *data.TransferRequest) (int, error) {
+	args := m.Called(req)
+	return args.Int(0), args.Error(1)
+}
+
 // TestGetLastTransactions_InvalidJSON checks invalid JSON
 func TestGetLastTransactions_InvalidJSON(t *testing.T) {
 	router := gin.Default()
@@ -73,9 +94,11 @@ func TestGetLastTransactions_Success(t *testing.T) {
 	app := &Config{Repo: mockRepo}
 	app.routes(router)
 
-	transactions := []*Transactions{
-		{ID: 1, UserIDSource: 123, UserIDEndpoint: 456, Amount: decimal.NewFromFloat(100.50), CreatedAt: time.Now()},
-		{ID: 2, UserIDSource: 123, UserIDEndpoint: 789, Amount: decimal.NewFromFloat(200.75), CreatedAt: time.Now()},
+	createdAt := time.Date(2024, time.December, 1, 12, 0, 0, 0, time.UTC)
+	transactions := []*data.Transactions{
+		{ID: 1, Type: data.TransactionTransfer, UserIDSource: intPtr(123), UserIDEndpoint: intPtr(456), Amount: decimal.NewFromFloat(100.50), Currency: "RUB", CreatedAt: createdAt},
+		{ID: 2, Type: data.TransactionTransfer, UserIDSource: intPtr(123), UserIDEndpoint: intPtr(789), Amount: decimal.NewFromFloat(200.75), Currency: "RUB", CreatedAt: createdAt},
+		{ID: 3, Type: data.TransactionDeposit, UserIDEndpoint: intPtr(123), Amount: decimal.NewFromInt(50), Currency: "RUB", CreatedAt: createdAt},
 	}
 
 	mockRepo.On("GetLastTransactions", 123).Return(transactions, nil)
@@ -87,18 +110,23 @@ func TestGetLastTransactions_Success(t *testing.T) {
 
 	req, _ := http.NewRequest("GET", "/getLastTransactions", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", "123")
 	resp := httptest.NewRecorder()
 
 	router.ServeHTTP(resp, req)
 
 	assert.Equal(t, http.StatusOK, resp.Code)
 
-	var response map[string]interface{}
+	var response struct {
+		Error   bool                 `json:"error"`
+		Message string               `json:"message"`
+		Data    []*data.Transactions `json:"data"`
+	}
 	err := json.Unmarshal(resp.Body.Bytes(), &response)
 	assert.Nil(t, err)
-	assert.Equal(t, false, response["error"])
-	assert.Equal(t, "Fetched all transactions", response["message"])
-	assert.Equal(t, transactions, response["data"])
+	assert.Equal(t, false, response.Error)
+	assert.Equal(t, "Fetched all transactions", response.Message)
+	assert.Equal(t, transactions, response.Data)
 
 	mockRepo.AssertCalled(t, "GetLastTransactions", 123)
 }
@@ -111,7 +139,7 @@ func TestGetLastTransactions_Error(t *testing.T) {
 	app := &Config{Repo: mockRepo}
 	app.routes(router)
 
-	mockRepo.On("GetLastTransactions", 123).Return([]*Transactions{}, errors.New("database error"))
+	mockRepo.On("GetLastTransactions", 123).Return([]*data.Transactions{}, errors.New("database error"))
 
 	payload := map[string]interface{}{
 		"Id": 123,
@@ -120,6 +148,7 @@ func TestGetLastTransactions_Error(t *testing.T) {
 
 	req, _ := http.NewRequest("GET", "/getLastTransactions", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", "123")
 	resp := httptest.NewRecorder()
 
 	router.ServeHTTP(resp, req)
@@ -169,9 +198,7 @@ func TestDepositMoney_Success(t *testing.T) {
 	amount := decimal.NewFromFloat(100.50)
 	id := 123
 
-	mockRepo.On("AddMoney", id, amount).Return(nil)
-
-	mockRepo.On("AddTransaction", amount, []int{id}).Return(nil)
+	mockRepo.On("Deposit", &data.DepositRequest{UserID: id, Amount: amount, Currency: data.DefaultCurrency}).Return(1, nil)
 
 	payload := map[string]interface{}{
 		"Amount": amount.String(),
@@ -193,12 +220,12 @@ func TestDepositMoney_Success(t *testing.T) {
 	assert.Equal(t, false, response["error"])
 	assert.Equal(t, fmt.Sprintf("Deposit money worked for user with id %d, added money %s", id, amount.String()), response["message"])
 
-	mockRepo.AssertCalled(t, "AddMoney", id, amount)
-	mockRepo.AssertCalled(t, "AddTransaction", amount, []int{id})
+	mockRepo.AssertCalled(t, "Deposit", &data.DepositRequest{UserID: id, Amount: amount, Currency: data.DefaultCurrency})
+	mockRepo.AssertNotCalled(t, "AddMoney", mock.Anything, mock.Anything)
 }
 
-// TestDepositMoney_AddMoneyError check error while depositing
-func TestDepositMoney_AddMoneyError(t *testing.T) {
+// TestDepositMoney_Error check error while depositing
+func TestDepositMoney_Error(t *testing.T) {
 	router := gin.Default()
 
 	mockRepo := new(MockRepository)
@@ -208,7 +235,7 @@ func TestDepositMoney_AddMoneyError(t *testing.T) {
 	amount := decimal.NewFromFloat(100.50)
 	id := 123
 
-	mockRepo.On("AddMoney", id, amount).Return(errors.New("database error"))
+	mockRepo.On("Deposit", &data.DepositRequest{UserID: id, Amount: amount, Currency: data.DefaultCurrency}).Return(0, errors.New("database error"))
 
 	payload := map[string]interface{}{
 		"Amount": amount.String(),
@@ -230,11 +257,11 @@ func TestDepositMoney_AddMoneyError(t *testing.T) {
 	assert.Equal(t, true, response["error"])
 	assert.Equal(t, "Couldn't add money to the user", response["message"])
 
-	mockRepo.AssertCalled(t, "AddMoney", id, amount)
+	mockRepo.AssertCalled(t, "Deposit", &data.DepositRequest{UserID: id, Amount: amount, Currency: data.DefaultCurrency})
 }
 
-// TestDepositMoney_AddTransactionError error handling during depositing
-func TestDepositMoney_AddTransactionError(t *testing.T) {
+// TestDepositMoney_UserNotFound error handling when depositing to an unknown user
+func TestDepositMoney_UserNotFound(t *testing.T) {
 	router := gin.Default()
 
 	mockRepo := new(MockRepository)
@@ -244,9 +271,7 @@ func TestDepositMoney_AddTransactionError(t *testing.T) {
 	amount := decimal.NewFromFloat(100.50)
 	id := 123
 
-	mockRepo.On("AddMoney", id, amount).Return(nil)
-
-	mockRepo.On("AddTransaction", amount, []int{id}).Return(errors.New("database error"))
+	mockRepo.On("Deposit", &data.DepositRequest{UserID: id, Amount: amount, Currency: data.DefaultCurrency}).Return(0, fmt.Errorf("user %d: %w", id, data.ErrUserNotFound))
 
 	payload := map[string]interface{}{
 		"Amount": amount.String(),
@@ -266,10 +291,9 @@ func TestDepositMoney_AddTransactionError(t *testing.T) {
 	err := json.Unmarshal(resp.Body.Bytes(), &response)
 	assert.Nil(t, err)
 	assert.Equal(t, true, response["error"])
-	assert.Equal(t, "Couldn't add transaction", response["message"])
+	assert.Equal(t, "User doesn't exist", response["message"])
 
-	mockRepo.AssertCalled(t, "AddMoney", id, amount)
-	mockRepo.AssertCalled(t, "AddTransaction", amount, []int{id})
+	mockRepo.AssertCalled(t, "Deposit", &data.DepositRequest{UserID: id, Amount: amount, Currency: data.DefaultCurrency})
 }
 
 // TestTransferMoney_InvalidJSON checks invalid JSON
@@ -307,11 +331,7 @@ func TestTransferMoney_Success(t *testing.T) {
 	idSource := 123
 	idEndpoint := 456
 
-	mockRepo.On("DecreaseMoney", idSource, amount).Return(nil)
-
-	mockRepo.On("AddMoney", idEndpoint, amount).Return(nil)
-
-	mockRepo.On("AddTransaction", amount, []int{idSource, idEndpoint}).Return(nil)
+	mockRepo.On("Transfer", &data.TransferRequest{From: idSource, To: idEndpoint, Amount: amount, Currency: data.DefaultCurrency}).Return(1, nil)
 
 	payload := map[string]interface{}{
 		"Amount":     amount.String(),
@@ -334,13 +354,13 @@ func TestTransferMoney_Success(t *testing.T) {
 	assert.Equal(t, false, response["error"])
 	assert.Equal(t, "Transfer money worked successfully", response["message"])
 
-	mockRepo.AssertCalled(t, "DecreaseMoney", idSource, amount)
-	mockRepo.AssertCalled(t, "AddMoney", idEndpoint, amount)
-	mockRepo.AssertCalled(t, "AddTransaction", amount, []int{idSource, idEndpoint})
+	mockRepo.AssertCalled(t, "Transfer", &data.TransferRequest{From: idSource, To: idEndpoint, Amount: amount, Currency: data.DefaultCurrency})
+	mockRepo.AssertNotCalled(t, "DecreaseMoney", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "AddMoney", mock.Anything, mock.Anything)
 }
 
-// TestTransferMoney_DecreaseMoneyError error handling during decreasing money
-func TestTransferMoney_DecreaseMoneyError(t *testing.T) {
+// TestTransferMoney_InsufficientBalance error handling when the source can't cover the transfer
+func TestTransferMoney_InsufficientBalance(t *testing.T) {
 	router := gin.Default()
 
 	mockRepo := new(MockRepository)
@@ -351,7 +371,7 @@ func TestTransferMoney_DecreaseMoneyError(t *testing.T) {
 	idSource := 123
 	idEndpoint := 456
 
-	mockRepo.On("DecreaseMoney", idSource, amount).Return(errors.New("database error"))
+	mockRepo.On("Transfer", &data.TransferRequest{From: idSource, To: idEndpoint, Amount: amount, Currency: data.DefaultCurrency}).Return(0, fmt.Errorf("%w: cannot transfer", data.ErrInsufficientBalance))
 
 	payload := map[string]interface{}{
 		"Amount":     amount.String(),
@@ -372,13 +392,13 @@ func TestTransferMoney_DecreaseMoneyError(t *testing.T) {
 	err := json.Unmarshal(resp.Body.Bytes(), &response)
 	assert.Nil(t, err)
 	assert.Equal(t, true, response["error"])
-	assert.Equal(t, "Couldn't decrease money from the source user", response["message"])
+	assert.Equal(t, "Insufficient balance on the source user", response["message"])
 
-	mockRepo.AssertCalled(t, "DecreaseMoney", idSource, amount)
+	mockRepo.AssertCalled(t, "Transfer", &data.TransferRequest{From: idSource, To: idEndpoint, Amount: amount, Currency: data.DefaultCurrency})
 }
 
-// TestTransferMoney_AddMoneyError error handling during adding money to balance
-func TestTransferMoney_AddMoneyError(t *testing.T) {
+// TestTransferMoney_Error error handling when the transfer fails
+func TestTransferMoney_Error(t *testing.T) {
 	router := gin.Default()
 
 	mockRepo := new(MockRepository)
@@ -389,9 +409,7 @@ func TestTransferMoney_AddMoneyError(t *testing.T) {
 	idSource := 123
 	idEndpoint := 456
 
-	mockRepo.On("DecreaseMoney", idSource, amount).Return(nil)
-
-	mockRepo.On("AddMoney", idEndpoint, amount).Return(errors.New("database error"))
+	mockRepo.On("Transfer", &data.TransferRequest{From: idSource, To: idEndpoint, Amount: amount, Currency: data.DefaultCurrency}).Return(0, errors.New("database error"))
 
 	payload := map[string]interface{}{
 		"Amount":     amount.String(),
@@ -412,14 +430,13 @@ func TestTransferMoney_AddMoneyError(t *testing.T) {
 	err := json.Unmarshal(resp.Body.Bytes(), &response)
 	assert.Nil(t, err)
 	assert.Equal(t, true, response["error"])
-	assert.Equal(t, "Couldn't add money to the destination user", response["message"])
+	assert.Equal(t, "Couldn't transfer money", response["message"])
 
-	mockRepo.AssertCalled(t, "DecreaseMoney", idSource, amount)
-	mockRepo.AssertCalled(t, "AddMoney", idEndpoint, amount)
+	mockRepo.AssertCalled(t, "Transfer", &data.TransferRequest{From: idSource, To: idEndpoint, Amount: amount, Currency: data.DefaultCurrency})
 }
 
-// TestTransferMoney_AddTransactionError error handling during adding transaction
-func TestTransferMoney_AddTransactionError(t *testing.T) {
+// TestDepositMoney_IdempotentReplay checks that a retried deposit gets the stored response back
+func TestDepositMoney_IdempotentReplay(t *testing.T) {
 	router := gin.Default()
 
 	mockRepo := new(MockRepository)
@@ -427,14 +444,44 @@ func TestTransferMoney_AddTransactionError(t *testing.T) {
 	app.routes(router)
 
 	amount := decimal.NewFromFloat(100.50)
-	idSource := 123
-	idEndpoint := 456
+	id := 123
+	stored := []byte(`{"error":false,"message":"Deposit money worked for user with id 123, added money 100.5"}`)
 
-	mockRepo.On("DecreaseMoney", idSource, amount).Return(nil)
+	mockRepo.On("Deposit", &data.DepositRequest{UserID: id, Amount: amount, Currency: data.DefaultCurrency}).Return(0, &data.ReplayError{StatusCode: http.StatusOK, Response: stored})
 
-	mockRepo.On("AddMoney", idEndpoint, amount).Return(nil)
+	payload := map[string]interface{}{
+		"Amount": amount.String(),
+		"Id":     id,
+	}
+	body, _ := json.Marshal(payload)
 
-	mockRepo.On("AddTransaction", amount, []int{idSource, idEndpoint}).Return(errors.New("database error"))
+	req, _ := http.NewRequest("POST", "/depositMoney", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "deposit-1")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "true", resp.Header().Get("Idempotent-Replayed"))
+	assert.JSONEq(t, string(stored), resp.Body.String())
+
+	mockRepo.AssertCalled(t, "Deposit", &data.DepositRequest{UserID: id, Amount: amount, Currency: data.DefaultCurrency})
+}
+
+// TestTransferMoney_IdempotencyConflict checks that a key reused with a different body is rejected
+func TestTransferMoney_IdempotencyConflict(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	amount := decimal.NewFromFloat(100.50)
+	idSource := 123
+	idEndpoint := 456
+
+	mockRepo.On("Transfer", &data.TransferRequest{From: idSource, To: idEndpoint, Amount: amount, Currency: data.DefaultCurrency}).Return(0, data.ErrIdempotencyConflict)
 
 	payload := map[string]interface{}{
 		"Amount":     amount.String(),
@@ -445,19 +492,705 @@ func TestTransferMoney_AddTransactionError(t *testing.T) {
 
 	req, _ := http.NewRequest("POST", "/transferMoney", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "transfer-1")
 	resp := httptest.NewRecorder()
 
 	router.ServeHTTP(resp, req)
 
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, true, response["error"])
+	assert.Equal(t, "Idempotency key was already used with a different request", response["message"])
+}
+
+// TestTransferMoney_InvalidIdempotencyKey checks that oversized keys are rejected before touching the repository
+func TestTransferMoney_InvalidIdempotencyKey(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	payload := map[string]interface{}{
+		"Amount":     "100.50",
+		"IdSource":   123,
+		"IdEndpoint": 456,
+	}
+	body, _ := json.Marshal(payload)
+
+	req, _ := http.NewRequest("POST", "/transferMoney", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", strings.Repeat("k", 256))
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	mockRepo.AssertNotCalled(t, "Transfer", mock.Anything)
+}
+
+// TestTransferMoney_CurrencyMismatch checks that cross-currency transfers without a conversion are rejected
+func TestTransferMoney_CurrencyMismatch(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	req := &data.TransferRequest{
+		From:                123,
+		To:                  456,
+		Amount:              decimal.NewFromFloat(10.25),
+		Currency:            "EUR",
+		DestinationCurrency: "USD",
+	}
+
+	mockRepo.On("Transfer", req).Return(0, fmt.Errorf("%w: EUR to USD", data.ErrCurrencyMismatch))
+
+	payload := map[string]interface{}{
+		"Amount":              req.Amount.String(),
+		"IdSource":            req.From,
+		"IdEndpoint":          req.To,
+		"Currency":            "eur",
+		"DestinationCurrency": "usd",
+	}
+	body, _ := json.Marshal(payload)
+
+	httpReq, _ := http.NewRequest("POST", "/transferMoney", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, true, response["error"])
+	assert.Equal(t, "Source and destination currencies differ, request a conversion to transfer between them", response["message"])
+
+	mockRepo.AssertCalled(t, "Transfer", req)
+}
+
+// TestDepositMoney_InvalidScale checks that amounts finer than the currency allows are rejected
+func TestDepositMoney_InvalidScale(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	req := &data.DepositRequest{UserID: 123, Amount: decimal.RequireFromString("100.5"), Currency: "JPY"}
+
+	mockRepo.On("Deposit", req).Return(0, fmt.Errorf("%w: JPY allows 0 decimal places", data.ErrInvalidScale))
+
+	payload := map[string]interface{}{
+		"Amount":   "100.5",
+		"Id":       123,
+		"Currency": "JPY",
+	}
+	body, _ := json.Marshal(payload)
+
+	httpReq, _ := http.NewRequest("POST", "/depositMoney", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, "Amount has too many decimal places for its currency", response["message"])
+}
+
+// TestWithdrawMoney_Success check success withdrawal
+func TestWithdrawMoney_Success(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	amount := decimal.NewFromFloat(40.25)
+	id := 123
+	req := &data.WithdrawalRequest{UserID: id, Amount: amount, Currency: "USD"}
+
+	mockRepo.On("Withdraw", req).Return(1, nil)
+
+	payload := map[string]interface{}{
+		"Amount":   amount.String(),
+		"Id":       id,
+		"Currency": "usd",
+	}
+	body, _ := json.Marshal(payload)
+
+	httpReq, _ := http.NewRequest("POST", "/withdrawMoney", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, false, response["error"])
+	assert.Equal(t, fmt.Sprintf("Withdraw money worked for user with id %d, withdrawn money %s", id, amount.String()), response["message"])
+
+	mockRepo.AssertCalled(t, "Withdraw", req)
+}
+
+// TestWithdrawMoney_InsufficientBalance error handling when the user can't cover the withdrawal
+func TestWithdrawMoney_InsufficientBalance(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	amount := decimal.NewFromFloat(40.25)
+	id := 123
+	req := &data.WithdrawalRequest{UserID: id, Amount: amount, Currency: data.DefaultCurrency}
+
+	mockRepo.On("Withdraw", req).Return(0, fmt.Errorf("%w: cannot withdraw", data.ErrInsufficientBalance))
+
+	payload := map[string]interface{}{
+		"Amount": amount.String(),
+		"Id":     id,
+	}
+	body, _ := json.Marshal(payload)
+
+	httpReq, _ := http.NewRequest("POST", "/withdrawMoney", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
 	assert.Equal(t, http.StatusBadRequest, resp.Code)
 
 	var response map[string]interface{}
 	err := json.Unmarshal(resp.Body.Bytes(), &response)
 	assert.Nil(t, err)
 	assert.Equal(t, true, response["error"])
-	assert.Equal(t, "Couldn't add transaction", response["message"])
+	assert.Equal(t, "Insufficient balance on the source user", response["message"])
+}
+
+// TestWithdrawMoney_InvalidJSON checks invalid JSON
+func TestWithdrawMoney_InvalidJSON(t *testing.T) {
+	router := gin.Default()
+	app := &Config{}
+	app.routes(router)
+
+	req, _ := http.NewRequest("POST", "/withdrawMoney", bytes.NewBufferString(`{"Amount": "invalid", "Id": 123}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, "Invalid JSON", response["message"])
+}
+
+func (m *MockRepository) GetTransactionHistory(ctx context.Context, req *data.HistoryRequest) (*data.TransactionPage, error) {
+	args := m.Called(req)
+	if page, ok := args.Get(0).(*data.TransactionPage); ok {
+		return page, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// TestTransferMoney_Details checks that the description, reference and metadata reach the repository
+func TestTransferMoney_Details(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	req := &data.TransferRequest{
+		From:     1,
+		To:       2,
+		Amount:   decimal.NewFromInt(100),
+		Currency: data.DefaultCurrency,
+		TransactionDetails: data.TransactionDetails{
+			Description: "Rent for May",
+			Reference:   "INV-2024-05",
+			Metadata:    map[string]any{"flat": "12B", "month": float64(5)},
+		},
+	}
+	mockRepo.On("Transfer", req).Return(1, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"IdSource":    1,
+		"IdEndpoint":  2,
+		"Amount":      "100",
+		"Description": "Rent for May",
+		"Reference":   "INV-2024-05",
+		"Metadata":    map[string]interface{}{"flat": "12B", "month": 5},
+	})
+
+	httpReq, _ := http.NewRequest("POST", "/transferMoney", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockRepo.AssertCalled(t, "Transfer", req)
+}
 
-	mockRepo.AssertCalled(t, "DecreaseMoney", idSource, amount)
-	mockRepo.AssertCalled(t, "AddMoney", idEndpoint, amount)
-	mockRepo.AssertCalled(t, "AddTransaction", amount, []int{idSource, idEndpoint})
+// TestDepositMoney_MetadataTooLarge checks that oversized metadata is refused with the reason
+func TestDepositMoney_MetadataTooLarge(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("Deposit", mock.Anything).
+		Return(0, fmt.Errorf("%w: metadata is larger than %d bytes", data.ErrInvalidDetails, data.MaxMetadataSize))
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"Id":       1,
+		"Amount":   "10",
+		"Metadata": map[string]interface{}{"note": strings.Repeat("x", data.MaxMetadataSize)},
+	})
+
+	httpReq, _ := http.NewRequest("POST", "/depositMoney", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "metadata is larger than")
+}
+
+// TestTransactionHistory_Reference checks searching a user's transactions by external reference
+func TestTransactionHistory_Reference(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	reference := "INV-2024-05"
+	page := &data.TransactionPage{Transactions: []*data.Transactions{
+		{ID: 4, Type: data.TransactionTransfer, UserIDSource: intPtr(1), UserIDEndpoint: intPtr(2), Reference: &reference,
+			Metadata: map[string]any{"flat": "12B"}},
+	}}
+	req := &data.HistoryRequest{UserID: 1, Limit: data.DefaultHistoryLimit, Filter: data.HistoryFilter{Reference: reference}}
+	mockRepo.On("GetTransactionHistory", req).Return(page, nil)
+
+	httpReq, _ := http.NewRequest("GET", "/users/1/transactions?reference=INV-2024-05", nil)
+	httpReq.Header.Set("X-User-Id", "1")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Data []*data.Transactions `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Len(t, response.Data, 1)
+	assert.Equal(t, reference, *response.Data[0].Reference)
+	assert.Equal(t, "12B", response.Data[0].Metadata["flat"])
+}
+
+// TestTransactionHistory_Cursor checks that the limit and cursor reach the repository and the page cursors
+// are returned
+func TestTransactionHistory_Cursor(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	cursor := &data.HistoryCursor{CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), ID: 40}
+	page := &data.TransactionPage{
+		Transactions: []*data.Transactions{{ID: 39}, {ID: 38}},
+		NextCursor:   "next",
+		PrevCursor:   "prev",
+	}
+	mockRepo.On("GetTransactionHistory", &data.HistoryRequest{UserID: 1, Limit: 2, Cursor: cursor}).Return(page, nil)
+
+	httpReq, _ := http.NewRequest("GET", "/users/1/transactions?limit=2&cursor="+cursor.Encode(), nil)
+	httpReq.Header.Set("X-User-Id", "1")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Data       []*data.Transactions `json:"data"`
+		NextCursor *string              `json:"next_cursor"`
+		PrevCursor *string              `json:"prev_cursor"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Len(t, response.Data, 2)
+	assert.Equal(t, "next", *response.NextCursor)
+	assert.Equal(t, "prev", *response.PrevCursor)
+}
+
+// TestTransactionHistory_FirstPage checks that missing cursors are written as null
+func TestTransactionHistory_FirstPage(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("GetTransactionHistory", mock.Anything).Return(&data.TransactionPage{Transactions: []*data.Transactions{}}, nil)
+
+	httpReq, _ := http.NewRequest("GET", "/users/1/transactions", nil)
+	httpReq.Header.Set("X-User-Id", "1")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"next_cursor":null`)
+	assert.Contains(t, resp.Body.String(), `"prev_cursor":null`)
+}
+
+// TestTransactionHistory_InvalidParameters checks that bad limits and cursors are refused
+func TestTransactionHistory_InvalidParameters(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	for _, query := range []string{"limit=0", "limit=101", "limit=ten", "cursor=not-a-cursor"} {
+		httpReq, _ := http.NewRequest("GET", "/users/1/transactions?"+query, nil)
+		httpReq.Header.Set("X-User-Id", "1")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, httpReq)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code, query)
+	}
+	mockRepo.AssertNotCalled(t, "GetTransactionHistory", mock.Anything)
+}
+
+// TestTransactionHistory_Filters checks that every filter parameter reaches the repository
+func TestTransactionHistory_Filters(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	req := &data.HistoryRequest{UserID: 1, Limit: data.DefaultHistoryLimit, Filter: data.HistoryFilter{
+		From:           &from,
+		To:             &to,
+		MinAmount:      decimal.NewNullDecimal(decimal.NewFromInt(10)),
+		MaxAmount:      decimal.NewNullDecimal(decimal.RequireFromString("99.50")),
+		Direction:      data.HistoryOutgoing,
+		CounterpartyID: 2,
+		Types:          []data.TransactionType{data.TransactionTransfer, data.TransactionRefund, data.TransactionFee},
+	}}
+	mockRepo.On("GetTransactionHistory", req).Return(&data.TransactionPage{Transactions: []*data.Transactions{}}, nil)
+
+	query := "from=2024-05-01T00:00:00Z&to=2024-06-01T00:00:00Z&minAmount=10&maxAmount=99.50" +
+		"&direction=outgoing&counterparty=2&type=transfer,refund&type=fee"
+	httpReq, _ := http.NewRequest("GET", "/users/1/transactions?"+query, nil)
+	httpReq.Header.Set("X-User-Id", "1")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockRepo.AssertCalled(t, "GetTransactionHistory", req)
+}
+
+// TestTransactionHistory_InvalidFilters checks that malformed filter parameters are refused before the repository
+func TestTransactionHistory_InvalidFilters(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	tests := map[string]string{
+		"from=yesterday":   "from must be an RFC 3339 timestamp",
+		"to=2024-06-01":    "to must be an RFC 3339 timestamp",
+		"minAmount=ten":    "minAmount must be a number",
+		"maxAmount=1,5":    "maxAmount must be a number",
+		"counterparty=-3":  "counterparty must be a user id",
+		"counterparty=bob": "counterparty must be a user id",
+	}
+	for query, message := range tests {
+		httpReq, _ := http.NewRequest("GET", "/users/1/transactions?"+query, nil)
+		httpReq.Header.Set("X-User-Id", "1")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, httpReq)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code, query)
+		assert.Contains(t, resp.Body.String(), message, query)
+	}
+	mockRepo.AssertNotCalled(t, "GetTransactionHistory", mock.Anything)
+}
+
+// TestTransactionHistory_RejectedFilter checks that filters the repository rejects answer with 400 and the reason
+func TestTransactionHistory_RejectedFilter(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("GetTransactionHistory", mock.Anything).
+		Return(nil, fmt.Errorf("%w: direction must be incoming or outgoing", data.ErrInvalidFilter))
+
+	httpReq, _ := http.NewRequest("GET", "/users/1/transactions?direction=sideways", nil)
+	httpReq.Header.Set("X-User-Id", "1")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "direction must be incoming or outgoing")
+}
+
+func (m *MockRepository) GetTransaction(ctx context.Context, id int) (*data.TransactionRecord, error) {
+	args := m.Called(id)
+	if record, ok := args.Get(0).(*data.TransactionRecord); ok {
+		return record, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// refundedWithdrawal is a withdrawal of user 1 that was partly refunded
+func refundedWithdrawal() *data.TransactionRecord {
+	source := 1
+	parent := 42
+	refundedAt := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	return &data.TransactionRecord{
+		Transactions: &data.Transactions{
+			ID:           42,
+			Type:         data.TransactionWithdrawal,
+			UserIDSource: &source,
+			Amount:       decimal.NewFromInt(15),
+			Currency:     data.DefaultCurrency,
+			CreatedAt:    time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Status:     data.TransactionPartiallyRefunded,
+		Refunded:   decimal.NewFromInt(5),
+		RefundedAt: &refundedAt,
+		Linked: []*data.Transactions{
+			{ID: 43, Type: data.TransactionRefund, UserIDEndpoint: &source, ParentID: &parent, Amount: decimal.NewFromInt(5), Currency: data.DefaultCurrency, CreatedAt: refundedAt},
+		},
+	}
+}
+
+// TestGetTransaction checks that a party of a transaction gets the full record
+func TestGetTransaction(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("GetTransaction", 42).Return(refundedWithdrawal(), nil)
+
+	for _, path := range []string{"/transactions/42", "/v1/transactions/42"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("X-User-Id", "1")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code, path)
+
+		var response struct {
+			Data struct {
+				data.Transactions
+				Status     data.TransactionStatus `json:"Status"`
+				Refunded   decimal.Decimal        `json:"Refunded"`
+				RefundedAt *time.Time             `json:"RefundedAt"`
+				Linked     []*data.Transactions   `json:"Linked"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		assert.Equal(t, 42, response.Data.ID)
+		assert.Equal(t, data.TransactionWithdrawal, response.Data.Type)
+		assert.Equal(t, 1, *response.Data.UserIDSource)
+		assert.Equal(t, data.TransactionPartiallyRefunded, response.Data.Status)
+		assert.True(t, decimal.NewFromInt(5).Equal(response.Data.Refunded))
+		assert.NotNil(t, response.Data.RefundedAt)
+		if assert.Len(t, response.Data.Linked, 1) {
+			assert.Equal(t, 43, response.Data.Linked[0].ID)
+		}
+	}
+}
+
+// TestGetTransaction_Authorization checks that only the parties of a transaction and admins can read it
+func TestGetTransaction_Authorization(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	mockRepo.On("GetTransaction", 42).Return(refundedWithdrawal(), nil)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"malformed user", map[string]string{"X-User-Id": "one"}, http.StatusUnauthorized},
+		{"wrong admin token", map[string]string{"Authorization": "Bearer guess"}, http.StatusUnauthorized},
+		{"other user", map[string]string{"X-User-Id": "2"}, http.StatusNotFound},
+		{"party", map[string]string{"X-User-Id": "1"}, http.StatusOK},
+		{"admin", map[string]string{"Authorization": "Bearer secret"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/transactions/42", nil)
+		for name, value := range tt.headers {
+			req.Header.Set(name, value)
+		}
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, tt.status, resp.Code, tt.name)
+		if tt.status == http.StatusNotFound {
+			assert.Contains(t, resp.Body.String(), "Transaction doesn't exist", tt.name)
+		}
+	}
+}
+
+// TestGetTransaction_NotFound checks that unknown and malformed transaction ids are refused
+func TestGetTransaction_NotFound(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("GetTransaction", 7).Return(nil, fmt.Errorf("transaction 7: %w", data.ErrTransactionNotFound))
+
+	req, _ := http.NewRequest("GET", "/transactions/7", nil)
+	req.Header.Set("X-User-Id", "1")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Transaction doesn't exist")
+
+	req, _ = http.NewRequest("GET", "/transactions/latest", nil)
+	req.Header.Set("X-User-Id", "1")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	mockRepo.AssertNumberOfCalls(t, "GetTransaction", 1)
+}
+
+// TestDepositMoney_TransactionID checks that a deposit answers with the id of the transaction it recorded
+func TestDepositMoney_TransactionID(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("Deposit", &data.DepositRequest{UserID: 5, Amount: decimal.NewFromInt(20), Currency: data.DefaultCurrency}).Return(77, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Id": 5, "Amount": "20"})
+	req, _ := http.NewRequest("POST", "/v1/deposits", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "/v1/transactions/77", resp.Header().Get("Location"))
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+	assert.Equal(t, float64(77), response["transaction_id"])
+}
+
+// TestTransferMoney_TransactionID checks that a transfer answers with the id of the transaction it recorded
+func TestTransferMoney_TransactionID(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("Transfer", &data.TransferRequest{From: 5, To: 6, Amount: decimal.NewFromInt(20), Currency: data.DefaultCurrency}).Return(78, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"IdSource": 5, "IdEndpoint": 6, "Amount": "20"})
+	req, _ := http.NewRequest("POST", "/v1/transfers", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "/v1/transactions/78", resp.Header().Get("Location"))
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+	assert.Equal(t, float64(78), response["transaction_id"])
+}
+
+// TestTransactionHistory_Authorization checks that a history can only be read by its user or an admin
+func TestTransactionHistory_Authorization(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	mockRepo.On("GetTransactionHistory", mock.Anything).Return(&data.TransactionPage{Transactions: []*data.Transactions{}}, nil)
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		status  int
+	}{
+		{"anonymous", "/users/1/transactions", nil, http.StatusUnauthorized},
+		{"other user", "/users/1/transactions", map[string]string{"X-User-Id": "2"}, http.StatusForbidden},
+		{"other user on v1", "/v1/users/1/transactions", map[string]string{"X-User-Id": "2"}, http.StatusForbidden},
+		{"owner", "/v1/users/1/transactions", map[string]string{"X-User-Id": "1"}, http.StatusOK},
+		{"admin", "/users/1/transactions", map[string]string{"Authorization": "Bearer secret"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		httpReq, _ := http.NewRequest("GET", tt.path, nil)
+		for name, value := range tt.headers {
+			httpReq.Header.Set(name, value)
+		}
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, httpReq)
+
+		assert.Equal(t, tt.status, resp.Code, tt.name)
+	}
+	mockRepo.AssertNumberOfCalls(t, "GetTransactionHistory", 2)
 }
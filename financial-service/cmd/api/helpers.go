@@ -3,8 +3,11 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
 	"io"
 	"net/http"
+	"strconv"
 )
 
 type jsonResponse struct {
@@ -70,3 +73,18 @@ func (app *Config) errorJSON(w http.ResponseWriter, err error, status ...int) er
 
 	return app.writeJSON(w, statusCode, payload)
 }
+
+// pathID parses the id path parameter, answering with 400 when it isn't a number. name is the kind of
+// resource the id refers to
+func pathID(c *gin.Context, name string) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": fmt.Sprintf("Invalid %s id", name),
+		})
+		return 0, false
+	}
+
+	return id, true
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"financial-service/data"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"log"
+	"net/http"
+	"time"
+)
+
+const defaultHoldTTL = 7 * 24 * time.Hour
+
+// authorizeHold reserves funds of a user for a later transfer to another user
+func (app *Config) authorizeHold(c *gin.Context) {
+	var requestPayload struct {
+		Amount     decimal.Decimal `json:"Amount"`
+		IDSource   int             `json:"IdSource"`
+		IDEndpoint int             `json:"IdEndpoint"`
+		Currency   string          `json:"Currency"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+
+	ttl := app.HoldTTL
+	if ttl == 0 {
+		ttl = defaultHoldTTL
+	}
+
+	hold, err := app.Repo.AuthorizeHold(c.Request.Context(), &data.HoldRequest{
+		UserID:        requestPayload.IDSource,
+		DestinationID: requestPayload.IDEndpoint,
+		Amount:        requestPayload.Amount,
+		Currency:      requestPayload.Currency,
+		TTL:           ttl,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't authorize the hold"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Hold authorized",
+		"data":    hold,
+	})
+}
+
+// captureHold turns a hold into a transfer. Without an amount the whole hold is captured
+func (app *Config) captureHold(c *gin.Context) {
+	var requestPayload struct {
+		Amount decimal.Decimal `json:"Amount"`
+	}
+
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&requestPayload); err != nil || requestPayload.Amount.IsNegative() {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   true,
+				"message": "Invalid JSON",
+			})
+			return
+		}
+	}
+
+	id, ok := pathID(c, "hold")
+	if !ok {
+		return
+	}
+
+	hold, err := app.Repo.CaptureHold(c.Request.Context(), id, requestPayload.Amount)
+	if err != nil {
+		if writeLimitError(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't capture the hold"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Hold captured",
+		"data":    hold,
+	})
+}
+
+// voidHold cancels a hold and releases its funds
+func (app *Config) voidHold(c *gin.Context) {
+	id, ok := pathID(c, "hold")
+	if !ok {
+		return
+	}
+
+	hold, err := app.Repo.VoidHold(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't void the hold"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Hold voided",
+		"data":    hold,
+	})
+}
+
+// expireHolds periodically expires holds past their TTL and releases their funds
+func (app *Config) expireHolds(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		expired, err := app.Repo.ExpireHolds(context.Background())
+		if err != nil {
+			log.Printf("Couldn't expire holds: %v\n", err)
+			continue
+		}
+		if expired > 0 {
+			log.Printf("Expired %d holds\n", expired)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"financial-service/data"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (m *MockRepository) AuthorizeHold(ctx context.Context, req *data.HoldRequest) (*data.Hold, error) {
+	args := m.Called(req)
+	if hold, ok := args.Get(0).(*data.Hold); ok {
+		return hold, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) CaptureHold(ctx context.Context, id int, amount decimal.Decimal) (*data.Hold, error) {
+	args := m.Called(id, amount)
+	if hold, ok := args.Get(0).(*data.Hold); ok {
+		return hold, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) VoidHold(ctx context.Context, id int) (*data.Hold, error) {
+	args := m.Called(id)
+	if hold, ok := args.Get(0).(*data.Hold); ok {
+		return hold, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// TestAuthorizeHold_Success checks that the hold is returned with its available and ledger balance
+func TestAuthorizeHold_Success(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	req := &data.HoldRequest{UserID: 1, DestinationID: 2, Amount: decimal.RequireFromString("30"), Currency: "RUB", TTL: defaultHoldTTL}
+	hold := &data.Hold{
+		ID:            7,
+		UserID:        1,
+		DestinationID: 2,
+		Amount:        decimal.NewFromInt(30),
+		Currency:      "RUB",
+		Status:        data.HoldAuthorized,
+		Balance: &data.Balance{
+			UserID:    1,
+			Currency:  "RUB",
+			Ledger:    decimal.NewFromInt(100),
+			Held:      decimal.NewFromInt(30),
+			Available: decimal.NewFromInt(70),
+		},
+	}
+
+	mockRepo.On("AuthorizeHold", req).Return(hold, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"IdSource": 1, "IdEndpoint": 2, "Amount": "30", "Currency": "RUB"})
+
+	httpReq, _ := http.NewRequest("POST", "/holds", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Error   bool       `json:"error"`
+		Message string     `json:"message"`
+		Data    *data.Hold `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, false, response.Error)
+	assert.Equal(t, "Hold authorized", response.Message)
+	assert.Equal(t, 7, response.Data.ID)
+	assert.True(t, response.Data.Balance.Ledger.Equal(decimal.NewFromInt(100)))
+	assert.True(t, response.Data.Balance.Available.Equal(decimal.NewFromInt(70)))
+
+	mockRepo.AssertCalled(t, "AuthorizeHold", req)
+}
+
+// TestAuthorizeHold_InsufficientBalance checks the error when the available balance can't cover the hold
+func TestAuthorizeHold_InsufficientBalance(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("AuthorizeHold", mock.Anything).Return(nil, fmt.Errorf("%w: available balance is 10", data.ErrInsufficientBalance))
+
+	body, _ := json.Marshal(map[string]interface{}{"IdSource": 1, "IdEndpoint": 2, "Amount": "30"})
+
+	httpReq, _ := http.NewRequest("POST", "/holds", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.JSONEq(t, `{"error":true,"message":"Insufficient balance on the source user"}`, resp.Body.String())
+}
+
+// TestCaptureHold_Partial checks that the requested amount is passed through to the repository
+func TestCaptureHold_Partial(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	amount := decimal.RequireFromString("12.5")
+	hold := &data.Hold{ID: 7, Status: data.HoldCaptured, CapturedAmount: decimal.NewNullDecimal(amount), TransactionID: intPtr(90)}
+
+	mockRepo.On("CaptureHold", 7, amount).Return(hold, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Amount": "12.5"})
+
+	httpReq, _ := http.NewRequest("POST", "/holds/7/capture", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockRepo.AssertCalled(t, "CaptureHold", 7, amount)
+}
+
+// TestVoidHold_NotActive checks the error when the hold was already settled
+func TestVoidHold_NotActive(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("VoidHold", 7).Return(nil, fmt.Errorf("%w: hold 7 is captured", data.ErrHoldNotActive))
+
+	httpReq, _ := http.NewRequest("POST", "/holds/7/void", nil)
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.JSONEq(t, `{"error":true,"message":"Hold was already captured, voided or has expired"}`, resp.Body.String())
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"financial-service/data"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	idempotencyHeader       = "Idempotency-Key"
+	defaultIdempotencyTTL   = 24 * time.Hour
+	maxIdempotencyKeyLength = 255
+)
+
+var errInvalidIdempotencyKey = errors.New("invalid idempotency key")
+
+// idempotentContext attaches the Idempotency-Key header of the request to its context, together with the
+// fingerprint of payload and the response the request produces on success. Requests without the header
+// get their context back unchanged
+func (app *Config) idempotentContext(c *gin.Context, payload any, status int, response any) (context.Context, error) {
+	ctx := c.Request.Context()
+
+	key := c.GetHeader(idempotencyHeader)
+	if key == "" {
+		return ctx, nil
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, errInvalidIdempotencyKey
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := sha256.Sum256(body)
+
+	stored, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := app.IdempotencyTTL
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+
+	return data.WithIdempotencyKey(ctx, &data.IdempotencyKey{
+		Key:         key,
+		Endpoint:    c.FullPath(),
+		Fingerprint: hex.EncodeToString(fingerprint[:]),
+		StatusCode:  status,
+		Response:    stored,
+		TTL:         ttl,
+	}), nil
+}
+
+// writeIdempotencyError answers requests that failed because of their idempotency key: retries get the
+// stored response replayed, keys reused with a different body get a conflict. It reports whether it wrote
+// a response
+func (app *Config) writeIdempotencyError(c *gin.Context, err error) bool {
+	var replay *data.ReplayError
+	if errors.As(err, &replay) {
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(replay.StatusCode, "application/json; charset=utf-8", replay.Response)
+		return true
+	}
+
+	if errors.Is(err, data.ErrIdempotencyConflict) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   true,
+			"message": "Idempotency key was already used with a different request",
+		})
+		return true
+	}
+
+	return false
+}
+
+// purgeIdempotencyKeys periodically deletes expired idempotency keys
+func (app *Config) purgeIdempotencyKeys(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purged, err := app.Repo.PurgeIdempotencyKeys(context.Background())
+		if err != nil {
+			log.Printf("Couldn't purge idempotency keys: %v\n", err)
+			continue
+		}
+		if purged > 0 {
+			log.Printf("Purged %d expired idempotency keys\n", purged)
+		}
+	}
+}
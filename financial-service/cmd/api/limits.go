@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"financial-service/data"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"net/http"
+)
+
+// limitExceededCode identifies spending limit rejections for clients
+const limitExceededCode = "limit_exceeded"
+
+// writeLimitError answers requests rejected by a spending limit with which limit tripped and when it
+// resets. It reports whether it wrote a response
+func writeLimitError(c *gin.Context, err error) bool {
+	var limitErr *data.LimitExceededError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   true,
+		"code":    limitExceededCode,
+		"message": "Spending limit exceeded",
+		"data":    limitErr,
+	})
+	return true
+}
+
+// setGlobalSpendingLimit sets the default spending limit of a currency
+func (app *Config) setGlobalSpendingLimit(c *gin.Context) {
+	app.setSpendingLimit(c, nil)
+}
+
+// setUserSpendingLimit overrides the spending limit of one user
+func (app *Config) setUserSpendingLimit(c *gin.Context) {
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	app.setSpendingLimit(c, &id)
+}
+
+// setSpendingLimit stores the limit in the request body for userID, or globally when userID is nil
+func (app *Config) setSpendingLimit(c *gin.Context, userID *int) {
+	var requestPayload struct {
+		Currency      string              `json:"Currency"`
+		DailyAmount   decimal.NullDecimal `json:"DailyAmount"`
+		DailyCount    *int                `json:"DailyCount"`
+		MonthlyAmount decimal.NullDecimal `json:"MonthlyAmount"`
+		MonthlyCount  *int                `json:"MonthlyCount"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+
+	limit, err := app.Repo.SetSpendingLimit(c.Request.Context(), &data.SpendingLimit{
+		UserID:        userID,
+		Currency:      requestPayload.Currency,
+		DailyAmount:   requestPayload.DailyAmount,
+		DailyCount:    requestPayload.DailyCount,
+		MonthlyAmount: requestPayload.MonthlyAmount,
+		MonthlyCount:  requestPayload.MonthlyCount,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't set the spending limit"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Spending limit set",
+		"data":    limit,
+	})
+}
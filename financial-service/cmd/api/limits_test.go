@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"financial-service/data"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (m *MockRepository) SetSpendingLimit(ctx context.Context, l *data.SpendingLimit) (*data.SpendingLimit, error) {
+	args := m.Called(l)
+	if limit, ok := args.Get(0).(*data.SpendingLimit); ok {
+		return limit, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// TestTransferMoney_LimitExceeded checks that a spending limit rejection says which limit tripped and when it resets
+func TestTransferMoney_LimitExceeded(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	resetsAt := time.Date(2024, time.June, 2, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("Transfer", mock.Anything).Return(0, &data.LimitExceededError{
+		Limit:     data.LimitDailyAmount,
+		Currency:  "RUB",
+		Max:       decimal.NewFromInt(1000),
+		Used:      decimal.NewFromInt(900),
+		Requested: decimal.NewFromInt(200),
+		ResetsAt:  resetsAt,
+	})
+
+	body, _ := json.Marshal(map[string]interface{}{"Amount": "200", "IdSource": 1, "IdEndpoint": 2})
+
+	httpReq, _ := http.NewRequest("POST", "/transferMoney", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	var response struct {
+		Error   bool                     `json:"error"`
+		Code    string                   `json:"code"`
+		Message string                   `json:"message"`
+		Data    *data.LimitExceededError `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, true, response.Error)
+	assert.Equal(t, "limit_exceeded", response.Code)
+	assert.Equal(t, data.LimitDailyAmount, response.Data.Limit)
+	assert.True(t, response.Data.Used.Equal(decimal.NewFromInt(900)))
+	assert.Equal(t, resetsAt, response.Data.ResetsAt)
+}
+
+// TestSetUserSpendingLimit_Success checks that a user override is stored for the user in the path
+func TestSetUserSpendingLimit_Success(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	dailyCount := 5
+	req := &data.SpendingLimit{
+		UserID:      intPtr(7),
+		Currency:    "RUB",
+		DailyAmount: decimal.NewNullDecimal(decimal.RequireFromString("1000")),
+		DailyCount:  &dailyCount,
+	}
+
+	mockRepo.On("SetSpendingLimit", req).Return(req, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Currency": "RUB", "DailyAmount": "1000", "DailyCount": 5})
+
+	httpReq, _ := http.NewRequest("PUT", "/users/7/limits", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockRepo.AssertCalled(t, "SetSpendingLimit", req)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"financial-service/data"
@@ -11,6 +12,7 @@ import (
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/joho/godotenv"
 	"github.com/pressly/goose/v3"
+	"github.com/shopspring/decimal"
 	"log"
 	"net/http"
 	"os"
@@ -25,8 +27,13 @@ var counts int64
 var EmbedMigrations embed.FS
 
 type Config struct {
-	Repo   data.Repository
-	Client *http.Client
+	Repo           data.Repository
+	Client         *http.Client
+	IdempotencyTTL time.Duration
+	FXSpread       decimal.NullDecimal
+	FXQuoteTTL     time.Duration
+	AdminToken     string
+	HoldTTL        time.Duration
 }
 
 // main starts the server and establishing connection to database
@@ -43,15 +50,31 @@ func main() {
 		log.Panic("Can't connect to Postgres!")
 	}
 
+	fxSpread, err := decimalFromEnv("FX_SPREAD")
+	if err == nil && fxSpread.Valid && !validFXSpread(fxSpread.Decimal) {
+		err = fmt.Errorf("FX_SPREAD %s must be at least 0 and below 1", fxSpread.Decimal)
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
 	// set up config
 	app := Config{
-		Client: &http.Client{},
+		Client:         &http.Client{},
+		IdempotencyTTL: durationFromEnv("IDEMPOTENCY_TTL", defaultIdempotencyTTL),
+		FXSpread:       fxSpread,
+		FXQuoteTTL:     durationFromEnv("FX_QUOTE_TTL", defaultFXQuoteTTL),
+		AdminToken:     os.Getenv("ADMIN_TOKEN"),
+		HoldTTL:        durationFromEnv("HOLD_TTL", defaultHoldTTL),
 	}
 	app.setupRepo(conn)
 
 	goose.SetBaseFS(EmbedMigrations)
 
 	migrationsDir := os.Getenv("GOOSE_MIGRATION_DIR")
+	if migrationsDir == "" {
+		migrationsDir = "migrations"
+	}
 
 	if err := goose.SetDialect("postgres"); err != nil {
 		panic(err)
@@ -61,6 +84,16 @@ func main() {
 		panic(err)
 	}
 
+	if path := os.Getenv("FX_RATES_FILE"); path != "" {
+		app.importRates(path)
+	}
+
+	go app.purgeIdempotencyKeys(time.Hour)
+	go app.expireHolds(time.Minute)
+	go app.runSchedules(time.Minute)
+	go app.snapshotBalances(time.Hour)
+	go app.processPayouts(time.Minute)
+
 	router := gin.Default()
 
 	app.routes(router)
@@ -117,3 +150,51 @@ func (app *Config) setupRepo(conn *sql.DB) {
 	db := data.NewPostgresRepository(conn)
 	app.Repo = db
 }
+
+// durationFromEnv reads a duration such as "24h" from the environment, falling back to def when it is unset or invalid
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using %s\n", key, value, def)
+		return def
+	}
+
+	return d
+}
+
+// decimalFromEnv reads a decimal from the environment. The result isn't valid when the variable is unset,
+// so that an explicit zero can be told apart from a missing value
+func decimalFromEnv(key string) (decimal.NullDecimal, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return decimal.NullDecimal{}, nil
+	}
+
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		return decimal.NullDecimal{}, fmt.Errorf("%s %q is not a decimal", key, value)
+	}
+
+	return decimal.NewNullDecimal(d), nil
+}
+
+// importRates loads conversion rates from a local CSV or JSON file into the rates table
+func (app *Config) importRates(path string) {
+	rates, err := data.ReadRatesFile(path)
+	if err != nil {
+		log.Printf("Couldn't read rates from %s: %v\n", path, err)
+		return
+	}
+
+	if err := app.Repo.SetRates(context.Background(), rates); err != nil {
+		log.Printf("Couldn't import rates from %s: %v\n", path, err)
+		return
+	}
+
+	log.Printf("Imported %d rates from %s\n", len(rates), path)
+}
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS users (
+    id         SERIAL PRIMARY KEY,
+    balance    NUMERIC(20, 2) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP      NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    id             SERIAL PRIMARY KEY,
+    useridsource   INT,
+    useridendpoint INT,
+    amount         NUMERIC(20, 2) NOT NULL,
+    createdat      TIMESTAMP      NOT NULL DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,60 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS accounts (
+    id         SERIAL PRIMARY KEY,
+    user_id    INT UNIQUE REFERENCES users (id),
+    code       TEXT UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    CHECK ((user_id IS NULL) <> (code IS NULL))
+);
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id             SERIAL PRIMARY KEY,
+    transaction_id INT REFERENCES transactions (id),
+    description    TEXT      NOT NULL DEFAULT '',
+    created_at     TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS postings (
+    id         SERIAL PRIMARY KEY,
+    entry_id   INT            NOT NULL REFERENCES journal_entries (id),
+    account_id INT            NOT NULL REFERENCES accounts (id),
+    amount     NUMERIC(20, 2) NOT NULL CHECK (amount <> 0)
+);
+
+CREATE INDEX IF NOT EXISTS postings_account_id_idx ON postings (account_id);
+CREATE INDEX IF NOT EXISTS journal_entries_transaction_id_idx ON journal_entries (transaction_id);
+
+INSERT INTO accounts (code) VALUES ('external_funding');
+
+INSERT INTO accounts (user_id)
+SELECT id FROM users;
+
+-- Existing balances predate the ledger, so they are brought in as a single opening entry
+-- funded from the external account.
+-- +goose StatementBegin
+DO $$
+DECLARE
+    entry INT;
+BEGIN
+    IF EXISTS (SELECT 1 FROM users WHERE balance <> 0) THEN
+        INSERT INTO journal_entries (description) VALUES ('opening balances') RETURNING id INTO entry;
+
+        INSERT INTO postings (entry_id, account_id, amount)
+        SELECT entry, a.id, u.balance
+        FROM users u
+        JOIN accounts a ON a.user_id = u.id
+        WHERE u.balance <> 0;
+
+        INSERT INTO postings (entry_id, account_id, amount)
+        SELECT entry, a.id, -(SELECT SUM(balance) FROM users)
+        FROM accounts a
+        WHERE a.code = 'external_funding'
+          AND (SELECT SUM(balance) FROM users) <> 0;
+    END IF;
+END $$;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE IF EXISTS postings;
+DROP TABLE IF EXISTS journal_entries;
+DROP TABLE IF EXISTS accounts;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    endpoint    TEXT      NOT NULL,
+    key         TEXT      NOT NULL,
+    fingerprint TEXT      NOT NULL,
+    status_code INT       NOT NULL,
+    response    BYTEA     NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT now(),
+    expires_at  TIMESTAMP NOT NULL,
+    PRIMARY KEY (endpoint, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,33 @@
+-- +goose Up
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS balance NUMERIC(20, 4) NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT now();
+
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_user_id_key;
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_code_key;
+ALTER TABLE accounts ADD CONSTRAINT accounts_user_id_currency_key UNIQUE (user_id, currency);
+ALTER TABLE accounts ADD CONSTRAINT accounts_code_currency_key UNIQUE (code, currency);
+
+ALTER TABLE postings ALTER COLUMN amount TYPE NUMERIC(20, 4);
+ALTER TABLE transactions ALTER COLUMN amount TYPE NUMERIC(20, 4);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+UPDATE accounts a
+SET balance = COALESCE((SELECT SUM(p.amount) FROM postings p WHERE p.account_id = a.id), 0);
+
+-- +goose Down
+ALTER TABLE transactions DROP COLUMN IF EXISTS currency;
+ALTER TABLE transactions ALTER COLUMN amount TYPE NUMERIC(20, 2);
+ALTER TABLE postings ALTER COLUMN amount TYPE NUMERIC(20, 2);
+
+DELETE FROM postings WHERE account_id IN (SELECT id FROM accounts WHERE currency <> 'RUB');
+DELETE FROM accounts WHERE currency <> 'RUB';
+
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_code_currency_key;
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_user_id_currency_key;
+ALTER TABLE accounts ADD CONSTRAINT accounts_user_id_key UNIQUE (user_id);
+ALTER TABLE accounts ADD CONSTRAINT accounts_code_key UNIQUE (code);
+
+ALTER TABLE accounts DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE accounts DROP COLUMN IF EXISTS balance;
+ALTER TABLE accounts DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS fx_rates (
+    base       CHAR(3)        NOT NULL,
+    quote      CHAR(3)        NOT NULL,
+    rate       NUMERIC(24, 10) NOT NULL CHECK (rate > 0),
+    updated_at TIMESTAMP      NOT NULL DEFAULT now(),
+    PRIMARY KEY (base, quote)
+);
+
+CREATE TABLE IF NOT EXISTS fx_quotes (
+    id                TEXT PRIMARY KEY,
+    base              CHAR(3)         NOT NULL,
+    quote             CHAR(3)         NOT NULL,
+    rate              NUMERIC(24, 10) NOT NULL,
+    spread            NUMERIC(10, 6)  NOT NULL,
+    applied_rate      NUMERIC(24, 10) NOT NULL,
+    amount            NUMERIC(20, 4)  NOT NULL,
+    converted_amount  NUMERIC(20, 4)  NOT NULL,
+    created_at        TIMESTAMP       NOT NULL DEFAULT now(),
+    expires_at        TIMESTAMP       NOT NULL,
+    used_at           TIMESTAMP
+);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS destination_amount NUMERIC(20, 4);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS destination_currency CHAR(3);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_rate NUMERIC(24, 10);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_quote_id TEXT REFERENCES fx_quotes (id);
+
+-- +goose Down
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_quote_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_rate;
+ALTER TABLE transactions DROP COLUMN IF EXISTS destination_currency;
+ALTER TABLE transactions DROP COLUMN IF EXISTS destination_amount;
+DROP TABLE IF EXISTS fx_quotes;
+DROP TABLE IF EXISTS fx_rates;
@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS type TEXT NOT NULL DEFAULT 'transfer';
+
+UPDATE transactions SET type = 'deposit' WHERE useridsource IS NULL;
+
+ALTER TABLE transactions ALTER COLUMN type DROP DEFAULT;
+ALTER TABLE transactions ADD CONSTRAINT transactions_type_check
+    CHECK (type IN ('deposit', 'withdrawal', 'transfer', 'fee', 'refund', 'adjustment'));
+ALTER TABLE transactions ALTER COLUMN useridsource DROP NOT NULL;
+ALTER TABLE transactions ALTER COLUMN useridendpoint DROP NOT NULL;
+ALTER TABLE transactions ADD CONSTRAINT transactions_party_check
+    CHECK (useridsource IS NOT NULL OR useridendpoint IS NOT NULL);
+
+CREATE INDEX IF NOT EXISTS transactions_useridsource_idx ON transactions (useridsource);
+CREATE INDEX IF NOT EXISTS transactions_useridendpoint_idx ON transactions (useridendpoint);
+
+-- +goose Down
+DROP INDEX IF EXISTS transactions_useridendpoint_idx;
+DROP INDEX IF EXISTS transactions_useridsource_idx;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_party_check;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_type_check;
+ALTER TABLE transactions DROP COLUMN IF EXISTS type;
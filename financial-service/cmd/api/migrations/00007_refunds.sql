@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES transactions (id);
+
+CREATE INDEX IF NOT EXISTS transactions_parent_id_idx ON transactions (parent_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS transactions_parent_id_idx;
+ALTER TABLE transactions DROP COLUMN IF EXISTS parent_id;
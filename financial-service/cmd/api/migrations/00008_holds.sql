@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS held NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (held >= 0);
+
+CREATE TABLE IF NOT EXISTS holds (
+    id              SERIAL PRIMARY KEY,
+    user_id         INT            NOT NULL REFERENCES users (id),
+    account_id      INT            NOT NULL REFERENCES accounts (id),
+    destination_id  INT            NOT NULL REFERENCES users (id),
+    amount          NUMERIC(20, 4) NOT NULL CHECK (amount > 0),
+    captured_amount NUMERIC(20, 4),
+    currency        CHAR(3)        NOT NULL,
+    status          TEXT           NOT NULL DEFAULT 'authorized'
+        CHECK (status IN ('authorized', 'captured', 'voided', 'expired')),
+    transaction_id  INT REFERENCES transactions (id),
+    created_at      TIMESTAMP      NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMP      NOT NULL DEFAULT now(),
+    expires_at      TIMESTAMP      NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS holds_authorized_expires_at_idx ON holds (expires_at) WHERE status = 'authorized';
+
+-- +goose Down
+DROP TABLE IF EXISTS holds;
+ALTER TABLE accounts DROP COLUMN IF EXISTS held;
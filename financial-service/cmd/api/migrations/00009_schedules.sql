@@ -0,0 +1,44 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS schedules (
+    id                     SERIAL PRIMARY KEY,
+    user_id_source         INT            NOT NULL REFERENCES users (id),
+    user_id_endpoint       INT            NOT NULL REFERENCES users (id),
+    amount                 NUMERIC(20, 4) NOT NULL CHECK (amount > 0),
+    currency               CHAR(3)        NOT NULL,
+    frequency              TEXT           NOT NULL CHECK (frequency IN ('daily', 'weekly', 'monthly', 'interval')),
+    interval_seconds       BIGINT CHECK (interval_seconds > 0),
+    day_of_month           INT CHECK (day_of_month BETWEEN 1 AND 31),
+    start_at               TIMESTAMP      NOT NULL,
+    end_at                 TIMESTAMP,
+    max_occurrences        INT CHECK (max_occurrences > 0),
+    occurrences            INT            NOT NULL DEFAULT 0,
+    on_insufficient        TEXT           NOT NULL DEFAULT 'skip' CHECK (on_insufficient IN ('skip', 'retry')),
+    max_retries            INT            NOT NULL DEFAULT 0 CHECK (max_retries >= 0),
+    retry_interval_seconds BIGINT         NOT NULL DEFAULT 3600 CHECK (retry_interval_seconds > 0),
+    retries                INT            NOT NULL DEFAULT 0,
+    status                 TEXT           NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'cancelled')),
+    next_run_at            TIMESTAMP      NOT NULL,
+    retry_at               TIMESTAMP,
+    created_at             TIMESTAMP      NOT NULL DEFAULT now(),
+    updated_at             TIMESTAMP      NOT NULL DEFAULT now(),
+    CHECK (user_id_source <> user_id_endpoint),
+    CHECK (frequency <> 'interval' OR interval_seconds IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS schedules_active_due_idx ON schedules (COALESCE(retry_at, next_run_at)) WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS schedule_runs (
+    id             SERIAL PRIMARY KEY,
+    schedule_id    INT       NOT NULL REFERENCES schedules (id),
+    scheduled_for  TIMESTAMP NOT NULL,
+    status         TEXT      NOT NULL CHECK (status IN ('succeeded', 'skipped', 'retrying', 'failed')),
+    transaction_id INT REFERENCES transactions (id),
+    error          TEXT,
+    created_at     TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS schedule_runs_schedule_id_idx ON schedule_runs (schedule_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS schedule_runs;
+DROP TABLE IF EXISTS schedules;
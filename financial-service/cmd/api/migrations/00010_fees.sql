@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS fee_rules (
+    id               SERIAL PRIMARY KEY,
+    transaction_type TEXT           NOT NULL CHECK (transaction_type IN ('transfer', 'withdrawal')),
+    currency         CHAR(3),
+    min_amount       NUMERIC(20, 4),
+    max_amount       NUMERIC(20, 4),
+    flat             NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (flat >= 0),
+    percent          NUMERIC(9, 6)  NOT NULL DEFAULT 0 CHECK (percent >= 0 AND percent < 1),
+    min_fee          NUMERIC(20, 4) CHECK (min_fee >= 0),
+    max_fee          NUMERIC(20, 4) CHECK (max_fee >= 0),
+    priority         INT            NOT NULL DEFAULT 0,
+    active           BOOLEAN        NOT NULL DEFAULT TRUE,
+    created_at       TIMESTAMP      NOT NULL DEFAULT now(),
+    CHECK (min_amount IS NULL OR max_amount IS NULL OR min_amount < max_amount),
+    CHECK (min_fee IS NULL OR max_fee IS NULL OR min_fee <= max_fee)
+);
+
+CREATE INDEX IF NOT EXISTS fee_rules_active_type_idx ON fee_rules (transaction_type, currency) WHERE active;
+
+-- +goose Down
+DROP TABLE IF EXISTS fee_rules;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_limit NUMERIC(20, 4) NOT NULL DEFAULT 0 CHECK (overdraft_limit >= 0);
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS negative_since TIMESTAMP;
+
+UPDATE accounts SET negative_since = updated_at WHERE user_id IS NOT NULL AND balance < 0;
+
+CREATE INDEX IF NOT EXISTS accounts_negative_since_idx ON accounts (negative_since) WHERE negative_since IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS accounts_negative_since_idx;
+ALTER TABLE accounts DROP COLUMN IF EXISTS negative_since;
+ALTER TABLE accounts DROP COLUMN IF EXISTS overdraft_limit;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS spending_limits (
+    id             SERIAL PRIMARY KEY,
+    user_id        INT REFERENCES users (id),
+    currency       CHAR(3)   NOT NULL,
+    daily_amount   NUMERIC(20, 4) CHECK (daily_amount >= 0),
+    daily_count    INT CHECK (daily_count >= 0),
+    monthly_amount NUMERIC(20, 4) CHECK (monthly_amount >= 0),
+    monthly_count  INT CHECK (monthly_count >= 0),
+    updated_at     TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS spending_limits_user_currency_idx ON spending_limits (user_id, currency) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS spending_limits_global_currency_idx ON spending_limits (currency) WHERE user_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS transactions_outgoing_idx ON transactions (useridsource, currency, createdat)
+    WHERE type IN ('transfer', 'withdrawal');
+
+-- +goose Down
+DROP INDEX IF EXISTS transactions_outgoing_idx;
+DROP TABLE IF EXISTS spending_limits;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    account_id    INT            NOT NULL REFERENCES accounts (id),
+    as_of         TIMESTAMP      NOT NULL,
+    balance       NUMERIC(20, 4) NOT NULL,
+    last_entry_id INT REFERENCES journal_entries (id),
+    created_at    TIMESTAMP      NOT NULL DEFAULT now(),
+    PRIMARY KEY (account_id, as_of)
+);
+
+CREATE INDEX IF NOT EXISTS journal_entries_created_at_idx ON journal_entries (created_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS journal_entries_created_at_idx;
+DROP TABLE IF EXISTS balance_snapshots;
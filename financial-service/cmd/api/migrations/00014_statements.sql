@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS statements (
+    user_id    INT       NOT NULL REFERENCES users (id),
+    currency   CHAR(3)   NOT NULL,
+    period     CHAR(7)   NOT NULL,
+    format     TEXT      NOT NULL CHECK (format IN ('json', 'csv', 'pdf')),
+    content    BYTEA     NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, currency, period, format)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS statements;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS payout_batches (
+    id           SERIAL PRIMARY KEY,
+    source_id    INT            NOT NULL REFERENCES users (id),
+    currency     CHAR(3)        NOT NULL,
+    mode         TEXT           NOT NULL CHECK (mode IN ('all_or_nothing', 'best_effort')),
+    status       TEXT           NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'completed', 'failed')),
+    total        NUMERIC(20, 4) NOT NULL,
+    fees         NUMERIC(20, 4) NOT NULL DEFAULT 0,
+    item_count   INT            NOT NULL,
+    succeeded    INT            NOT NULL DEFAULT 0,
+    failed       INT            NOT NULL DEFAULT 0,
+    error        TEXT,
+    created_at   TIMESTAMP      NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMP      NOT NULL DEFAULT now(),
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS payout_batches_open_idx ON payout_batches (id) WHERE status IN ('pending', 'processing');
+
+CREATE TABLE IF NOT EXISTS payout_items (
+    id             SERIAL PRIMARY KEY,
+    batch_id       INT            NOT NULL REFERENCES payout_batches (id),
+    position       INT            NOT NULL,
+    destination_id INT            NOT NULL,
+    amount         NUMERIC(20, 4) NOT NULL CHECK (amount > 0),
+    status         TEXT           NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    transaction_id INT REFERENCES transactions (id),
+    error          TEXT,
+    UNIQUE (batch_id, position)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS payout_items;
+DROP TABLE IF EXISTS payout_batches;
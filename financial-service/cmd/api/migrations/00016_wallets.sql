@@ -0,0 +1,48 @@
+-- +goose Up
+-- A user account is now a wallet: users may own several per currency, one of which is the default that
+-- operations without a wallet id use. Existing accounts become the default "main" wallets
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS name TEXT;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS is_default BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'closed'));
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS closed_at TIMESTAMP;
+
+UPDATE accounts SET name = 'main', is_default = TRUE WHERE user_id IS NOT NULL;
+
+ALTER TABLE accounts ADD CONSTRAINT accounts_wallet_name_check CHECK ((user_id IS NULL) = (name IS NULL));
+ALTER TABLE accounts ADD CONSTRAINT accounts_default_open_check CHECK (NOT is_default OR status = 'open');
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_user_id_currency_key;
+CREATE UNIQUE INDEX IF NOT EXISTS accounts_user_default_idx ON accounts (user_id, currency) WHERE is_default;
+CREATE UNIQUE INDEX IF NOT EXISTS accounts_user_name_idx ON accounts (user_id, currency, lower(name)) WHERE user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS accounts_user_id_idx ON accounts (user_id);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS source_wallet_id INT REFERENCES accounts (id);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS destination_wallet_id INT REFERENCES accounts (id);
+
+UPDATE transactions t SET source_wallet_id = a.id
+FROM accounts a
+WHERE a.user_id = t.useridsource AND a.currency = t.currency AND a.is_default;
+
+UPDATE transactions t SET destination_wallet_id = a.id
+FROM accounts a
+WHERE a.user_id = t.useridendpoint AND a.currency = COALESCE(t.destination_currency, t.currency) AND a.is_default;
+
+CREATE INDEX IF NOT EXISTS transactions_source_wallet_id_idx ON transactions (source_wallet_id);
+CREATE INDEX IF NOT EXISTS transactions_destination_wallet_id_idx ON transactions (destination_wallet_id);
+
+-- +goose Down
+-- Only the default wallets fit the old schema, so this fails while users hold other wallets
+DROP INDEX IF EXISTS transactions_destination_wallet_id_idx;
+DROP INDEX IF EXISTS transactions_source_wallet_id_idx;
+ALTER TABLE transactions DROP COLUMN IF EXISTS destination_wallet_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS source_wallet_id;
+
+DROP INDEX IF EXISTS accounts_user_id_idx;
+DROP INDEX IF EXISTS accounts_user_name_idx;
+DROP INDEX IF EXISTS accounts_user_default_idx;
+ALTER TABLE accounts ADD CONSTRAINT accounts_user_id_currency_key UNIQUE (user_id, currency);
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_default_open_check;
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_wallet_name_check;
+ALTER TABLE accounts DROP COLUMN IF EXISTS closed_at;
+ALTER TABLE accounts DROP COLUMN IF EXISTS status;
+ALTER TABLE accounts DROP COLUMN IF EXISTS is_default;
+ALTER TABLE accounts DROP COLUMN IF EXISTS name;
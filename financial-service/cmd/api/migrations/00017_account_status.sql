@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'frozen_in', 'frozen_out', 'frozen_all', 'closed'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS closed_at TIMESTAMP;
+
+-- Every status change is kept with the reason an admin gave for it
+CREATE TABLE IF NOT EXISTS account_status_changes (
+    id          SERIAL PRIMARY KEY,
+    user_id     INT       NOT NULL REFERENCES users (id),
+    from_status TEXT      NOT NULL,
+    to_status   TEXT      NOT NULL,
+    reason      TEXT      NOT NULL CHECK (reason <> ''),
+    swept_to    INT REFERENCES users (id),
+    created_at  TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS account_status_changes_user_id_idx ON account_status_changes (user_id, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS account_status_changes;
+ALTER TABLE users DROP COLUMN IF EXISTS closed_at;
+ALTER TABLE users DROP COLUMN IF EXISTS status;
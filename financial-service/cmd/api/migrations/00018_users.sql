@@ -0,0 +1,21 @@
+-- +goose Up
+-- Users used to be inserted by hand, so the table may predate 00001 and miss columns; from here on the
+-- migrations own its shape
+ALTER TABLE users ADD COLUMN IF NOT EXISTS balance NUMERIC(20, 2) NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE users ADD COLUMN IF NOT EXISTS name TEXT CHECK (name <> '');
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email TEXT CHECK (email <> '');
+ALTER TABLE users ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+UPDATE users SET created_at = updated_at WHERE created_at > updated_at;
+
+-- Deleted users give their email back, so the same person can sign up again
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_idx ON users (lower(email)) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS users_email_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS created_at;
+ALTER TABLE users DROP COLUMN IF EXISTS email;
+ALTER TABLE users DROP COLUMN IF EXISTS name;
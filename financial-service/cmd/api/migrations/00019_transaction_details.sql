@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS external_reference TEXT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS metadata JSONB CHECK (jsonb_typeof(metadata) = 'object');
+
+CREATE INDEX IF NOT EXISTS transactions_external_reference_idx ON transactions (external_reference)
+    WHERE external_reference IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS transactions_external_reference_idx;
+ALTER TABLE transactions DROP COLUMN IF EXISTS metadata;
+ALTER TABLE transactions DROP COLUMN IF EXISTS external_reference;
+ALTER TABLE transactions DROP COLUMN IF EXISTS description;
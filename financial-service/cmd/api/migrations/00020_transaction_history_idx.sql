@@ -0,0 +1,8 @@
+-- +goose Up
+-- History pages are read per user, newest first, resuming after a (createdat, id) cursor
+CREATE INDEX IF NOT EXISTS transactions_source_history_idx ON transactions (useridsource, createdat DESC, id DESC);
+CREATE INDEX IF NOT EXISTS transactions_endpoint_history_idx ON transactions (useridendpoint, createdat DESC, id DESC);
+
+-- +goose Down
+DROP INDEX IF EXISTS transactions_endpoint_history_idx;
+DROP INDEX IF EXISTS transactions_source_history_idx;
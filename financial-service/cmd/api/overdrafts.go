@@ -0,0 +1,66 @@
+package main
+
+import (
+	"financial-service/data"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"net/http"
+)
+
+// setOverdraftLimit sets how far below zero a user's balance may go
+func (app *Config) setOverdraftLimit(c *gin.Context) {
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	var requestPayload struct {
+		Limit    decimal.Decimal `json:"Limit"`
+		Currency string          `json:"Currency"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+
+	balance, err := app.Repo.SetOverdraftLimit(c.Request.Context(), id, requestPayload.Currency, requestPayload.Limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't set the overdraft limit"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Overdraft limit set",
+		"data":    balance,
+	})
+}
+
+// listOverdrafts reports the users that are currently in overdraft and for how long
+func (app *Config) listOverdrafts(c *gin.Context) {
+	overdrafts, err := app.Repo.ListOverdrafts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   true,
+			"message": "Couldn't get overdrafts",
+		})
+		return
+	}
+
+	if overdrafts == nil {
+		overdrafts = []*data.Overdraft{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Overdrafts found",
+		"data":    overdrafts,
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"financial-service/data"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func (m *MockRepository) SetOverdraftLimit(ctx context.Context, userID int, currency string, limit decimal.Decimal) (*data.Balance, error) {
+	args := m.Called(userID, currency, limit)
+	if balance, ok := args.Get(0).(*data.Balance); ok {
+		return balance, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) ListOverdrafts(ctx context.Context) ([]*data.Overdraft, error) {
+	args := m.Called()
+	if overdrafts, ok := args.Get(0).([]*data.Overdraft); ok {
+		return overdrafts, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// TestSetOverdraftLimit_Success checks that the new limit shows up in the available balance
+func TestSetOverdraftLimit_Success(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	limit := decimal.RequireFromString("500")
+	balance := &data.Balance{
+		UserID:         1,
+		Currency:       "RUB",
+		Ledger:         decimal.NewFromInt(100),
+		OverdraftLimit: decimal.NewFromInt(500),
+		Available:      decimal.NewFromInt(600),
+	}
+
+	mockRepo.On("SetOverdraftLimit", 1, "RUB", limit).Return(balance, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Limit": "500", "Currency": "RUB"})
+
+	httpReq, _ := http.NewRequest("PUT", "/users/1/overdraft", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Error   bool          `json:"error"`
+		Message string        `json:"message"`
+		Data    *data.Balance `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.True(t, response.Data.Available.Equal(decimal.NewFromInt(600)))
+
+	mockRepo.AssertCalled(t, "SetOverdraftLimit", 1, "RUB", limit)
+}
+
+// TestSetOverdraftLimit_RequiresAdmin checks that users can't raise their own limit
+func TestSetOverdraftLimit_RequiresAdmin(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	body, _ := json.Marshal(map[string]interface{}{"Limit": "500"})
+
+	httpReq, _ := http.NewRequest("PUT", "/users/1/overdraft", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	mockRepo.AssertNotCalled(t, "SetOverdraftLimit")
+}
+
+// TestListOverdrafts_Success checks the report of users in overdraft
+func TestListOverdrafts_Success(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	since := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	overdrafts := []*data.Overdraft{
+		{UserID: 3, Currency: "RUB", Balance: decimal.NewFromInt(-120), OverdraftLimit: decimal.NewFromInt(500), NegativeSince: since, NegativeForSeconds: 86400},
+	}
+
+	mockRepo.On("ListOverdrafts").Return(overdrafts, nil)
+
+	httpReq, _ := http.NewRequest("GET", "/overdrafts", nil)
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Error   bool              `json:"error"`
+		Message string            `json:"message"`
+		Data    []*data.Overdraft `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Len(t, response.Data, 1)
+	assert.Equal(t, 3, response.Data[0].UserID)
+	assert.Equal(t, int64(86400), response.Data[0].NegativeForSeconds)
+	assert.Equal(t, since, response.Data[0].NegativeSince)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"financial-service/data"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"log"
+	"net/http"
+	"time"
+)
+
+// syncPayoutItems is the largest batch that is executed while the client waits. Larger batches are
+// accepted and executed in the background, and their progress is polled with getPayoutBatch
+const syncPayoutItems = 100
+
+// createPayoutBatch pays a list of users from one source account. The whole batch is rolled back when a
+// payout is rejected if AllOrNothing is set, otherwise the rejected payouts are reported and the rest go
+// through. Only the holder of the source account and admins can submit a batch
+func (app *Config) createPayoutBatch(c *gin.Context) {
+	var requestPayload struct {
+		IDSource     int    `json:"IdSource"`
+		Currency     string `json:"Currency"`
+		AllOrNothing bool   `json:"AllOrNothing"`
+		Items        []struct {
+			IDEndpoint int             `json:"IdEndpoint"`
+			Amount     decimal.Decimal `json:"Amount"`
+		} `json:"Items"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+	if !app.authorizeAccountHolder(c, requestPayload.IDSource) {
+		return
+	}
+
+	batch := &data.PayoutBatch{
+		SourceID: requestPayload.IDSource,
+		Currency: requestPayload.Currency,
+		Mode:     data.PayoutBestEffort,
+		Items:    make([]*data.PayoutItem, 0, len(requestPayload.Items)),
+	}
+	if requestPayload.AllOrNothing {
+		batch.Mode = data.PayoutAllOrNothing
+	}
+	for _, item := range requestPayload.Items {
+		batch.Items = append(batch.Items, &data.PayoutItem{DestinationID: item.IDEndpoint, Amount: item.Amount})
+	}
+
+	batch, err := app.Repo.CreatePayoutBatch(c.Request.Context(), batch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't create the payout batch"),
+		})
+		return
+	}
+
+	if batch.ItemCount > syncPayoutItems {
+		go app.processPayoutBatch(batch.ID)
+		writePayoutBatch(c, batch)
+		return
+	}
+
+	processed, err := app.Repo.ProcessPayoutBatch(c.Request.Context(), batch.ID)
+	if err != nil {
+		// The batch stays open and is picked up by processPayouts
+		log.Printf("Couldn't process payout batch %d: %v\n", batch.ID, err)
+		writePayoutBatch(c, batch)
+		return
+	}
+
+	writePayoutBatch(c, processed)
+}
+
+// writePayoutBatch answers with a finished batch, or with 202 and the location to poll while it is open
+func writePayoutBatch(c *gin.Context, batch *data.PayoutBatch) {
+	switch batch.Status {
+	case data.PayoutCompleted:
+		c.JSON(http.StatusOK, gin.H{
+			"error":   false,
+			"message": "Payout batch completed",
+			"data":    batch,
+		})
+	case data.PayoutFailed:
+		c.JSON(http.StatusOK, gin.H{
+			"error":   false,
+			"message": "Payout batch failed",
+			"data":    batch,
+		})
+	default:
+		c.Header("Location", fmt.Sprintf("/transfers/batch/%d", batch.ID))
+		c.JSON(http.StatusAccepted, gin.H{
+			"error":   false,
+			"message": "Payout batch accepted",
+			"data":    batch,
+		})
+	}
+}
+
+// getPayoutBatch returns a payout batch with the result of each of its payouts. Only the holder of the
+// source account and admins can read it; anyone else is told it doesn't exist
+func (app *Config) getPayoutBatch(c *gin.Context) {
+	id, ok := pathID(c, "batch")
+	if !ok {
+		return
+	}
+
+	admin := app.isAdmin(c)
+	caller, authenticated := callerID(c)
+	if !admin && !authenticated {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   true,
+			"message": "Authorization required",
+		})
+		return
+	}
+
+	batch, err := app.Repo.GetPayoutBatch(c.Request.Context(), id)
+	if err == nil && !admin && batch.SourceID != caller {
+		err = fmt.Errorf("payout batch %d: %w", id, data.ErrPayoutBatchNotFound)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, data.ErrPayoutBatchNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't get the payout batch"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Payout batch found",
+		"data":    batch,
+	})
+}
+
+// processPayoutBatch executes a batch accepted for background processing. Batches it can't finish are
+// picked up again by processPayouts
+func (app *Config) processPayoutBatch(id int) {
+	batch, err := app.Repo.ProcessPayoutBatch(context.Background(), id)
+	if err != nil {
+		log.Printf("Couldn't process payout batch %d: %v\n", id, err)
+		return
+	}
+
+	log.Printf("Payout batch %d is %s: %d succeeded, %d failed\n", id, batch.Status, batch.Succeeded, batch.Failed)
+}
+
+// processPayouts periodically executes the batches that are still open, such as those interrupted by a restart
+func (app *Config) processPayouts(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		processed, err := app.Repo.ProcessPendingPayouts(context.Background())
+		if err != nil {
+			log.Printf("Couldn't process payout batches: %v\n", err)
+		}
+		if processed > 0 {
+			log.Printf("Processed %d payout batches\n", processed)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"financial-service/data"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (m *MockRepository) CreatePayoutBatch(ctx context.Context, batch *data.PayoutBatch) (*data.PayoutBatch, error) {
+	args := m.Called(batch)
+	if created, ok := args.Get(0).(*data.PayoutBatch); ok {
+		return created, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) GetPayoutBatch(ctx context.Context, id int) (*data.PayoutBatch, error) {
+	args := m.Called(id)
+	if batch, ok := args.Get(0).(*data.PayoutBatch); ok {
+		return batch, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) ProcessPayoutBatch(ctx context.Context, id int) (*data.PayoutBatch, error) {
+	args := m.Called(id)
+	if batch, ok := args.Get(0).(*data.PayoutBatch); ok {
+		return batch, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) ProcessPendingPayouts(ctx context.Context) (int, error) {
+	args := m.Called()
+	return args.Int(0), args.Error(1)
+}
+
+// payoutPayload builds a request body paying count users amount each
+func payoutPayload(count int, amount string, allOrNothing bool) []byte {
+	items := make([]map[string]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		items = append(items, map[string]interface{}{"IdEndpoint": i + 2, "Amount": amount})
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"IdSource":     1,
+		"Currency":     "RUB",
+		"AllOrNothing": allOrNothing,
+		"Items":        items,
+	})
+	return body
+}
+
+// TestCreatePayoutBatch_Sync checks that small batches run while the client waits and report each payout
+func TestCreatePayoutBatch_Sync(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	created := &data.PayoutBatch{ID: 7, SourceID: 1, Currency: "RUB", Mode: data.PayoutBestEffort, Status: data.PayoutPending, ItemCount: 2}
+	reason := "destination user not found"
+	transactionID := 40
+	processed := &data.PayoutBatch{
+		ID: 7, SourceID: 1, Currency: "RUB", Mode: data.PayoutBestEffort, Status: data.PayoutCompleted, ItemCount: 2, Succeeded: 1, Failed: 1,
+		Items: []*data.PayoutItem{
+			{ID: 1, Position: 0, DestinationID: 2, Amount: decimal.NewFromInt(10), Status: data.PayoutItemSucceeded, TransactionID: &transactionID},
+			{ID: 2, Position: 1, DestinationID: 3, Amount: decimal.NewFromInt(10), Status: data.PayoutItemFailed, Error: &reason},
+		},
+	}
+
+	mockRepo.On("CreatePayoutBatch", mock.MatchedBy(func(b *data.PayoutBatch) bool {
+		return b.SourceID == 1 && b.Mode == data.PayoutBestEffort && len(b.Items) == 2 &&
+			b.Items[1].DestinationID == 3 && b.Items[1].Amount.Equal(decimal.NewFromInt(10))
+	})).Return(created, nil)
+	mockRepo.On("ProcessPayoutBatch", 7).Return(processed, nil)
+
+	httpReq, _ := http.NewRequest("POST", "/transfers/batch", bytes.NewBuffer(payoutPayload(2, "10", false)))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-User-Id", "1")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Error   bool              `json:"error"`
+		Message string            `json:"message"`
+		Data    *data.PayoutBatch `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, "Payout batch completed", response.Message)
+	assert.Equal(t, 1, response.Data.Failed)
+	assert.Equal(t, data.PayoutItemFailed, response.Data.Items[1].Status)
+	assert.Equal(t, reason, *response.Data.Items[1].Error)
+}
+
+// TestCreatePayoutBatch_AllOrNothingFailed checks that a rolled back batch is reported as failed
+func TestCreatePayoutBatch_AllOrNothingFailed(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	reason := "item 1: limit exceeded"
+	created := &data.PayoutBatch{ID: 8, Mode: data.PayoutAllOrNothing, Status: data.PayoutPending, ItemCount: 2}
+	processed := &data.PayoutBatch{ID: 8, Mode: data.PayoutAllOrNothing, Status: data.PayoutFailed, ItemCount: 2, Failed: 2, Error: &reason}
+
+	mockRepo.On("CreatePayoutBatch", mock.MatchedBy(func(b *data.PayoutBatch) bool {
+		return b.Mode == data.PayoutAllOrNothing
+	})).Return(created, nil)
+	mockRepo.On("ProcessPayoutBatch", 8).Return(processed, nil)
+
+	httpReq, _ := http.NewRequest("POST", "/transfers/batch", bytes.NewBuffer(payoutPayload(2, "10", true)))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-User-Id", "1")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Payout batch failed")
+	assert.Contains(t, resp.Body.String(), reason)
+}
+
+// TestCreatePayoutBatch_Async checks that large batches are accepted at once and processed in the background
+func TestCreatePayoutBatch_Async(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	count := syncPayoutItems + 1
+	created := &data.PayoutBatch{ID: 9, Status: data.PayoutPending, ItemCount: count}
+	processed := make(chan struct{})
+
+	mockRepo.On("CreatePayoutBatch", mock.Anything).Return(created, nil)
+	mockRepo.On("ProcessPayoutBatch", 9).Run(func(mock.Arguments) { close(processed) }).
+		Return(&data.PayoutBatch{ID: 9, Status: data.PayoutCompleted, ItemCount: count, Succeeded: count}, nil)
+
+	httpReq, _ := http.NewRequest("POST", "/transfers/batch", bytes.NewBuffer(payoutPayload(count, "1", false)))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-User-Id", "1")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+	assert.Equal(t, "/transfers/batch/9", resp.Header().Get("Location"))
+	assert.Contains(t, resp.Body.String(), "Payout batch accepted")
+
+	select {
+	case <-processed:
+	case <-time.After(time.Second):
+		t.Fatal("batch was not processed in the background")
+	}
+}
+
+// TestCreatePayoutBatch_InsufficientBalance checks that a batch the source can't cover is refused up front
+func TestCreatePayoutBatch_InsufficientBalance(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("CreatePayoutBatch", mock.Anything).Return(nil, fmt.Errorf("%w: batch needs 20 RUB", data.ErrInsufficientBalance))
+
+	httpReq, _ := http.NewRequest("POST", "/transfers/batch", bytes.NewBuffer(payoutPayload(2, "10", false)))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-User-Id", "1")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Insufficient balance on the source user")
+	mockRepo.AssertNotCalled(t, "ProcessPayoutBatch", mock.Anything)
+}
+
+// TestGetPayoutBatch_NotFound checks polling an unknown batch
+func TestGetPayoutBatch_NotFound(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("GetPayoutBatch", 99).Return(nil, fmt.Errorf("payout batch 99: %w", data.ErrPayoutBatchNotFound))
+
+	httpReq, _ := http.NewRequest("GET", "/transfers/batch/99", nil)
+	httpReq.Header.Set("X-User-Id", "1")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Payout batch doesn't exist")
+}
+
+// TestPayoutBatch_Authorization checks that only the holder of the source account and admins can submit
+// or read a batch
+func TestPayoutBatch_Authorization(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	mockRepo.On("GetPayoutBatch", 7).Return(&data.PayoutBatch{ID: 7, SourceID: 1, Status: data.PayoutCompleted}, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   []byte
+		header string
+		value  string
+		status int
+	}{
+		{"create anonymously", "POST", "/transfers/batch", payoutPayload(2, "10", false), "", "", http.StatusUnauthorized},
+		{"create from another user", "POST", "/transfers/batch", payoutPayload(2, "10", false), "X-User-Id", "2", http.StatusForbidden},
+		{"read anonymously", "GET", "/transfers/batch/7", nil, "", "", http.StatusUnauthorized},
+		{"read another user's batch", "GET", "/transfers/batch/7", nil, "X-User-Id", "2", http.StatusNotFound},
+		{"read as the source", "GET", "/transfers/batch/7", nil, "X-User-Id", "1", http.StatusOK},
+		{"read as admin", "GET", "/transfers/batch/7", nil, "Authorization", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		httpReq, _ := http.NewRequest(tt.method, tt.path, bytes.NewBuffer(tt.body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		if tt.header != "" {
+			httpReq.Header.Set(tt.header, tt.value)
+		}
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, httpReq)
+
+		assert.Equal(t, tt.status, resp.Code, tt.name)
+	}
+	mockRepo.AssertNotCalled(t, "CreatePayoutBatch", mock.Anything)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"financial-service/data"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log"
+	"net/http"
+)
+
+// reconcile compares every user's cached balances with the ledger and reports the accounts that drifted.
+// With Repair set the mismatches are corrected with adjustment transactions
+func (app *Config) reconcile(c *gin.Context) {
+	var requestPayload struct {
+		Repair    bool `json:"Repair"`
+		BatchSize int  `json:"BatchSize"`
+	}
+
+	// An empty body runs a report-only reconciliation
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&requestPayload); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   true,
+				"message": "Invalid JSON",
+			})
+			return
+		}
+	}
+
+	report, err := app.Repo.Reconcile(c.Request.Context(), &data.ReconcileOptions{
+		Repair:    requestPayload.Repair,
+		BatchSize: requestPayload.BatchSize,
+	})
+	if err != nil {
+		log.Printf("Reconciliation failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   true,
+			"message": "Couldn't reconcile balances",
+		})
+		return
+	}
+
+	message := "Balances reconciled"
+	if len(report.Mismatches) > report.Repaired {
+		message = "Balance mismatches found"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": message,
+		"data":    report,
+	})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"financial-service/data"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func (m *MockRepository) Reconcile(ctx context.Context, opts *data.ReconcileOptions) (*data.ReconciliationReport, error) {
+	args := m.Called(*opts)
+	if report, ok := args.Get(0).(*data.ReconciliationReport); ok {
+		return report, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// TestReconcile_ReportsMismatches checks that a plain run reports drift without repairing it
+func TestReconcile_ReportsMismatches(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	report := &data.ReconciliationReport{
+		Scanned: 3,
+		Mismatches: []*data.BalanceMismatch{
+			{
+				UserID:         2,
+				AccountID:      5,
+				Currency:       "RUB",
+				Expected:       decimal.NewFromInt(100),
+				AccountBalance: decimal.NewFromInt(100),
+				UserBalance:    decimal.NewNullDecimal(decimal.NewFromInt(90)),
+				Difference:     decimal.NewFromInt(-10),
+			},
+		},
+	}
+
+	mockRepo.On("Reconcile", data.ReconcileOptions{}).Return(report, nil)
+
+	httpReq, _ := http.NewRequest("POST", "/reconcile", nil)
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Error   bool                       `json:"error"`
+		Message string                     `json:"message"`
+		Data    *data.ReconciliationReport `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, "Balance mismatches found", response.Message)
+	assert.Equal(t, 3, response.Data.Scanned)
+	assert.Len(t, response.Data.Mismatches, 1)
+	assert.True(t, response.Data.Mismatches[0].Difference.Equal(decimal.NewFromInt(-10)))
+	assert.False(t, response.Data.Mismatches[0].Repaired)
+}
+
+// TestReconcile_Repair checks that the repair flag and batch size reach the repository
+func TestReconcile_Repair(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	transactionID := 12
+	report := &data.ReconciliationReport{
+		Scanned:  1,
+		Repair:   true,
+		Repaired: 1,
+		Mismatches: []*data.BalanceMismatch{
+			{UserID: 2, AccountID: 5, Currency: "USD", Difference: decimal.NewFromInt(7), Repaired: true, TransactionID: &transactionID},
+		},
+	}
+
+	opts := data.ReconcileOptions{Repair: true, BatchSize: 100}
+	mockRepo.On("Reconcile", opts).Return(report, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Repair": true, "BatchSize": 100})
+
+	httpReq, _ := http.NewRequest("POST", "/reconcile", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Balances reconciled")
+	mockRepo.AssertCalled(t, "Reconcile", opts)
+}
+
+// TestReconcile_RequiresAdmin checks that only admins can scan or repair balances
+func TestReconcile_RequiresAdmin(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	httpReq, _ := http.NewRequest("POST", "/reconcile", bytes.NewBufferString(`{"Repair":true}`))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	mockRepo.AssertNotCalled(t, "Reconcile")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"financial-service/data"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"net/http"
+	"strconv"
+)
+
+// reverseTransaction refunds everything that is left of a transaction
+func (app *Config) reverseTransaction(c *gin.Context) {
+	var requestPayload struct {
+		Force bool `json:"Force"`
+	}
+
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&requestPayload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   true,
+				"message": "Invalid JSON",
+			})
+			return
+		}
+	}
+
+	app.refund(c, decimal.Zero, requestPayload.Force, "Transaction reversed")
+}
+
+// refundTransaction refunds part of a transaction
+func (app *Config) refundTransaction(c *gin.Context) {
+	var requestPayload struct {
+		Amount decimal.Decimal `json:"Amount"`
+		Force  bool            `json:"Force"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+	if !requestPayload.Amount.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Amount must be positive",
+		})
+		return
+	}
+
+	app.refund(c, requestPayload.Amount, requestPayload.Force, "Transaction refunded")
+}
+
+// refund runs a refund of amount for the transaction in the path. The routes are admin only, so the
+// caller may also force it
+func (app *Config) refund(c *gin.Context, amount decimal.Decimal, force bool, message string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid transaction id",
+		})
+		return
+	}
+
+	refund, err := app.Repo.Refund(c.Request.Context(), &data.RefundRequest{
+		TransactionID: id,
+		Amount:        amount,
+		Force:         force,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't refund the transaction"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": message,
+		"data":    refund,
+	})
+}
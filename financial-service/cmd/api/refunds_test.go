@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"financial-service/data"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (m *MockRepository) Refund(ctx context.Context, req *data.RefundRequest) (*data.Transactions, error) {
+	args := m.Called(req)
+	if refund, ok := args.Get(0).(*data.Transactions); ok {
+		return refund, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// TestReverseTransaction_Success checks that a reversal refunds the whole remaining amount
+func TestReverseTransaction_Success(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	req := &data.RefundRequest{TransactionID: 42, Amount: decimal.Zero}
+	refund := &data.Transactions{ID: 43, Type: data.TransactionRefund, ParentID: intPtr(42), Amount: decimal.NewFromInt(100), Currency: "RUB"}
+
+	mockRepo.On("Refund", req).Return(refund, nil)
+
+	httpReq, _ := http.NewRequest("POST", "/transactions/42/reverse", nil)
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Error   bool               `json:"error"`
+		Message string             `json:"message"`
+		Data    *data.Transactions `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, false, response.Error)
+	assert.Equal(t, "Transaction reversed", response.Message)
+	assert.Equal(t, 43, response.Data.ID)
+	assert.Equal(t, 42, *response.Data.ParentID)
+
+	mockRepo.AssertCalled(t, "Refund", req)
+}
+
+// TestRefundTransaction_ExceedsOriginal checks the error when the refund is larger than what's left
+func TestRefundTransaction_ExceedsOriginal(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	req := &data.RefundRequest{TransactionID: 42, Amount: decimal.RequireFromString("60")}
+
+	mockRepo.On("Refund", req).Return(nil, fmt.Errorf("%w: only 50 is left", data.ErrRefundExceedsOriginal))
+
+	body, _ := json.Marshal(map[string]interface{}{"Amount": "60"})
+
+	httpReq, _ := http.NewRequest("POST", "/transactions/42/refund", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, true, response["error"])
+	assert.Equal(t, "Refund exceeds the amount left on the transaction", response["message"])
+}
+
+// TestRefundTransaction_RequiresAdmin checks that only admins can reverse or refund a transaction
+func TestRefundTransaction_RequiresAdmin(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	body, _ := json.Marshal(map[string]interface{}{"Amount": "10"})
+
+	for _, path := range []string{"/transactions/42/reverse", "/transactions/42/refund"} {
+		for _, token := range []string{"", "Bearer wrong"} {
+			httpReq, _ := http.NewRequest("POST", path, bytes.NewBuffer(body))
+			httpReq.Header.Set("Content-Type", "application/json")
+			httpReq.Header.Set("X-User-Id", "1")
+			if token != "" {
+				httpReq.Header.Set("Authorization", token)
+			}
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, httpReq)
+
+			assert.Equal(t, http.StatusUnauthorized, resp.Code, path)
+		}
+	}
+	mockRepo.AssertNotCalled(t, "Refund", mock.Anything)
+}
+
+// TestRefundTransaction_ForceByAdmin checks that an admin's force flag reaches the repository
+func TestRefundTransaction_ForceByAdmin(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	req := &data.RefundRequest{TransactionID: 42, Amount: decimal.RequireFromString("10"), Force: true}
+
+	mockRepo.On("Refund", req).Return(&data.Transactions{ID: 43}, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Amount": "10", "Force": true})
+
+	httpReq, _ := http.NewRequest("POST", "/transactions/42/refund", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockRepo.AssertCalled(t, "Refund", req)
+}
+
+// TestRefundTransaction_InvalidID checks that a non-numeric transaction id is rejected
+func TestRefundTransaction_InvalidID(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	httpReq, _ := http.NewRequest("POST", "/transactions/abc/reverse", nil)
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	mockRepo.AssertNotCalled(t, "Refund", mock.Anything)
+}
+
+// TestRefundTransaction_NonPositiveAmount checks that a refund of nothing is refused with its own message
+func TestRefundTransaction_NonPositiveAmount(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	for _, amount := range []string{"0", "-5"} {
+		body, _ := json.Marshal(map[string]interface{}{"Amount": amount})
+
+		httpReq, _ := http.NewRequest("POST", "/transactions/1/refund", bytes.NewBuffer(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer secret")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, httpReq)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code, amount)
+		assert.Contains(t, resp.Body.String(), "Amount must be positive", amount)
+	}
+	mockRepo.AssertNotCalled(t, "Refund", mock.Anything)
+}
@@ -7,9 +7,9 @@ import (
 func (app *Config) routes(router *gin.Engine) {
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Link")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token, Idempotency-Key")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Link, Location, Idempotent-Replayed, Deprecation")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Max-Age", "300")
 
@@ -27,7 +27,77 @@ func (app *Config) routes(router *gin.Engine) {
 		})
 	})
 
-	router.POST("/depositMoney", app.depositMoney)
-	router.POST("/transferMoney", app.transferMoney)
-	router.GET("/getLastTransactions", app.GetLastTransactions)
+	v1 := router.Group("/v1")
+	v1.POST("/deposits", app.depositMoney)
+	v1.POST("/withdrawals", app.withdrawMoney)
+	v1.POST("/transfers", app.transferMoney)
+	v1.GET("/transactions/:id", app.getTransaction)
+	v1.GET("/users/:id/transactions", app.requireAccountHolder, app.getTransactionHistory)
+
+	// Legacy routes, kept until clients have moved to /v1
+	router.POST("/depositMoney", deprecated("/v1/deposits"), app.depositMoney)
+	router.POST("/withdrawMoney", deprecated("/v1/withdrawals"), app.withdrawMoney)
+	router.POST("/transferMoney", deprecated("/v1/transfers"), app.transferMoney)
+	router.GET("/getLastTransactions", deprecated(""), app.GetLastTransactions)
+
+	router.POST("/fx/rates", app.requireAdmin, app.setRates)
+	router.POST("/fx/quotes", app.createQuote)
+
+	router.POST("/transfers/batch", app.createPayoutBatch)
+	router.GET("/transfers/batch/:id", app.getPayoutBatch)
+
+	router.GET("/transactions/:id", app.getTransaction)
+	router.POST("/transactions/:id/reverse", app.requireAdmin, app.reverseTransaction)
+	router.POST("/transactions/:id/refund", app.requireAdmin, app.refundTransaction)
+
+	router.POST("/holds", app.authorizeHold)
+	router.POST("/holds/:id/capture", app.captureHold)
+	router.POST("/holds/:id/void", app.voidHold)
+
+	router.POST("/schedules", app.createSchedule)
+	router.GET("/schedules/:id", app.getSchedule)
+	router.POST("/schedules/:id/cancel", app.cancelSchedule)
+
+	router.POST("/fees/quote", app.quoteFee)
+	router.GET("/fees/rules", app.requireAdmin, app.listFeeRules)
+	router.POST("/fees/rules", app.requireAdmin, app.createFeeRule)
+	router.DELETE("/fees/rules/:id", app.requireAdmin, app.deactivateFeeRule)
+
+	router.POST("/users", app.createUser)
+	router.GET("/users", app.requireAdmin, app.listUsers)
+	router.GET("/users/:id", app.requireAccountHolder, app.getUser)
+	router.PATCH("/users/:id", app.requireAccountHolder, app.updateUser)
+	router.DELETE("/users/:id", app.requireAdmin, app.deleteUser)
+
+	router.GET("/users/:id/transactions", app.requireAccountHolder, app.getTransactionHistory)
+	router.POST("/users/:id/wallets", app.requireAccountHolder, app.createWallet)
+	router.GET("/users/:id/wallets", app.requireAccountHolder, app.listWallets)
+	router.POST("/wallets/:id/rename", app.requireWalletHolder, app.renameWallet)
+	router.POST("/wallets/:id/close", app.requireWalletHolder, app.closeWallet)
+
+	router.GET("/users/:id/balance", app.requireAccountHolder, app.getBalance)
+	router.GET("/balances", app.requireAdmin, app.getBalances)
+	router.GET("/users/:id/statements/:period", app.requireAccountHolder, app.getStatement)
+	router.PUT("/users/:id/overdraft", app.requireAdmin, app.setOverdraftLimit)
+	router.PUT("/users/:id/status", app.requireAdmin, app.setAccountStatus)
+	router.POST("/users/:id/close", app.requireAdmin, app.closeAccount)
+	router.GET("/overdrafts", app.requireAdmin, app.listOverdrafts)
+
+	router.PUT("/limits", app.requireAdmin, app.setGlobalSpendingLimit)
+	router.PUT("/users/:id/limits", app.requireAdmin, app.setUserSpendingLimit)
+
+	router.POST("/reconcile", app.requireAdmin, app.reconcile)
+}
+
+// deprecated marks the responses of a route that is kept only as an alias with a Deprecation header and,
+// when there is a direct replacement, a Link to it
+func deprecated(successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		if successor != "" {
+			c.Header("Link", "<"+successor+">; rel=\"successor-version\"")
+		}
+
+		c.Next()
+	}
 }
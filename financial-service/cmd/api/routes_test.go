@@ -23,8 +23,8 @@ func TestCORS(t *testing.T) {
 	router.ServeHTTP(resp, req)
 
 	assert.Equal(t, "*", resp.Header().Get("Access-Control-Allow-Origin"))
-	assert.Equal(t, "GET, POST, PUT, DELETE, OPTIONS", resp.Header().Get("Access-Control-Allow-Methods"))
-	assert.Equal(t, "Accept, Authorization, Content-Type, X-CSRF-Token", resp.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "GET, POST, PUT, PATCH, DELETE, OPTIONS", resp.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Accept, Authorization, Content-Type, X-CSRF-Token, Idempotency-Key", resp.Header().Get("Access-Control-Allow-Headers"))
 	assert.Equal(t, "true", resp.Header().Get("Access-Control-Allow-Credentials"))
 	assert.Equal(t, "300", resp.Header().Get("Access-Control-Max-Age"))
 
@@ -53,7 +53,7 @@ func TestPingRoute(t *testing.T) {
 // TestDepositMoneyRoute checks routes for correct handling
 func TestDepositMoneyRoute(t *testing.T) {
 	router := gin.Default()
-	app := &Config{}
+	app := &Config{Repo: testApp.Repo}
 	app.routes(router)
 
 	payload := map[string]interface{}{
@@ -71,10 +71,31 @@ func TestDepositMoneyRoute(t *testing.T) {
 	assert.Equal(t, 200, resp.Code)
 }
 
+// TestWithdrawMoneyRoute checks routes for correct handling
+func TestWithdrawMoneyRoute(t *testing.T) {
+	router := gin.Default()
+	app := &Config{Repo: testApp.Repo}
+	app.routes(router)
+
+	payload := map[string]interface{}{
+		"Id":     123,
+		"Amount": "10.00",
+	}
+	body, _ := json.Marshal(payload)
+
+	req, _ := http.NewRequest("POST", "/withdrawMoney", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, 200, resp.Code)
+}
+
 // TestTransferMoneyRoute checks routes for correct handling
 func TestTransferMoneyRoute(t *testing.T) {
 	router := gin.Default()
-	app := &Config{}
+	app := &Config{Repo: testApp.Repo}
 	app.routes(router)
 
 	payload := map[string]interface{}{
@@ -96,13 +117,85 @@ func TestTransferMoneyRoute(t *testing.T) {
 // TestGetLastTransactionsRoute checks routes for correct handling
 func TestGetLastTransactionsRoute(t *testing.T) {
 	router := gin.Default()
-	app := &Config{}
+	app := &Config{Repo: testApp.Repo}
 	app.routes(router)
 
-	req, _ := http.NewRequest("GET", "/getLastTransactions?id=123", nil)
+	body, _ := json.Marshal(map[string]interface{}{"Id": 123})
+
+	req, _ := http.NewRequest("GET", "/getLastTransactions", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-Id", "123")
 	resp := httptest.NewRecorder()
 
 	router.ServeHTTP(resp, req)
 
 	assert.Equal(t, 200, resp.Code)
 }
+
+// TestV1Routes checks that the versioned routes are served without deprecation headers
+func TestV1Routes(t *testing.T) {
+	router := gin.Default()
+	app := &Config{Repo: testApp.Repo}
+	app.routes(router)
+
+	tests := []struct {
+		method  string
+		path    string
+		payload map[string]interface{}
+	}{
+		{"POST", "/v1/deposits", map[string]interface{}{"Id": 123, "Amount": "100.50"}},
+		{"POST", "/v1/withdrawals", map[string]interface{}{"Id": 123, "Amount": "10.00"}},
+		{"POST", "/v1/transfers", map[string]interface{}{"IdSource": 123, "IdEndpoint": 456, "Amount": "50.25"}},
+		{"GET", "/v1/transactions/1", nil},
+		{"GET", "/v1/users/123/transactions", nil},
+	}
+	for _, tt := range tests {
+		var body bytes.Buffer
+		if tt.payload != nil {
+			_ = json.NewEncoder(&body).Encode(tt.payload)
+		}
+
+		req, _ := http.NewRequest(tt.method, tt.path, &body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-Id", "123")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, 200, resp.Code, tt.path)
+		assert.Empty(t, resp.Header().Get("Deprecation"), tt.path)
+	}
+}
+
+// TestLegacyRoutesDeprecated checks that the legacy routes still work and point to their replacements
+func TestLegacyRoutesDeprecated(t *testing.T) {
+	router := gin.Default()
+	app := &Config{Repo: testApp.Repo}
+	app.routes(router)
+
+	tests := []struct {
+		method    string
+		path      string
+		payload   map[string]interface{}
+		successor string
+	}{
+		{"POST", "/depositMoney", map[string]interface{}{"Id": 123, "Amount": "100.50"}, `</v1/deposits>; rel="successor-version"`},
+		{"POST", "/withdrawMoney", map[string]interface{}{"Id": 123, "Amount": "10.00"}, `</v1/withdrawals>; rel="successor-version"`},
+		{"POST", "/transferMoney", map[string]interface{}{"IdSource": 123, "IdEndpoint": 456, "Amount": "50.25"}, `</v1/transfers>; rel="successor-version"`},
+		{"GET", "/getLastTransactions", map[string]interface{}{"Id": 123}, ""},
+	}
+	for _, tt := range tests {
+		body, _ := json.Marshal(tt.payload)
+
+		req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-Id", "123")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, 200, resp.Code, tt.path)
+		assert.Equal(t, "true", resp.Header().Get("Deprecation"), tt.path)
+		assert.Equal(t, tt.successor, resp.Header().Get("Link"), tt.path)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"financial-service/data"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"log"
+	"net/http"
+	"time"
+)
+
+// createSchedule sets up a standing order from one user to another
+func (app *Config) createSchedule(c *gin.Context) {
+	var requestPayload struct {
+		Amount                decimal.Decimal `json:"Amount"`
+		IDSource              int             `json:"IdSource"`
+		IDEndpoint            int             `json:"IdEndpoint"`
+		Currency              string          `json:"Currency"`
+		Frequency             string          `json:"Frequency"`
+		IntervalSeconds       int64           `json:"IntervalSeconds"`
+		DayOfMonth            int             `json:"DayOfMonth"`
+		StartAt               time.Time       `json:"StartAt"`
+		EndAt                 *time.Time      `json:"EndAt"`
+		MaxOccurrences        int             `json:"MaxOccurrences"`
+		OnInsufficientBalance string          `json:"OnInsufficientBalance"`
+		MaxRetries            int             `json:"MaxRetries"`
+		RetryIntervalSeconds  int64           `json:"RetryIntervalSeconds"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+
+	schedule, err := app.Repo.CreateSchedule(c.Request.Context(), &data.Schedule{
+		UserIDSource:          requestPayload.IDSource,
+		UserIDEndpoint:        requestPayload.IDEndpoint,
+		Amount:                requestPayload.Amount,
+		Currency:              requestPayload.Currency,
+		Frequency:             data.ScheduleFrequency(requestPayload.Frequency),
+		IntervalSeconds:       requestPayload.IntervalSeconds,
+		DayOfMonth:            requestPayload.DayOfMonth,
+		StartAt:               requestPayload.StartAt,
+		EndAt:                 requestPayload.EndAt,
+		MaxOccurrences:        requestPayload.MaxOccurrences,
+		OnInsufficientBalance: data.InsufficientBalancePolicy(requestPayload.OnInsufficientBalance),
+		MaxRetries:            requestPayload.MaxRetries,
+		RetryIntervalSeconds:  requestPayload.RetryIntervalSeconds,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't create the schedule"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Schedule created",
+		"data":    schedule,
+	})
+}
+
+// getSchedule returns a schedule with the outcome of its latest runs
+func (app *Config) getSchedule(c *gin.Context) {
+	id, ok := pathID(c, "schedule")
+	if !ok {
+		return
+	}
+
+	schedule, err := app.Repo.GetSchedule(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, data.ErrScheduleNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't get the schedule"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Schedule found",
+		"data":    schedule,
+	})
+}
+
+// cancelSchedule stops a standing order
+func (app *Config) cancelSchedule(c *gin.Context) {
+	id, ok := pathID(c, "schedule")
+	if !ok {
+		return
+	}
+
+	schedule, err := app.Repo.CancelSchedule(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't cancel the schedule"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Schedule cancelled",
+		"data":    schedule,
+	})
+}
+
+// runSchedules periodically executes the schedules that are due
+func (app *Config) runSchedules(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		runs, err := app.Repo.RunDueSchedules(context.Background(), now)
+		if err != nil {
+			log.Printf("Couldn't run schedules: %v\n", err)
+		}
+		if runs > 0 {
+			log.Printf("Ran %d scheduled transfers\n", runs)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"financial-service/data"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (m *MockRepository) CreateSchedule(ctx context.Context, s *data.Schedule) (*data.Schedule, error) {
+	args := m.Called(s)
+	if schedule, ok := args.Get(0).(*data.Schedule); ok {
+		return schedule, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) GetSchedule(ctx context.Context, id int) (*data.Schedule, error) {
+	args := m.Called(id)
+	if schedule, ok := args.Get(0).(*data.Schedule); ok {
+		return schedule, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) CancelSchedule(ctx context.Context, id int) (*data.Schedule, error) {
+	args := m.Called(id)
+	if schedule, ok := args.Get(0).(*data.Schedule); ok {
+		return schedule, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// TestCreateSchedule_Success checks that the recurrence rule and policy reach the repository
+func TestCreateSchedule_Success(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	start := time.Date(2024, time.June, 1, 9, 0, 0, 0, time.UTC)
+	req := &data.Schedule{
+		UserIDSource:          1,
+		UserIDEndpoint:        2,
+		Amount:                decimal.RequireFromString("25"),
+		Currency:              "RUB",
+		Frequency:             data.ScheduleMonthly,
+		DayOfMonth:            5,
+		StartAt:               start,
+		MaxOccurrences:        12,
+		OnInsufficientBalance: data.PolicyRetry,
+		MaxRetries:            3,
+	}
+	created := *req
+	created.ID = 11
+	created.Status = data.ScheduleActive
+	created.NextRunAt = time.Date(2024, time.June, 5, 9, 0, 0, 0, time.UTC)
+
+	mockRepo.On("CreateSchedule", req).Return(&created, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"IdSource":              1,
+		"IdEndpoint":            2,
+		"Amount":                "25",
+		"Currency":              "RUB",
+		"Frequency":             "monthly",
+		"DayOfMonth":            5,
+		"StartAt":               start,
+		"MaxOccurrences":        12,
+		"OnInsufficientBalance": "retry",
+		"MaxRetries":            3,
+	})
+
+	httpReq, _ := http.NewRequest("POST", "/schedules", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Error   bool           `json:"error"`
+		Message string         `json:"message"`
+		Data    *data.Schedule `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, false, response.Error)
+	assert.Equal(t, 11, response.Data.ID)
+	assert.Equal(t, created.NextRunAt, response.Data.NextRunAt)
+
+	mockRepo.AssertCalled(t, "CreateSchedule", req)
+}
+
+// TestCreateSchedule_Invalid checks that validation errors explain what is wrong with the rule
+func TestCreateSchedule_Invalid(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("CreateSchedule", mock.Anything).Return(nil, fmt.Errorf("%w: interval must be positive", data.ErrInvalidSchedule))
+
+	body, _ := json.Marshal(map[string]interface{}{"IdSource": 1, "IdEndpoint": 2, "Amount": "25", "Frequency": "interval"})
+
+	httpReq, _ := http.NewRequest("POST", "/schedules", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.JSONEq(t, `{"error":true,"message":"invalid schedule: interval must be positive"}`, resp.Body.String())
+}
+
+// TestGetSchedule_NotFound checks that a missing schedule answers with 404
+func TestGetSchedule_NotFound(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("GetSchedule", 5).Return(nil, fmt.Errorf("schedule 5: %w", data.ErrScheduleNotFound))
+
+	httpReq, _ := http.NewRequest("GET", "/schedules/5", nil)
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.JSONEq(t, `{"error":true,"message":"Schedule doesn't exist"}`, resp.Body.String())
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"financial-service/data"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// getStatement returns the statement of a user for the yyyy-mm period in the path, as JSON by default or
+// as CSV or PDF when the format query parameter asks for it
+func (app *Config) getStatement(c *gin.Context) {
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	document, err := app.Repo.GetStatement(c.Request.Context(), &data.StatementRequest{
+		UserID:   id,
+		Currency: c.Query("currency"),
+		Period:   c.Param("period"),
+		Format:   data.StatementFormat(c.Query("format")),
+	})
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, data.ErrUserNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't generate the statement"),
+		})
+		return
+	}
+
+	if document.Format == data.StatementJSON {
+		c.JSON(http.StatusOK, gin.H{
+			"error":   false,
+			"message": "Statement generated",
+			"data":    json.RawMessage(document.Content),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%d-%s.%s"`, id, c.Param("period"), document.Format))
+	c.Data(http.StatusOK, document.ContentType(), document.Content)
+}
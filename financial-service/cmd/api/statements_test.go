@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"financial-service/data"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (m *MockRepository) GetStatement(ctx context.Context, req *data.StatementRequest) (*data.StatementDocument, error) {
+	args := m.Called(req)
+	if document, ok := args.Get(0).(*data.StatementDocument); ok {
+		return document, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// TestGetStatement_JSON checks that the stored statement is returned verbatim inside the usual envelope
+func TestGetStatement_JSON(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	req := &data.StatementRequest{UserID: 1, Period: "2024-05"}
+	document := &data.StatementDocument{Format: data.StatementJSON, Content: []byte(`{"UserID":1,"Period":"2024-05"}`), Persisted: true}
+
+	mockRepo.On("GetStatement", req).Return(document, nil)
+
+	httpReq, _ := http.NewRequest("GET", "/users/1/statements/2024-05", nil)
+	httpReq.Header.Set("X-User-Id", "1")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"error":false,"message":"Statement generated","data":{"UserID":1,"Period":"2024-05"}}`, resp.Body.String())
+}
+
+// TestGetStatement_CSV checks that other formats are served as downloads
+func TestGetStatement_CSV(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	req := &data.StatementRequest{UserID: 1, Currency: "USD", Period: "2024-05", Format: data.StatementCSV}
+	document := &data.StatementDocument{Format: data.StatementCSV, Content: []byte("date,transaction_id\n")}
+
+	mockRepo.On("GetStatement", req).Return(document, nil)
+
+	httpReq, _ := http.NewRequest("GET", "/users/1/statements/2024-05?format=csv&currency=USD", nil)
+	httpReq.Header.Set("X-User-Id", "1")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", resp.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="statement-1-2024-05.csv"`, resp.Header().Get("Content-Disposition"))
+	assert.Equal(t, "date,transaction_id\n", resp.Body.String())
+}
+
+// TestGetStatement_InvalidPeriod checks the error for periods that aren't months
+func TestGetStatement_InvalidPeriod(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	req := &data.StatementRequest{UserID: 1, Period: "May"}
+
+	mockRepo.On("GetStatement", req).Return(nil, fmt.Errorf("%w: %q is not yyyy-mm", data.ErrInvalidPeriod, "May"))
+
+	httpReq, _ := http.NewRequest("GET", "/users/1/statements/May", nil)
+	httpReq.Header.Set("X-User-Id", "1")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.JSONEq(t, `{"error":true,"message":"Period must be a month in yyyy-mm format that has already started"}`, resp.Body.String())
+}
+
+// TestGetStatement_Authorization checks that statements are served only to the account holder and admins
+func TestGetStatement_Authorization(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	for caller, status := range map[string]int{"": http.StatusUnauthorized, "2": http.StatusForbidden} {
+		httpReq, _ := http.NewRequest("GET", "/users/1/statements/2024-05", nil)
+		if caller != "" {
+			httpReq.Header.Set("X-User-Id", caller)
+		}
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, httpReq)
+
+		assert.Equal(t, status, resp.Code, caller)
+	}
+	mockRepo.AssertNotCalled(t, "GetStatement", mock.Anything)
+}
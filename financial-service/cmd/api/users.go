@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"financial-service/data"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultUsersLimit = 50
+	maxUsersLimit     = 200
+)
+
+// createUser adds a user
+func (app *Config) createUser(c *gin.Context) {
+	var requestPayload struct {
+		Name  string `json:"Name"`
+		Email string `json:"Email"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+
+	user, err := app.Repo.CreateUser(c.Request.Context(), &data.User{
+		Name:  requestPayload.Name,
+		Email: requestPayload.Email,
+	})
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't create the user"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "User created",
+		"data":    user,
+	})
+}
+
+// listUsers returns a page of users ordered by id. The after query parameter is the last id of the
+// previous page and limit the page size
+func (app *Config) listUsers(c *gin.Context) {
+	afterID, err := strconv.Atoi(c.DefaultQuery("after", "0"))
+	if err != nil || afterID < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "after must be a user id",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultUsersLimit)))
+	if err != nil || limit <= 0 || limit > maxUsersLimit {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "limit must be between 1 and " + strconv.Itoa(maxUsersLimit),
+		})
+		return
+	}
+
+	users, err := app.Repo.ListUsers(c.Request.Context(), afterID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   true,
+			"message": "Couldn't list the users",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Users found",
+		"data":    users,
+	})
+}
+
+// getUser returns a user
+func (app *Config) getUser(c *gin.Context) {
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	user, err := app.Repo.GetUser(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't get the user"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "User found",
+		"data":    user,
+	})
+}
+
+// updateUser changes the name or email of a user, leaving out fields missing from the payload
+func (app *Config) updateUser(c *gin.Context) {
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	var requestPayload struct {
+		Name  *string `json:"Name"`
+		Email *string `json:"Email"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+
+	user, err := app.Repo.UpdateUser(c.Request.Context(), id, &data.UserUpdate{
+		Name:  requestPayload.Name,
+		Email: requestPayload.Email,
+	})
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't update the user"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "User updated",
+		"data":    user,
+	})
+}
+
+// deleteUser soft-deletes a user that never moved money
+func (app *Config) deleteUser(c *gin.Context) {
+	id, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	if err := app.Repo.DeleteUser(c.Request.Context(), id); err != nil {
+		c.JSON(userErrorStatus(err), gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't delete the user"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "User deleted",
+	})
+}
+
+// userErrorStatus maps a user management error to its HTTP status
+func userErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, data.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrEmailTaken), errors.Is(err, data.ErrUserNotDeletable), errors.Is(err, data.ErrAccountClosed):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"financial-service/data"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (m *MockRepository) CreateUser(ctx context.Context, user *data.User) (*data.User, error) {
+	args := m.Called(user)
+	if created, ok := args.Get(0).(*data.User); ok {
+		return created, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) GetUser(ctx context.Context, id int) (*data.User, error) {
+	args := m.Called(id)
+	if user, ok := args.Get(0).(*data.User); ok {
+		return user, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) ListUsers(ctx context.Context, afterID, limit int) ([]*data.User, error) {
+	args := m.Called(afterID, limit)
+	if users, ok := args.Get(0).([]*data.User); ok {
+		return users, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) UpdateUser(ctx context.Context, id int, update *data.UserUpdate) (*data.User, error) {
+	args := m.Called(id, update)
+	if user, ok := args.Get(0).(*data.User); ok {
+		return user, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) DeleteUser(ctx context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+// TestCreateUser_Success checks that the name and email reach the repository
+func TestCreateUser_Success(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	req := &data.User{Name: "Anna", Email: "anna@example.com"}
+	mockRepo.On("CreateUser", req).Return(&data.User{ID: 7, Name: "Anna", Email: "anna@example.com", Status: data.AccountActive}, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Name": "Anna", "Email": "anna@example.com"})
+
+	httpReq, _ := http.NewRequest("POST", "/users", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Data *data.User `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, 7, response.Data.ID)
+	assert.Equal(t, data.AccountActive, response.Data.Status)
+}
+
+// TestCreateUser_EmailTaken checks that two users can't share an email
+func TestCreateUser_EmailTaken(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("CreateUser", mock.Anything).Return(nil, fmt.Errorf("%w: anna@example.com", data.ErrEmailTaken))
+
+	body, _ := json.Marshal(map[string]interface{}{"Name": "Anna", "Email": "anna@example.com"})
+
+	httpReq, _ := http.NewRequest("POST", "/users", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Another user already has this email")
+}
+
+// TestGetUser_NotFound checks that unknown and deleted users aren't found
+func TestGetUser_NotFound(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("GetUser", 9).Return(nil, fmt.Errorf("user 9: %w", data.ErrUserNotFound))
+
+	httpReq, _ := http.NewRequest("GET", "/users/9", nil)
+	httpReq.Header.Set("X-User-Id", "9")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+// TestListUsers_Page checks that the page parameters reach the repository
+func TestListUsers_Page(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	mockRepo.On("ListUsers", 10, 2).Return([]*data.User{{ID: 11}, {ID: 12}}, nil)
+
+	httpReq, _ := http.NewRequest("GET", "/users?after=10&limit=2", nil)
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Data []*data.User `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Len(t, response.Data, 2)
+}
+
+// TestListUsers_InvalidLimit checks that oversized pages are refused
+func TestListUsers_InvalidLimit(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	httpReq, _ := http.NewRequest("GET", "/users?limit=1000", nil)
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	mockRepo.AssertNotCalled(t, "ListUsers", mock.Anything, mock.Anything)
+}
+
+// TestUpdateUser_Partial checks that fields missing from the payload are left alone
+func TestUpdateUser_Partial(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	name := "Anna Petrova"
+	mockRepo.On("UpdateUser", 7, &data.UserUpdate{Name: &name}).Return(&data.User{ID: 7, Name: name}, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Name": name})
+
+	httpReq, _ := http.NewRequest("PATCH", "/users/7", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-User-Id", "7")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockRepo.AssertCalled(t, "UpdateUser", 7, &data.UserUpdate{Name: &name})
+}
+
+// TestUser_Authorization checks that users can only read and update their own profile
+func TestUser_Authorization(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	for _, method := range []string{"GET", "PATCH"} {
+		for caller, status := range map[string]int{"": http.StatusUnauthorized, "8": http.StatusForbidden} {
+			httpReq, _ := http.NewRequest(method, "/users/7", bytes.NewBufferString(`{"Email": "anna@example.com"}`))
+			httpReq.Header.Set("Content-Type", "application/json")
+			if caller != "" {
+				httpReq.Header.Set("X-User-Id", caller)
+			}
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, httpReq)
+
+			assert.Equal(t, status, resp.Code, method+" as "+caller)
+		}
+	}
+	mockRepo.AssertNotCalled(t, "GetUser", mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything)
+}
+
+// TestDeleteUser_HasHistory checks that users with history can't be deleted
+func TestDeleteUser_HasHistory(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	mockRepo.On("DeleteUser", 7).Return(fmt.Errorf("%w: user 7 has transaction history", data.ErrUserNotDeletable))
+
+	httpReq, _ := http.NewRequest("DELETE", "/users/7", nil)
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "transaction history")
+}
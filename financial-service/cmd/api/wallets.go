@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"financial-service/data"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// createWallet opens a named wallet for a user
+func (app *Config) createWallet(c *gin.Context) {
+	userID, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	var requestPayload struct {
+		Name     string `json:"Name"`
+		Currency string `json:"Currency"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+
+	wallet, err := app.Repo.CreateWallet(c.Request.Context(), &data.Wallet{
+		UserID:   userID,
+		Name:     requestPayload.Name,
+		Currency: requestPayload.Currency,
+	})
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, data.ErrWalletNameTaken) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't create the wallet"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Wallet created",
+		"data":    wallet,
+	})
+}
+
+// listWallets returns the wallets of a user with their balances
+func (app *Config) listWallets(c *gin.Context) {
+	userID, ok := pathID(c, "user")
+	if !ok {
+		return
+	}
+
+	wallets, err := app.Repo.ListWallets(c.Request.Context(), userID)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, data.ErrUserNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't list the wallets"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Wallets found",
+		"data":    wallets,
+	})
+}
+
+// renameWallet changes the name of a wallet
+func (app *Config) renameWallet(c *gin.Context) {
+	id, ok := pathID(c, "wallet")
+	if !ok {
+		return
+	}
+
+	var requestPayload struct {
+		Name string `json:"Name"`
+	}
+
+	if err := c.ShouldBindJSON(&requestPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   true,
+			"message": "Invalid JSON",
+		})
+		return
+	}
+
+	wallet, err := app.Repo.RenameWallet(c.Request.Context(), id, requestPayload.Name)
+	if err != nil {
+		c.JSON(walletErrorStatus(err), gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't rename the wallet"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Wallet renamed",
+		"data":    wallet,
+	})
+}
+
+// closeWallet closes an empty wallet
+func (app *Config) closeWallet(c *gin.Context) {
+	id, ok := pathID(c, "wallet")
+	if !ok {
+		return
+	}
+
+	wallet, err := app.Repo.CloseWallet(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(walletErrorStatus(err), gin.H{
+			"error":   true,
+			"message": errorMessage(err, "Couldn't close the wallet"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"error":   false,
+		"message": "Wallet closed",
+		"data":    wallet,
+	})
+}
+
+// walletErrorStatus maps an error of an operation on an existing wallet to its response status
+func walletErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, data.ErrWalletNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrWalletNameTaken), errors.Is(err, data.ErrWalletClosed), errors.Is(err, data.ErrWalletNotClosable):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"financial-service/data"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (m *MockRepository) CreateWallet(ctx context.Context, w *data.Wallet) (*data.Wallet, error) {
+	args := m.Called(w)
+	if wallet, ok := args.Get(0).(*data.Wallet); ok {
+		return wallet, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) ListWallets(ctx context.Context, userID int) ([]*data.Wallet, error) {
+	args := m.Called(userID)
+	if wallets, ok := args.Get(0).([]*data.Wallet); ok {
+		return wallets, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) GetWallet(ctx context.Context, id int) (*data.Wallet, error) {
+	args := m.Called(id)
+	if wallet, ok := args.Get(0).(*data.Wallet); ok {
+		return wallet, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) RenameWallet(ctx context.Context, id int, name string) (*data.Wallet, error) {
+	args := m.Called(id, name)
+	if wallet, ok := args.Get(0).(*data.Wallet); ok {
+		return wallet, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) CloseWallet(ctx context.Context, id int) (*data.Wallet, error) {
+	args := m.Called(id)
+	if wallet, ok := args.Get(0).(*data.Wallet); ok {
+		return wallet, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) GetWalletTransactions(walletID int) ([]*data.Transactions, error) {
+	args := m.Called(walletID)
+	if transactions, ok := args.Get(0).([]*data.Transactions); ok {
+		return transactions, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// TestCreateWallet_Success checks that the user, name and currency reach the repository
+func TestCreateWallet_Success(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	req := &data.Wallet{UserID: 4, Name: "savings", Currency: "USD"}
+	wallet := &data.Wallet{ID: 12, UserID: 4, Name: "savings", Currency: "USD", Status: data.WalletOpen}
+	mockRepo.On("CreateWallet", req).Return(wallet, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Name": "savings", "Currency": "USD"})
+
+	httpReq, _ := http.NewRequest("POST", "/users/4/wallets", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-User-Id", "4")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Error   bool         `json:"error"`
+		Message string       `json:"message"`
+		Data    *data.Wallet `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, 12, response.Data.ID)
+	assert.Equal(t, data.WalletOpen, response.Data.Status)
+	mockRepo.AssertCalled(t, "CreateWallet", req)
+}
+
+// TestCreateWallet_NameTaken checks that wallet names can't be reused
+func TestCreateWallet_NameTaken(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("CreateWallet", mock.Anything).Return(nil, fmt.Errorf("%w: \"main\" in RUB", data.ErrWalletNameTaken))
+
+	body, _ := json.Marshal(map[string]interface{}{"Name": "Main"})
+
+	httpReq, _ := http.NewRequest("POST", "/users/4/wallets", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-User-Id", "4")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "A wallet with this name already exists in this currency")
+}
+
+// TestListWallets_Success checks that every wallet is listed with its balance
+func TestListWallets_Success(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	wallets := []*data.Wallet{
+		{ID: 1, UserID: 4, Name: "main", Currency: "RUB", Default: true, Status: data.WalletOpen, Balance: &data.Balance{Ledger: decimal.NewFromInt(50)}},
+		{ID: 12, UserID: 4, Name: "savings", Currency: "RUB", Status: data.WalletOpen, Balance: &data.Balance{Ledger: decimal.NewFromInt(300)}},
+	}
+	mockRepo.On("ListWallets", 4).Return(wallets, nil)
+
+	httpReq, _ := http.NewRequest("GET", "/users/4/wallets", nil)
+	httpReq.Header.Set("X-User-Id", "4")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var response struct {
+		Data []*data.Wallet `json:"data"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Len(t, response.Data, 2)
+	assert.True(t, response.Data[0].Default)
+	assert.True(t, response.Data[1].Balance.Ledger.Equal(decimal.NewFromInt(300)))
+}
+
+// TestRenameWallet_Success checks renaming a wallet
+func TestRenameWallet_Success(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("GetWallet", 12).Return(&data.Wallet{ID: 12, UserID: 4}, nil)
+	mockRepo.On("RenameWallet", 12, "holidays").Return(&data.Wallet{ID: 12, Name: "holidays", Status: data.WalletOpen}, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Name": "holidays"})
+
+	httpReq, _ := http.NewRequest("POST", "/wallets/12/rename", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-User-Id", "4")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), "holidays")
+}
+
+// TestCloseWallet_NotEmpty checks that wallets holding money can't be closed
+func TestCloseWallet_NotEmpty(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("GetWallet", 12).Return(&data.Wallet{ID: 12, UserID: 4}, nil)
+	mockRepo.On("CloseWallet", 12).Return(nil, fmt.Errorf("%w: wallet 12 holds 300 RUB", data.ErrWalletNotClosable))
+
+	httpReq, _ := http.NewRequest("POST", "/wallets/12/close", nil)
+	httpReq.Header.Set("X-User-Id", "4")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Only empty wallets other than the default one can be closed")
+}
+
+// TestCloseWallet_NotFound checks closing an unknown wallet as an admin
+func TestCloseWallet_NotFound(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	mockRepo.On("CloseWallet", 99).Return(nil, fmt.Errorf("wallet 99: %w", data.ErrWalletNotFound))
+
+	httpReq, _ := http.NewRequest("POST", "/wallets/99/close", nil)
+	httpReq.Header.Set("Authorization", "Bearer secret")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+// TestWalletRoutes_Authorization checks that users can only open, list, rename and close their own wallets
+func TestWalletRoutes_Authorization(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("GetWallet", 12).Return(&data.Wallet{ID: 12, UserID: 4}, nil)
+	mockRepo.On("GetWallet", 99).Return(nil, fmt.Errorf("wallet 99: %w", data.ErrWalletNotFound))
+
+	tests := []struct {
+		method string
+		path   string
+		caller string
+		status int
+	}{
+		{"POST", "/users/4/wallets", "", http.StatusUnauthorized},
+		{"POST", "/users/4/wallets", "5", http.StatusForbidden},
+		{"GET", "/users/4/wallets", "5", http.StatusForbidden},
+		{"POST", "/wallets/12/rename", "", http.StatusUnauthorized},
+		{"POST", "/wallets/12/rename", "5", http.StatusForbidden},
+		{"POST", "/wallets/12/close", "5", http.StatusForbidden},
+		{"POST", "/wallets/99/close", "5", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		body, _ := json.Marshal(map[string]interface{}{"Name": "holidays", "Currency": "RUB"})
+
+		httpReq, _ := http.NewRequest(tt.method, tt.path, bytes.NewBuffer(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		if tt.caller != "" {
+			httpReq.Header.Set("X-User-Id", tt.caller)
+		}
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, httpReq)
+
+		assert.Equal(t, tt.status, resp.Code, tt.method+" "+tt.path+" as "+tt.caller)
+	}
+	mockRepo.AssertNotCalled(t, "CreateWallet", mock.Anything)
+	mockRepo.AssertNotCalled(t, "ListWallets", mock.Anything)
+	mockRepo.AssertNotCalled(t, "RenameWallet", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "CloseWallet", mock.Anything)
+}
+
+// TestGetLastTransactions_Wallet checks that a wallet id narrows the history to that wallet
+func TestGetLastTransactions_Wallet(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	transactions := []*data.Transactions{
+		{ID: 7, Type: data.TransactionTransfer, UserIDSource: intPtr(4), UserIDEndpoint: intPtr(4), SourceWalletID: intPtr(1), DestinationWalletID: intPtr(12)},
+	}
+	mockRepo.On("GetWallet", 12).Return(&data.Wallet{ID: 12, UserID: 4}, nil)
+	mockRepo.On("GetWalletTransactions", 12).Return(transactions, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Id": 4, "WalletId": 12})
+
+	httpReq, _ := http.NewRequest("GET", "/getLastTransactions", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-User-Id", "4")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"DestinationWalletID":12`)
+	mockRepo.AssertCalled(t, "GetWalletTransactions", 12)
+	mockRepo.AssertNotCalled(t, "GetLastTransactions", mock.Anything)
+}
+
+// TestGetLastTransactions_Authorization checks that the legacy history is open only to the holder of the
+// account or wallet and to admins
+func TestGetLastTransactions_Authorization(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo, AdminToken: "secret"}
+	app.routes(router)
+
+	mockRepo.On("GetWallet", 12).Return(&data.Wallet{ID: 12, UserID: 4}, nil)
+	mockRepo.On("GetWallet", 99).Return(nil, fmt.Errorf("wallet 99: %w", data.ErrWalletNotFound))
+	mockRepo.On("GetLastTransactions", 4).Return([]*data.Transactions{}, nil)
+
+	tests := []struct {
+		name    string
+		payload map[string]interface{}
+		header  string
+		value   string
+		status  int
+	}{
+		{"anonymous", map[string]interface{}{"Id": 4}, "", "", http.StatusUnauthorized},
+		{"another user", map[string]interface{}{"Id": 4}, "X-User-Id", "5", http.StatusForbidden},
+		{"another user's wallet", map[string]interface{}{"Id": 5, "WalletId": 12}, "X-User-Id", "5", http.StatusForbidden},
+		{"missing wallet", map[string]interface{}{"Id": 5, "WalletId": 99}, "X-User-Id", "5", http.StatusForbidden},
+		{"admin", map[string]interface{}{"Id": 4}, "Authorization", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		body, _ := json.Marshal(tt.payload)
+
+		httpReq, _ := http.NewRequest("GET", "/getLastTransactions", bytes.NewBuffer(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		if tt.header != "" {
+			httpReq.Header.Set(tt.header, tt.value)
+		}
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, httpReq)
+
+		assert.Equal(t, tt.status, resp.Code, tt.name)
+	}
+	mockRepo.AssertNotCalled(t, "GetWalletTransactions", mock.Anything)
+}
+
+// TestTransferMoney_BetweenWallets checks that wallet ids reach the repository and that a source wallet
+// without a currency isn't forced into the default one
+func TestTransferMoney_BetweenWallets(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	amount := decimal.NewFromInt(25)
+	req := &data.TransferRequest{FromWalletID: 1, ToWalletID: 12, Amount: amount}
+	mockRepo.On("Transfer", req).Return(1, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"Amount": "25", "FromWalletId": 1, "ToWalletId": 12})
+
+	httpReq, _ := http.NewRequest("POST", "/transferMoney", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	mockRepo.AssertCalled(t, "Transfer", req)
+}
+
+// TestDepositMoney_ClosedWallet checks that closed wallets can't be credited
+func TestDepositMoney_ClosedWallet(t *testing.T) {
+	router := gin.Default()
+
+	mockRepo := new(MockRepository)
+	app := &Config{Repo: mockRepo}
+	app.routes(router)
+
+	mockRepo.On("Deposit", &data.DepositRequest{WalletID: 12, Amount: decimal.NewFromInt(10)}).
+		Return(0, fmt.Errorf("wallet 12: %w", data.ErrWalletClosed))
+
+	body, _ := json.Marshal(map[string]interface{}{"Amount": "10", "WalletId": 12})
+
+	httpReq, _ := http.NewRequest("POST", "/depositMoney", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Wallet is closed")
+}
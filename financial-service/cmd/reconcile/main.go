@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"financial-service/data"
+	"flag"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/joho/godotenv"
+	"log"
+	"os"
+)
+
+// main compares every user's cached balances with the ledger and prints the report as JSON. It exits with
+// status 1 when mismatches remain, so it can be used as a check
+func main() {
+	repair := flag.Bool("repair", false, "write adjustment transactions that correct the mismatches")
+	batchSize := flag.Int("batch", 500, "number of accounts to check per query")
+	envFile := flag.String("env", "example.env", "file to load the environment from, if it exists")
+	flag.Parse()
+
+	if err := godotenv.Load(*envFile); err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Error loading %s: %v", *envFile, err)
+	}
+
+	conn, err := sql.Open("pgx/v4", os.Getenv("DSN"))
+	if err != nil {
+		log.Fatalf("Can't open Postgres connection: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.Ping(); err != nil {
+		log.Fatalf("Can't connect to Postgres: %v", err)
+	}
+
+	repo := data.NewPostgresRepository(conn)
+	report, err := repo.Reconcile(context.Background(), &data.ReconcileOptions{Repair: *repair, BatchSize: *batchSize})
+	if err != nil {
+		log.Fatalf("Reconciliation failed: %v", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		log.Fatalf("Can't write report: %v", err)
+	}
+
+	log.Printf("Scanned %d accounts, %d mismatches, %d repaired\n", report.Scanned, len(report.Mismatches), report.Repaired)
+	if len(report.Mismatches) > report.Repaired {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,330 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"strings"
+	"time"
+)
+
+// AccountStatus is the lifecycle state of a user's account. Frozen accounts refuse money coming in,
+// going out or both; closed accounts refuse everything and can't be reopened
+type AccountStatus string
+
+const (
+	AccountActive    AccountStatus = "active"
+	AccountFrozenIn  AccountStatus = "frozen_in"
+	AccountFrozenOut AccountStatus = "frozen_out"
+	AccountFrozenAll AccountStatus = "frozen_all"
+	AccountClosed    AccountStatus = "closed"
+)
+
+// accountFlow is the direction of a money movement relative to a user's account
+type accountFlow int
+
+const (
+	flowIn accountFlow = iota
+	flowOut
+)
+
+// allows reports whether an account in status accepts money moving in flow
+func (s AccountStatus) allows(flow accountFlow) bool {
+	switch s {
+	case AccountActive:
+		return true
+	case AccountFrozenIn:
+		return flow == flowOut
+	case AccountFrozenOut:
+		return flow == flowIn
+	default:
+		return false
+	}
+}
+
+// valid reports whether s is a known status
+func (s AccountStatus) valid() bool {
+	switch s {
+	case AccountActive, AccountFrozenIn, AccountFrozenOut, AccountFrozenAll, AccountClosed:
+		return true
+	default:
+		return false
+	}
+}
+
+// AccountStatusChange records a move of a user's account from one status to another. SweptTo and
+// Sweeps are set when closing the account moved its remaining balances to another user
+type AccountStatusChange struct {
+	ID        int           `json:"ID"`
+	UserID    int           `json:"UserID"`
+	From      AccountStatus `json:"From"`
+	To        AccountStatus `json:"To"`
+	Reason    string        `json:"Reason"`
+	SweptTo   *int          `json:"SweptTo"`
+	Sweeps    []int         `json:"Sweeps,omitempty"`
+	CreatedAt time.Time     `json:"CreatedAt"`
+}
+
+// CloseAccountRequest asks to close a user's account. Any money left on it is moved to the default
+// wallets of SweepTo; without SweepTo every wallet must be empty
+type CloseAccountRequest struct {
+	UserID  int
+	SweepTo int
+	Reason  string
+}
+
+// SetAccountStatus freezes or unfreezes a user's account. Closing goes through CloseAccount instead
+func (u *PostgresRepository) SetAccountStatus(ctx context.Context, userID int, status AccountStatus, reason string) (*AccountStatusChange, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", ErrInvalidStatusChange)
+	}
+	if !status.valid() {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidStatusChange, status)
+	}
+	if status == AccountClosed {
+		return nil, fmt.Errorf("%w: accounts are closed through the close flow", ErrInvalidStatusChange)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockAccountStatus(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if current == status {
+		return nil, fmt.Errorf("%w: account of user %d is already %s", ErrInvalidStatusChange, userID, status)
+	}
+
+	change, err := changeAccountStatus(ctx, tx, &AccountStatusChange{UserID: userID, From: current, To: status, Reason: reason})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return change, nil
+}
+
+// CloseAccount closes a user's account for good. It fails while holds are active or a wallet is
+// overdrawn, and when money is left on the account and there's nowhere to sweep it to
+func (u *PostgresRepository) CloseAccount(ctx context.Context, req *CloseAccountRequest) (*AccountStatusChange, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", ErrInvalidStatusChange)
+	}
+	if req.SweepTo == req.UserID {
+		return nil, ErrSameAccount
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The user is locked before listing wallets, so none can be opened or funded while it closes
+	current, err := lockAccountStatus(ctx, tx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	// The sweep target is share-locked before the accounts as well. Its status only matters when
+	// there is money to sweep, which isn't known until the wallets are locked
+	var sweepErr error
+	if req.SweepTo != 0 {
+		sweepErr = checkAccountStatus(ctx, tx, req.SweepTo, flowIn)
+	}
+
+	type wallet struct {
+		id, destinationID int
+		currency          string
+		balance, held     decimal.Decimal
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, currency FROM accounts WHERE user_id = $1 ORDER BY id`, req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallets: %w", err)
+	}
+	var wallets []*wallet
+	for rows.Next() {
+		var w wallet
+		if err := rows.Scan(&w.id, &w.currency); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan wallet: %w", err)
+		}
+		wallets = append(wallets, &w)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get wallets: %w", err)
+	}
+
+	// Accounts are locked after the users, in the same order as every other movement
+	ids := make([]int, 0, 2*len(wallets))
+	for _, w := range wallets {
+		ids = append(ids, w.id)
+		if req.SweepTo != 0 {
+			if w.destinationID, err = userAccountID(ctx, tx, req.SweepTo, w.currency); err != nil {
+				return nil, fmt.Errorf("sweep %w", err)
+			}
+			ids = append(ids, w.destinationID)
+		}
+	}
+	if _, err := lockAccounts(ctx, tx, ids...); err != nil {
+		return nil, err
+	}
+
+	var remaining bool
+	for _, w := range wallets {
+		err := tx.QueryRowContext(ctx, `SELECT balance, held FROM accounts WHERE id = $1`, w.id).Scan(&w.balance, &w.held)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get balance: %w", err)
+		}
+		if w.held.IsPositive() {
+			return nil, fmt.Errorf("%w: wallet %d has %s %s on hold", ErrAccountNotClosable, w.id, w.held.String(), w.currency)
+		}
+		if w.balance.IsNegative() {
+			return nil, fmt.Errorf("%w: wallet %d is overdrawn by %s %s", ErrAccountNotClosable, w.id, w.balance.Neg().String(), w.currency)
+		}
+		remaining = remaining || w.balance.IsPositive()
+	}
+	if remaining && req.SweepTo == 0 {
+		return nil, fmt.Errorf("%w: money is left on the account and no account to sweep it to was given", ErrAccountNotClosable)
+	}
+
+	change := &AccountStatusChange{UserID: req.UserID, From: current, To: AccountClosed, Reason: reason, CreatedAt: time.Now()}
+	if remaining {
+		if sweepErr != nil {
+			return nil, fmt.Errorf("sweep %w", sweepErr)
+		}
+		change.SweptTo = &req.SweepTo
+	}
+
+	for _, w := range wallets {
+		if !w.balance.IsPositive() {
+			continue
+		}
+
+		var transactionID int
+		stmt := `
+            INSERT INTO transactions (type, userIDSource, userIDEndpoint, source_wallet_id, destination_wallet_id, amount, currency, createdat)
+            VALUES ('transfer', $1, $2, $3, $4, $5, $6, $7)
+            RETURNING id
+        `
+		err := tx.QueryRowContext(ctx, stmt, req.UserID, req.SweepTo, w.id, w.destinationID, w.balance, w.currency,
+			change.CreatedAt).Scan(&transactionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add transaction: %w", err)
+		}
+
+		_, err = postEntry(ctx, tx, &JournalEntry{
+			TransactionID: &transactionID,
+			Description:   "account closure sweep",
+			CreatedAt:     change.CreatedAt,
+			Postings: []*Posting{
+				{AccountID: w.id, Currency: w.currency, Amount: w.balance.Neg()},
+				{AccountID: w.destinationID, Currency: w.currency, Amount: w.balance},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		change.Sweeps = append(change.Sweeps, transactionID)
+	}
+
+	if change, err = changeAccountStatus(ctx, tx, change); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return change, nil
+}
+
+// lockAccountStatus locks a user and returns the status of their account. Closed accounts can't change
+// status anymore
+func lockAccountStatus(ctx context.Context, tx *sql.Tx, userID int) (AccountStatus, error) {
+	var status AccountStatus
+	err := tx.QueryRowContext(ctx, `SELECT status FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, userID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("user %d: %w", userID, ErrUserNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to lock user: %w", err)
+	}
+	if status == AccountClosed {
+		return "", fmt.Errorf("%w: user %d", ErrAccountClosed, userID)
+	}
+
+	return status, nil
+}
+
+// changeAccountStatus sets the status of a locked user and records the change
+func changeAccountStatus(ctx context.Context, tx *sql.Tx, change *AccountStatusChange) (*AccountStatusChange, error) {
+	if change.CreatedAt.IsZero() {
+		change.CreatedAt = time.Now()
+	}
+
+	stmt := `
+        UPDATE users SET status = $1, updated_at = $2, closed_at = CASE WHEN $1 = 'closed' THEN $2::timestamp END
+        WHERE id = $3
+    `
+	if _, err := tx.ExecContext(ctx, stmt, change.To, change.CreatedAt, change.UserID); err != nil {
+		return nil, fmt.Errorf("failed to change account status: %w", err)
+	}
+
+	stmt = `
+        INSERT INTO account_status_changes (user_id, from_status, to_status, reason, swept_to, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id
+    `
+	err := tx.QueryRowContext(ctx, stmt, change.UserID, change.From, change.To, change.Reason, change.SweptTo,
+		change.CreatedAt).Scan(&change.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record status change: %w", err)
+	}
+
+	return change, nil
+}
+
+// checkAccountStatus fails when the account of a user doesn't accept money moving in flow. The user
+// stays share-locked until the transaction ends, so a freeze or close waits for movements that
+// passed the check; movements call it before locking accounts. It takes KEY SHARE rather than
+// SHARE because applyPosting updates users.balance, which SHARE would conflict with
+func checkAccountStatus(ctx context.Context, tx *sql.Tx, userID int, flow accountFlow) error {
+	var status AccountStatus
+	err := tx.QueryRowContext(ctx, `SELECT status FROM users WHERE id = $1 AND deleted_at IS NULL FOR KEY SHARE`, userID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("user %d: %w", userID, ErrUserNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get account status: %w", err)
+	}
+
+	if status.allows(flow) {
+		return nil
+	}
+	if status == AccountClosed {
+		return fmt.Errorf("%w: user %d", ErrAccountClosed, userID)
+	}
+	if flow == flowIn {
+		return fmt.Errorf("%w: user %d is %s and can't receive money", ErrAccountFrozen, userID, status)
+	}
+	return fmt.Errorf("%w: user %d is %s and can't send money", ErrAccountFrozen, userID, status)
+}
@@ -0,0 +1,27 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAccountStatusAllows checks which directions of movement each status accepts
+func TestAccountStatusAllows(t *testing.T) {
+	tests := []struct {
+		status AccountStatus
+		in     bool
+		out    bool
+	}{
+		{AccountActive, true, true},
+		{AccountFrozenIn, false, true},
+		{AccountFrozenOut, true, false},
+		{AccountFrozenAll, false, false},
+		{AccountClosed, false, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.in, tt.status.allows(flowIn), "%s in", tt.status)
+		assert.Equal(t, tt.out, tt.status.allows(flowOut), "%s out", tt.status)
+	}
+}
@@ -0,0 +1,235 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"strings"
+	"time"
+)
+
+const (
+	// snapshotTimeout bounds the nightly snapshot, which touches every account and is slower than a request
+	snapshotTimeout = time.Minute
+	// MaxBalanceBatch is the largest number of users whose balances can be read in one call
+	MaxBalanceBatch = 100
+)
+
+// Balance is the state of a user's account in one currency. Ledger is the posted balance, Held the part
+// reserved by active holds, OverdraftLimit how far below zero the ledger balance may go and Available what
+// can still be spent
+type Balance struct {
+	UserID         int             `json:"UserID"`
+	Currency       string          `json:"Currency"`
+	Ledger         decimal.Decimal `json:"Ledger"`
+	Held           decimal.Decimal `json:"Held"`
+	OverdraftLimit decimal.Decimal `json:"OverdraftLimit"`
+	Available      decimal.Decimal `json:"Available"`
+	UpdatedAt      time.Time       `json:"UpdatedAt"`
+}
+
+// accountBalance reads the balance of an account inside tx
+func accountBalance(ctx context.Context, tx *sql.Tx, accountID int) (*Balance, error) {
+	var balance Balance
+	query := `SELECT user_id, currency, balance, held, overdraft_limit, updated_at FROM accounts WHERE id = $1`
+	err := tx.QueryRowContext(ctx, query, accountID).Scan(&balance.UserID, &balance.Currency, &balance.Ledger, &balance.Held,
+		&balance.OverdraftLimit, &balance.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+	balance.Available = balance.Ledger.Sub(balance.Held).Add(balance.OverdraftLimit)
+
+	return &balance, nil
+}
+
+// GetBalance returns the current balance of a user's default wallet in currency. A user without a wallet
+// in currency has a zero balance
+func (u *PostgresRepository) GetBalance(ctx context.Context, userID int, currency string) (*Balance, error) {
+	balances, err := u.GetBalances(ctx, []int{userID}, currency)
+	if err != nil {
+		return nil, err
+	}
+	if len(balances) == 0 {
+		return nil, fmt.Errorf("user %d: %w", userID, ErrUserNotFound)
+	}
+
+	return balances[0], nil
+}
+
+// GetBalances returns the current balances of the default wallets in currency of several users, in the
+// order of userIDs. Users that don't exist are left out
+func (u *PostgresRepository) GetBalances(ctx context.Context, userIDs []int, currency string) ([]*Balance, error) {
+	currency = NormalizeCurrency(currency)
+	if _, err := CurrencyScale(currency); err != nil {
+		return nil, err
+	}
+	if len(userIDs) == 0 {
+		return []*Balance{}, nil
+	}
+	if len(userIDs) > MaxBalanceBatch {
+		return nil, fmt.Errorf("%w: at most %d users can be read at once", ErrInvalidBalanceRequest, MaxBalanceBatch)
+	}
+
+	args := queryArgs{currency}
+	placeholders := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		placeholders[i] = args.add(id)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+        SELECT u.id, COALESCE(a.balance, 0), COALESCE(a.held, 0), COALESCE(a.overdraft_limit, 0), COALESCE(a.updated_at, u.updated_at)
+        FROM users u
+        LEFT JOIN accounts a ON a.user_id = u.id AND a.currency = $1 AND a.is_default
+        WHERE u.deleted_at IS NULL AND u.id IN (` + strings.Join(placeholders, ", ") + `)
+    `
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balances: %w", err)
+	}
+	defer rows.Close()
+
+	found := make(map[int]*Balance, len(userIDs))
+	for rows.Next() {
+		balance := Balance{Currency: currency}
+		err := rows.Scan(&balance.UserID, &balance.Ledger, &balance.Held, &balance.OverdraftLimit, &balance.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan balance: %w", err)
+		}
+		balance.Available = balance.Ledger.Sub(balance.Held).Add(balance.OverdraftLimit)
+		found[balance.UserID] = &balance
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get balances: %w", err)
+	}
+
+	return orderBalances(userIDs, found), nil
+}
+
+// orderBalances lists the balances found for userIDs in the order of userIDs, once per user
+func orderBalances(userIDs []int, found map[int]*Balance) []*Balance {
+	balances := make([]*Balance, 0, len(found))
+	for _, id := range userIDs {
+		if balance, ok := found[id]; ok {
+			balances = append(balances, balance)
+			delete(found, id)
+		}
+	}
+
+	return balances
+}
+
+// HistoricalBalance is the ledger balance of a user's account at AsOf, rebuilt from postings. It starts
+// from the latest snapshot taken at or before AsOf, if any, and applies the EntriesApplied postings made
+// since, the last of which belongs to LastEntryID
+type HistoricalBalance struct {
+	UserID         int             `json:"UserID"`
+	Currency       string          `json:"Currency"`
+	AsOf           time.Time       `json:"AsOf"`
+	Balance        decimal.Decimal `json:"Balance"`
+	SnapshotAsOf   *time.Time      `json:"SnapshotAsOf"`
+	SnapshotAmount decimal.Decimal `json:"SnapshotAmount"`
+	EntriesApplied int             `json:"EntriesApplied"`
+	LastEntryID    *int            `json:"LastEntryID"`
+}
+
+// BalanceAsOf returns the balance a user had in currency at the instant asOf, counting every entry
+// created at or before it
+func (u *PostgresRepository) BalanceAsOf(ctx context.Context, userID int, currency string, asOf time.Time) (*HistoricalBalance, error) {
+	currency = NormalizeCurrency(currency)
+	if _, err := CurrencyScale(currency); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	balance := HistoricalBalance{UserID: userID, Currency: currency, AsOf: asOf}
+
+	var accountID sql.NullInt64
+	query := `SELECT a.id FROM users u LEFT JOIN accounts a ON a.user_id = u.id AND a.currency = $2 AND a.is_default WHERE u.id = $1`
+	err = tx.QueryRowContext(ctx, query, userID, currency).Scan(&accountID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("user %d: %w", userID, ErrUserNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	if !accountID.Valid {
+		return &balance, nil
+	}
+
+	query = `SELECT as_of, balance FROM balance_snapshots WHERE account_id = $1 AND as_of <= $2 ORDER BY as_of DESC LIMIT 1`
+	var snapshotAsOf time.Time
+	err = tx.QueryRowContext(ctx, query, accountID.Int64, asOf).Scan(&snapshotAsOf, &balance.SnapshotAmount)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get balance snapshot: %w", err)
+	}
+	if err == nil {
+		balance.SnapshotAsOf = &snapshotAsOf
+	}
+
+	// A snapshot covers the entries created before its as_of, so the delta starts at as_of inclusive
+	var delta decimal.Decimal
+	query = `
+        SELECT COALESCE(SUM(p.amount), 0), COUNT(*), MAX(e.id)
+        FROM postings p
+        JOIN journal_entries e ON e.id = p.entry_id
+        WHERE p.account_id = $1 AND e.created_at <= $2 AND ($3::timestamp IS NULL OR e.created_at >= $3)
+    `
+	err = tx.QueryRowContext(ctx, query, accountID.Int64, asOf, balance.SnapshotAsOf).Scan(&delta, &balance.EntriesApplied, &balance.LastEntryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum postings: %w", err)
+	}
+	balance.Balance = balance.SnapshotAmount.Add(delta)
+
+	return &balance, nil
+}
+
+// SnapshotBalances records the balance of every account as of asOf, built from the latest earlier
+// snapshot plus the postings since. Accounts that already have a snapshot at asOf are left alone, so the
+// job can be rerun safely. It returns how many snapshots were written
+func (u *PostgresRepository) SnapshotBalances(ctx context.Context, asOf time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+	defer cancel()
+
+	stmt := `
+        INSERT INTO balance_snapshots (account_id, as_of, balance, last_entry_id, created_at)
+        SELECT a.id, $1, COALESCE(s.balance, 0) + COALESCE(d.amount, 0), d.last_entry_id, $2
+        FROM accounts a
+        LEFT JOIN LATERAL (
+            SELECT as_of, balance FROM balance_snapshots
+            WHERE account_id = a.id AND as_of < $1
+            ORDER BY as_of DESC
+            LIMIT 1
+        ) s ON TRUE
+        LEFT JOIN LATERAL (
+            SELECT SUM(p.amount) AS amount, MAX(e.id) AS last_entry_id
+            FROM postings p
+            JOIN journal_entries e ON e.id = p.entry_id
+            WHERE p.account_id = a.id AND e.created_at < $1 AND (s.as_of IS NULL OR e.created_at >= s.as_of)
+        ) d ON TRUE
+        ON CONFLICT (account_id, as_of) DO NOTHING
+    `
+	result, err := db.ExecContext(ctx, stmt, asOf, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to snapshot balances: %w", err)
+	}
+
+	written, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to snapshot balances: %w", err)
+	}
+
+	return written, nil
+}
@@ -0,0 +1,24 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOrderBalances checks that balances follow the requested order once per user and skip missing users
+func TestOrderBalances(t *testing.T) {
+	found := map[int]*Balance{
+		1: {UserID: 1},
+		3: {UserID: 3},
+		4: {UserID: 4},
+	}
+
+	balances := orderBalances([]int{4, 2, 1, 4, 3}, found)
+
+	ids := make([]int, len(balances))
+	for i, balance := range balances {
+		ids[i] = balance.UserID
+	}
+	assert.Equal(t, []int{4, 1, 3}, ids)
+}
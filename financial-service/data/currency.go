@@ -0,0 +1,63 @@
+package data
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"strings"
+)
+
+// DefaultCurrency is the currency of balances that predate multi-currency support. The cached
+// users.balance column mirrors the user's account in this currency
+const DefaultCurrency = "RUB"
+
+// currencyScales holds the number of minor unit digits of each supported ISO 4217 currency
+var currencyScales = map[string]int32{
+	"RUB": 2,
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"KZT": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"BHD": 3,
+	"KWD": 3,
+}
+
+// NormalizeCurrency upper-cases an ISO 4217 code and substitutes DefaultCurrency for an empty one
+func NormalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return DefaultCurrency
+	}
+
+	return currency
+}
+
+// CurrencyScale returns the number of minor unit digits of currency
+func CurrencyScale(currency string) (int32, error) {
+	scale, ok := currencyScales[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, currency)
+	}
+
+	return scale, nil
+}
+
+// ValidateAmount checks that amount is positive and has no more decimal places than currency allows
+func ValidateAmount(currency string, amount decimal.Decimal) error {
+	scale, err := CurrencyScale(currency)
+	if err != nil {
+		return err
+	}
+
+	if !amount.IsPositive() {
+		return ErrInvalidAmount
+	}
+	if !amount.Equal(amount.Truncate(scale)) {
+		return fmt.Errorf("%w: %s allows %d decimal places, got %s", ErrInvalidScale, currency, scale, amount.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidateAmount checks currency-specific scale validation
+func TestValidateAmount(t *testing.T) {
+	tests := []struct {
+		currency string
+		amount   string
+		err      error
+	}{
+		{currency: "USD", amount: "10.25"},
+		{currency: "USD", amount: "10.250"},
+		{currency: "USD", amount: "10.255", err: ErrInvalidScale},
+		{currency: "JPY", amount: "1000"},
+		{currency: "JPY", amount: "1000.5", err: ErrInvalidScale},
+		{currency: "BHD", amount: "1.125"},
+		{currency: "EUR", amount: "0", err: ErrInvalidAmount},
+		{currency: "EUR", amount: "-1", err: ErrInvalidAmount},
+		{currency: "XXX", amount: "1", err: ErrUnsupportedCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+tt.amount, func(t *testing.T) {
+			err := ValidateAmount(tt.currency, decimal.RequireFromString(tt.amount))
+			if tt.err == nil {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+// TestNormalizeCurrency checks that codes are upper-cased and defaulted
+func TestNormalizeCurrency(t *testing.T) {
+	assert.Equal(t, "EUR", NormalizeCurrency(" eur "))
+	assert.Equal(t, DefaultCurrency, NormalizeCurrency(""))
+}
@@ -0,0 +1,63 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxDescriptionLength = 500
+	maxReferenceLength   = 128
+	// MaxMetadataSize is the largest metadata map accepted, measured as encoded JSON
+	MaxMetadataSize = 4096
+)
+
+// TransactionDetails are notes a caller attaches to a movement to tell it apart from others: a free
+// text description, a reference to the movement in an external system and arbitrary metadata
+type TransactionDetails struct {
+	Description string
+	Reference   string
+	Metadata    map[string]any
+}
+
+// transactionDetails are TransactionDetails ready to be stored, with empty values as NULL
+type transactionDetails struct {
+	description sql.NullString
+	reference   sql.NullString
+	metadata    []byte
+}
+
+// columns trims and checks the details and encodes the metadata
+func (d *TransactionDetails) columns() (*transactionDetails, error) {
+	var columns transactionDetails
+
+	if description := strings.TrimSpace(d.Description); description != "" {
+		if utf8.RuneCountInString(description) > maxDescriptionLength {
+			return nil, fmt.Errorf("%w: description is longer than %d characters", ErrInvalidDetails, maxDescriptionLength)
+		}
+		columns.description = sql.NullString{String: description, Valid: true}
+	}
+
+	if reference := strings.TrimSpace(d.Reference); reference != "" {
+		if len(reference) > maxReferenceLength {
+			return nil, fmt.Errorf("%w: reference is longer than %d characters", ErrInvalidDetails, maxReferenceLength)
+		}
+		columns.reference = sql.NullString{String: reference, Valid: true}
+	}
+
+	if len(d.Metadata) > 0 {
+		metadata, err := json.Marshal(d.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("%w: metadata can't be encoded: %v", ErrInvalidDetails, err)
+		}
+		if len(metadata) > MaxMetadataSize {
+			return nil, fmt.Errorf("%w: metadata is larger than %d bytes", ErrInvalidDetails, MaxMetadataSize)
+		}
+		columns.metadata = metadata
+	}
+
+	return &columns, nil
+}
@@ -0,0 +1,36 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTransactionDetailsColumns checks that details are trimmed, empty values stored as NULL and limits enforced
+func TestTransactionDetailsColumns(t *testing.T) {
+	columns, err := (&TransactionDetails{}).columns()
+	assert.NoError(t, err)
+	assert.False(t, columns.description.Valid)
+	assert.False(t, columns.reference.Valid)
+	assert.Nil(t, columns.metadata)
+
+	columns, err = (&TransactionDetails{
+		Description: " Rent for May ",
+		Reference:   "INV-2024-05",
+		Metadata:    map[string]any{"flat": "12B"},
+	}).columns()
+	assert.NoError(t, err)
+	assert.Equal(t, "Rent for May", columns.description.String)
+	assert.Equal(t, "INV-2024-05", columns.reference.String)
+	assert.JSONEq(t, `{"flat":"12B"}`, string(columns.metadata))
+
+	_, err = (&TransactionDetails{Description: strings.Repeat("я", maxDescriptionLength+1)}).columns()
+	assert.ErrorIs(t, err, ErrInvalidDetails)
+
+	_, err = (&TransactionDetails{Reference: strings.Repeat("x", maxReferenceLength+1)}).columns()
+	assert.ErrorIs(t, err, ErrInvalidDetails)
+
+	_, err = (&TransactionDetails{Metadata: map[string]any{"note": strings.Repeat("x", MaxMetadataSize)}}).columns()
+	assert.ErrorIs(t, err, ErrInvalidDetails)
+}
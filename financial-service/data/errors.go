@@ -0,0 +1,98 @@
+package data
+
+import "errors"
+
+var (
+	// ErrInsufficientBalance is returned when a debit would take a balance below zero
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	// ErrUserNotFound is returned when an operation references a user that doesn't exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidAmount is returned when an amount is zero or negative
+	ErrInvalidAmount = errors.New("amount must be positive")
+	// ErrSameAccount is returned when a transfer has the same source and destination
+	ErrSameAccount = errors.New("source and destination must differ")
+	// ErrUnbalancedEntry is returned when the postings of a journal entry don't sum to zero
+	ErrUnbalancedEntry = errors.New("unbalanced journal entry")
+	// ErrIdempotencyConflict is returned when an idempotency key is reused with a different request
+	ErrIdempotencyConflict = errors.New("idempotency key was already used with a different request")
+	// ErrUnsupportedCurrency is returned for currency codes the service doesn't handle
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	// ErrInvalidScale is returned when an amount has more decimal places than its currency allows
+	ErrInvalidScale = errors.New("amount has too many decimal places for its currency")
+	// ErrCurrencyMismatch is returned when a transfer crosses currencies without requesting a conversion
+	ErrCurrencyMismatch = errors.New("source and destination currencies differ")
+	// ErrConversionUnavailable is returned when a conversion is requested between currencies that can't be converted
+	ErrConversionUnavailable = errors.New("currency conversion is not available")
+	// ErrQuoteRequired is returned when a conversion transfer doesn't reference a quote
+	ErrQuoteRequired = errors.New("currency conversion requires a quote")
+	// ErrInvalidQuote is returned when a quote doesn't exist, expired, was already used or doesn't match the transfer
+	ErrInvalidQuote = errors.New("invalid quote")
+	// ErrUnsupportedTransactionType is returned for transaction types an operation can't create
+	ErrUnsupportedTransactionType = errors.New("unsupported transaction type")
+	// ErrInvalidParties is returned when the source and destination of a transaction don't fit its type
+	ErrInvalidParties = errors.New("invalid transaction parties")
+	// ErrTransactionNotFound is returned when an operation references a transaction that doesn't exist
+	ErrTransactionNotFound = errors.New("transaction not found")
+	// ErrNotRefundable is returned for transactions that can't be refunded
+	ErrNotRefundable = errors.New("transaction can't be refunded")
+	// ErrRefundExceedsOriginal is returned when a refund asks for more than is left of the original amount
+	ErrRefundExceedsOriginal = errors.New("refund exceeds the amount left on the transaction")
+	// ErrHoldNotFound is returned when an operation references a hold that doesn't exist
+	ErrHoldNotFound = errors.New("hold not found")
+	// ErrHoldNotActive is returned when a hold was already captured, voided or has expired
+	ErrHoldNotActive = errors.New("hold is no longer active")
+	// ErrCaptureExceedsHold is returned when a capture asks for more than the hold reserved
+	ErrCaptureExceedsHold = errors.New("capture exceeds the held amount")
+	// ErrInvalidSchedule is returned when a schedule has an invalid recurrence rule or policy
+	ErrInvalidSchedule = errors.New("invalid schedule")
+	// ErrScheduleNotFound is returned when an operation references a schedule that doesn't exist
+	ErrScheduleNotFound = errors.New("schedule not found")
+	// ErrScheduleNotActive is returned when a schedule was already completed or cancelled
+	ErrScheduleNotActive = errors.New("schedule is no longer active")
+	// ErrInvalidFeeRule is returned when a fee rule has an unsupported type or inconsistent bands or caps
+	ErrInvalidFeeRule = errors.New("invalid fee rule")
+	// ErrFeeRuleNotFound is returned when an operation references a fee rule that doesn't exist or is inactive
+	ErrFeeRuleNotFound = errors.New("fee rule not found")
+	// ErrLimitExceeded is returned, wrapped in a LimitExceededError, when a debit would exceed a spending limit
+	ErrLimitExceeded = errors.New("limit exceeded")
+	// ErrInvalidPeriod is returned when a statement period isn't a yyyy-mm month that has started
+	ErrInvalidPeriod = errors.New("invalid period")
+	// ErrUnsupportedFormat is returned when a document is requested in a format it can't be rendered in
+	ErrUnsupportedFormat = errors.New("unsupported format")
+	// ErrInvalidPayoutBatch is returned when a payout batch has no items, too many items or an invalid item
+	ErrInvalidPayoutBatch = errors.New("invalid payout batch")
+	// ErrPayoutBatchNotFound is returned when an operation references a payout batch that doesn't exist
+	ErrPayoutBatchNotFound = errors.New("payout batch not found")
+	// ErrInvalidWallet is returned when a wallet has no name or too long a name
+	ErrInvalidWallet = errors.New("invalid wallet")
+	// ErrWalletNameTaken is returned when a user already has a wallet of that name in the currency
+	ErrWalletNameTaken = errors.New("wallet name is already used")
+	// ErrWalletNotFound is returned when an operation references a wallet that doesn't exist or belongs to another user
+	ErrWalletNotFound = errors.New("wallet not found")
+	// ErrWalletClosed is returned when an operation references a wallet that has been closed
+	ErrWalletClosed = errors.New("wallet is closed")
+	// ErrWalletNotClosable is returned when closing a default wallet or one that still holds money
+	ErrWalletNotClosable = errors.New("wallet can't be closed")
+	// ErrAccountFrozen is returned when a movement would credit or debit an account frozen in that direction
+	ErrAccountFrozen = errors.New("account is frozen")
+	// ErrAccountClosed is returned when an operation references an account that has been closed
+	ErrAccountClosed = errors.New("account is closed")
+	// ErrInvalidStatusChange is returned when a status change has no reason or an unknown or unchanged status
+	ErrInvalidStatusChange = errors.New("invalid status change")
+	// ErrAccountNotClosable is returned when closing an account with active holds, an overdrawn wallet or money left and nowhere to sweep it
+	ErrAccountNotClosable = errors.New("account can't be closed")
+	// ErrInvalidUser is returned when a user has a missing or too long name or an invalid email
+	ErrInvalidUser = errors.New("invalid user")
+	// ErrEmailTaken is returned when another user already has the email
+	ErrEmailTaken = errors.New("email is already used")
+	// ErrUserNotDeletable is returned when deleting a user that holds money or has transaction history
+	ErrUserNotDeletable = errors.New("user can't be deleted")
+	// ErrInvalidDetails is returned when the description, reference or metadata of a transaction is too long or can't be stored
+	ErrInvalidDetails = errors.New("invalid transaction details")
+	// ErrInvalidCursor is returned when a history cursor can't be decoded
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidFilter is returned when a history filter has an inverted range or an unknown direction or type
+	ErrInvalidFilter = errors.New("invalid filter")
+	// ErrInvalidBalanceRequest is returned when more balances are asked for at once than MaxBalanceBatch
+	ErrInvalidBalanceRequest = errors.New("invalid balance request")
+)
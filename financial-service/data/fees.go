@@ -0,0 +1,262 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// FeeAccount is the house account that collects fees. There is one such account per currency
+const FeeAccount = "fees"
+
+// FeeRule prices the fee of transactions of one type. A rule applies to amounts in [MinAmount, MaxAmount),
+// so tiered pricing is a set of rules with adjacent bands. The fee is Flat plus Percent of the amount,
+// kept between MinFee and MaxFee. Rules without a currency match any currency and their amounts are read
+// in the currency of the transaction. When several rules match, one for the exact currency wins, then the
+// highest Priority
+type FeeRule struct {
+	ID              int                 `json:"ID"`
+	TransactionType TransactionType     `json:"TransactionType"`
+	Currency        *string             `json:"Currency"`
+	MinAmount       decimal.NullDecimal `json:"MinAmount"`
+	MaxAmount       decimal.NullDecimal `json:"MaxAmount"`
+	Flat            decimal.Decimal     `json:"Flat"`
+	Percent         decimal.Decimal     `json:"Percent"`
+	MinFee          decimal.NullDecimal `json:"MinFee"`
+	MaxFee          decimal.NullDecimal `json:"MaxFee"`
+	Priority        int                 `json:"Priority"`
+	Active          bool                `json:"Active"`
+	CreatedAt       time.Time           `json:"CreatedAt"`
+}
+
+// FeeRequest asks what fee a transaction would be charged
+type FeeRequest struct {
+	Type     TransactionType
+	Amount   decimal.Decimal
+	Currency string
+}
+
+// FeeQuote is the fee a transaction would be charged. Total is what the user is debited; RuleID is nil
+// when no rule matched and the transaction is free
+type FeeQuote struct {
+	Type     TransactionType `json:"Type"`
+	Amount   decimal.Decimal `json:"Amount"`
+	Currency string          `json:"Currency"`
+	Fee      decimal.Decimal `json:"Fee"`
+	Total    decimal.Decimal `json:"Total"`
+	RuleID   *int            `json:"RuleID"`
+}
+
+// queryRower is implemented by *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+const feeRuleColumns = `id, transaction_type, currency, min_amount, max_amount, flat, percent, min_fee, max_fee, priority, active, created_at`
+
+// scanFeeRule reads a row selected with feeRuleColumns
+func scanFeeRule(row rowScanner) (*FeeRule, error) {
+	var r FeeRule
+	err := row.Scan(&r.ID, &r.TransactionType, &r.Currency, &r.MinAmount, &r.MaxAmount, &r.Flat, &r.Percent,
+		&r.MinFee, &r.MaxFee, &r.Priority, &r.Active, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// validate checks the type, currency, bands and caps of a rule
+func (r *FeeRule) validate() error {
+	if r.TransactionType != TransactionTransfer && r.TransactionType != TransactionWithdrawal {
+		return fmt.Errorf("%w: fees apply to transfers and withdrawals, not %q", ErrInvalidFeeRule, r.TransactionType)
+	}
+	if r.Currency != nil {
+		currency := NormalizeCurrency(*r.Currency)
+		if _, err := CurrencyScale(currency); err != nil {
+			return err
+		}
+		r.Currency = &currency
+	}
+	if r.Flat.IsNegative() || r.Percent.IsNegative() || r.Percent.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+		return fmt.Errorf("%w: flat must not be negative and percent must be in [0, 1)", ErrInvalidFeeRule)
+	}
+	if r.MinAmount.Valid && r.MaxAmount.Valid && !r.MinAmount.Decimal.LessThan(r.MaxAmount.Decimal) {
+		return fmt.Errorf("%w: min amount must be below max amount", ErrInvalidFeeRule)
+	}
+	if (r.MinFee.Valid && r.MinFee.Decimal.IsNegative()) || (r.MaxFee.Valid && r.MaxFee.Decimal.IsNegative()) {
+		return fmt.Errorf("%w: fee caps must not be negative", ErrInvalidFeeRule)
+	}
+	if r.MinFee.Valid && r.MaxFee.Valid && r.MinFee.Decimal.GreaterThan(r.MaxFee.Decimal) {
+		return fmt.Errorf("%w: min fee must not exceed max fee", ErrInvalidFeeRule)
+	}
+
+	return nil
+}
+
+// compute returns the fee the rule charges on amount, rounded to scale decimal places
+func (r *FeeRule) compute(amount decimal.Decimal, scale int32) decimal.Decimal {
+	fee := r.Flat.Add(amount.Mul(r.Percent))
+	if r.MinFee.Valid && fee.LessThan(r.MinFee.Decimal) {
+		fee = r.MinFee.Decimal
+	}
+	if r.MaxFee.Valid && fee.GreaterThan(r.MaxFee.Decimal) {
+		fee = r.MaxFee.Decimal
+	}
+
+	return fee.Round(scale)
+}
+
+// quoteFee finds the rule that applies to req and computes its fee
+func quoteFee(ctx context.Context, q queryRower, req *FeeRequest) (*FeeQuote, error) {
+	currency := NormalizeCurrency(req.Currency)
+	if err := ValidateAmount(currency, req.Amount); err != nil {
+		return nil, err
+	}
+	scale, _ := CurrencyScale(currency)
+
+	quote := FeeQuote{
+		Type:     req.Type,
+		Amount:   req.Amount,
+		Currency: currency,
+		Fee:      decimal.Zero,
+		Total:    req.Amount,
+	}
+
+	query := `
+        SELECT ` + feeRuleColumns + `
+        FROM fee_rules
+        WHERE active AND transaction_type = $1 AND (currency IS NULL OR currency = $2)
+          AND (min_amount IS NULL OR min_amount <= $3) AND (max_amount IS NULL OR max_amount > $3)
+        ORDER BY currency IS NULL, priority DESC, id
+        LIMIT 1
+    `
+	rule, err := scanFeeRule(q.QueryRowContext(ctx, query, req.Type, currency, req.Amount))
+	if errors.Is(err, sql.ErrNoRows) {
+		return &quote, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fee rule: %w", err)
+	}
+
+	quote.RuleID = &rule.ID
+	quote.Fee = rule.compute(req.Amount, scale)
+	quote.Total = req.Amount.Add(quote.Fee)
+
+	return &quote, nil
+}
+
+// chargeFee debits fee from the account of userID to the house fee account inside tx, recording it as a
+// fee transaction linked to the transaction it was charged for
+func chargeFee(ctx context.Context, tx *sql.Tx, parentID, userID, accountID int, currency string, fee decimal.Decimal, now time.Time) error {
+	feeAccountID, err := systemAccountID(ctx, tx, FeeAccount, currency)
+	if err != nil {
+		return err
+	}
+
+	var transactionID int
+	stmt := `
+        INSERT INTO transactions (type, userIDSource, userIDEndpoint, source_wallet_id, amount, currency, parent_id, createdat)
+        VALUES ('fee', $1, NULL, $2, $3, $4, $5, $6)
+        RETURNING id
+    `
+	if err := tx.QueryRowContext(ctx, stmt, userID, accountID, fee, currency, parentID, now).Scan(&transactionID); err != nil {
+		return fmt.Errorf("failed to add fee transaction: %w", err)
+	}
+
+	_, err = postEntry(ctx, tx, &JournalEntry{
+		TransactionID: &transactionID,
+		Description:   "fee",
+		CreatedAt:     now,
+		Postings: []*Posting{
+			{AccountID: accountID, Currency: currency, Amount: fee.Neg()},
+			{AccountID: feeAccountID, Currency: currency, Amount: fee},
+		},
+	})
+
+	return err
+}
+
+// QuoteFee returns the fee a transaction would be charged without executing it
+func (u *PostgresRepository) QuoteFee(ctx context.Context, req *FeeRequest) (*FeeQuote, error) {
+	if req.Type != TransactionTransfer && req.Type != TransactionWithdrawal {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedTransactionType, req.Type)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	return quoteFee(ctx, db, req)
+}
+
+// CreateFeeRule validates and stores a new active fee rule
+func (u *PostgresRepository) CreateFeeRule(ctx context.Context, r *FeeRule) (*FeeRule, error) {
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	r.Active = true
+	r.CreatedAt = time.Now()
+
+	stmt := `
+        INSERT INTO fee_rules (transaction_type, currency, min_amount, max_amount, flat, percent, min_fee, max_fee, priority, active, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        RETURNING id
+    `
+	err := db.QueryRowContext(ctx, stmt, r.TransactionType, r.Currency, r.MinAmount, r.MaxAmount, r.Flat, r.Percent,
+		r.MinFee, r.MaxFee, r.Priority, r.Active, r.CreatedAt).Scan(&r.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add fee rule: %w", err)
+	}
+
+	return r, nil
+}
+
+// ListFeeRules returns the active fee rules
+func (u *PostgresRepository) ListFeeRules(ctx context.Context) ([]*FeeRule, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `SELECT ` + feeRuleColumns + ` FROM fee_rules WHERE active ORDER BY transaction_type, currency NULLS LAST, min_amount NULLS FIRST, id`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fee rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*FeeRule
+	for rows.Next() {
+		rule, err := scanFeeRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan fee rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get fee rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// DeactivateFeeRule stops a fee rule from applying to new transactions
+func (u *PostgresRepository) DeactivateFeeRule(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, `UPDATE fee_rules SET active = FALSE WHERE id = $1 AND active`, id)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate fee rule: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("fee rule %d: %w", id, ErrFeeRuleNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFeeRuleCompute checks flat and percentage fees, the caps and rounding to the currency scale
+func TestFeeRuleCompute(t *testing.T) {
+	rule := &FeeRule{Flat: decimal.RequireFromString("10"), Percent: decimal.RequireFromString("0.015")}
+	assert.Equal(t, "25", rule.compute(decimal.RequireFromString("1000"), 2).String())
+	assert.Equal(t, "10.19", rule.compute(decimal.RequireFromString("12.34"), 2).String())
+
+	rule = &FeeRule{
+		Percent: decimal.RequireFromString("0.01"),
+		MinFee:  decimal.NewNullDecimal(decimal.RequireFromString("5")),
+		MaxFee:  decimal.NewNullDecimal(decimal.RequireFromString("100")),
+	}
+	assert.Equal(t, "5", rule.compute(decimal.RequireFromString("100"), 2).String())
+	assert.Equal(t, "50", rule.compute(decimal.RequireFromString("5000"), 2).String())
+	assert.Equal(t, "100", rule.compute(decimal.RequireFromString("50000"), 2).String())
+	assert.Equal(t, "13", rule.compute(decimal.RequireFromString("1250"), 0).String())
+}
+
+// TestFeeRuleValidate checks that rules need a fee-bearing type and consistent bands and caps
+func TestFeeRuleValidate(t *testing.T) {
+	currency := "usd"
+	rule := &FeeRule{TransactionType: TransactionTransfer, Currency: &currency, Percent: decimal.RequireFromString("0.01")}
+	assert.Nil(t, rule.validate())
+	assert.Equal(t, "USD", *rule.Currency)
+
+	rule = &FeeRule{TransactionType: TransactionDeposit}
+	assert.ErrorIs(t, rule.validate(), ErrInvalidFeeRule)
+
+	rule = &FeeRule{TransactionType: TransactionWithdrawal, Percent: decimal.NewFromInt(1)}
+	assert.ErrorIs(t, rule.validate(), ErrInvalidFeeRule)
+
+	rule = &FeeRule{
+		TransactionType: TransactionWithdrawal,
+		MinAmount:       decimal.NewNullDecimal(decimal.NewFromInt(1000)),
+		MaxAmount:       decimal.NewNullDecimal(decimal.NewFromInt(100)),
+	}
+	assert.ErrorIs(t, rule.validate(), ErrInvalidFeeRule)
+
+	rule = &FeeRule{
+		TransactionType: TransactionTransfer,
+		MinFee:          decimal.NewNullDecimal(decimal.NewFromInt(10)),
+		MaxFee:          decimal.NewNullDecimal(decimal.NewFromInt(5)),
+	}
+	assert.ErrorIs(t, rule.validate(), ErrInvalidFeeRule)
+}
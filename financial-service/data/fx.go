@@ -0,0 +1,405 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FXConversionAccount is the system account that sits between the two legs of a currency conversion
+const FXConversionAccount = "fx_conversion"
+
+// FXRate is the number of Quote units one Base unit buys
+type FXRate struct {
+	Base      string          `json:"Base"`
+	Quote     string          `json:"Quote"`
+	Rate      decimal.Decimal `json:"Rate"`
+	UpdatedAt time.Time       `json:"UpdatedAt"`
+}
+
+// QuoteRequest asks for the price of converting Amount of From into To
+type QuoteRequest struct {
+	From   string
+	To     string
+	Amount decimal.Decimal
+	Spread decimal.Decimal
+	TTL    time.Duration
+}
+
+// FXQuote is a conversion price that a transfer can execute against until it expires. AppliedRate is
+// Rate with Spread taken off, ConvertedAmount is what the destination receives
+type FXQuote struct {
+	ID              string          `json:"ID"`
+	From            string          `json:"From"`
+	To              string          `json:"To"`
+	Rate            decimal.Decimal `json:"Rate"`
+	Spread          decimal.Decimal `json:"Spread"`
+	AppliedRate     decimal.Decimal `json:"AppliedRate"`
+	Amount          decimal.Decimal `json:"Amount"`
+	ConvertedAmount decimal.Decimal `json:"ConvertedAmount"`
+	CreatedAt       time.Time       `json:"CreatedAt"`
+	ExpiresAt       time.Time       `json:"ExpiresAt"`
+}
+
+// validate checks the currencies and the rate
+func (r *FXRate) validate() error {
+	r.Base = NormalizeCurrency(r.Base)
+	r.Quote = NormalizeCurrency(r.Quote)
+
+	if _, err := CurrencyScale(r.Base); err != nil {
+		return err
+	}
+	if _, err := CurrencyScale(r.Quote); err != nil {
+		return err
+	}
+	if r.Base == r.Quote {
+		return fmt.Errorf("invalid rate %s/%s: currencies must differ", r.Base, r.Quote)
+	}
+	if !r.Rate.IsPositive() {
+		return fmt.Errorf("invalid rate %s/%s: rate must be positive", r.Base, r.Quote)
+	}
+
+	return nil
+}
+
+// convert applies spread to rate and converts amount into currency, rounding down to its scale so the
+// service never pays out more than the quote covers
+func convert(amount, rate, spread decimal.Decimal, currency string) (decimal.Decimal, decimal.Decimal, error) {
+	scale, err := CurrencyScale(currency)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+
+	appliedRate := rate.Mul(decimal.NewFromInt(1).Sub(spread)).Truncate(10)
+	converted := amount.Mul(appliedRate).RoundFloor(scale)
+
+	return appliedRate, converted, nil
+}
+
+// newQuoteID returns a random identifier for a quote
+func newQuoteID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// ReadRatesFile parses rates from a local .csv file with base,quote,rate rows (an optional header row is
+// skipped) or a .json file holding a list of rates
+func ReadRatesFile(path string) ([]*FXRate, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rates file: %w", err)
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return readRatesCSV(f)
+	case ".json":
+		var rates []*FXRate
+		if err := json.NewDecoder(f).Decode(&rates); err != nil {
+			return nil, fmt.Errorf("failed to parse rates file: %w", err)
+		}
+		return rates, nil
+	default:
+		return nil, fmt.Errorf("unsupported rates file %s: expected .csv or .json", path)
+	}
+}
+
+// readRatesCSV parses base,quote,rate rows
+func readRatesCSV(r io.Reader) ([]*FXRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var rates []*FXRate
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse rates file: %w", err)
+		}
+		if line == 1 && strings.EqualFold(record[0], "base") {
+			continue
+		}
+
+		rate, err := decimal.NewFromString(record[2])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse rate on line %d: %w", line, err)
+		}
+		rates = append(rates, &FXRate{Base: record[0], Quote: record[1], Rate: rate})
+	}
+
+	return rates, nil
+}
+
+// SetRates inserts or replaces conversion rates
+func (u *PostgresRepository) SetRates(ctx context.Context, rates []*FXRate) error {
+	for _, rate := range rates {
+		if err := rate.validate(); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, rate := range rates {
+		stmt := `
+            INSERT INTO fx_rates (base, quote, rate, updated_at) VALUES ($1, $2, $3, $4)
+            ON CONFLICT (base, quote) DO UPDATE SET rate = EXCLUDED.rate, updated_at = EXCLUDED.updated_at
+        `
+		if _, err := tx.ExecContext(ctx, stmt, rate.Base, rate.Quote, rate.Rate, now); err != nil {
+			return fmt.Errorf("failed to set rate %s/%s: %w", rate.Base, rate.Quote, err)
+		}
+		rate.UpdatedAt = now
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// CreateQuote prices a conversion from the rates table. A pair that is only loaded in the opposite
+// direction is quoted at the inverse rate
+func (u *PostgresRepository) CreateQuote(ctx context.Context, req *QuoteRequest) (*FXQuote, error) {
+	quote := FXQuote{
+		From:   NormalizeCurrency(req.From),
+		To:     NormalizeCurrency(req.To),
+		Spread: req.Spread,
+		Amount: req.Amount,
+	}
+	if err := ValidateAmount(quote.From, quote.Amount); err != nil {
+		return nil, err
+	}
+	if _, err := CurrencyScale(quote.To); err != nil {
+		return nil, err
+	}
+	if quote.From == quote.To {
+		return nil, fmt.Errorf("%w: %s to itself", ErrConversionUnavailable, quote.From)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+        SELECT rate FROM fx_rates WHERE base = $1 AND quote = $2
+        UNION ALL
+        SELECT 1 / rate FROM fx_rates WHERE base = $2 AND quote = $1
+        LIMIT 1
+    `
+	err := db.QueryRowContext(ctx, query, quote.From, quote.To).Scan(&quote.Rate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: no rate for %s/%s", ErrConversionUnavailable, quote.From, quote.To)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate: %w", err)
+	}
+
+	quote.AppliedRate, quote.ConvertedAmount, err = convert(quote.Amount, quote.Rate, quote.Spread, quote.To)
+	if err != nil {
+		return nil, err
+	}
+	if !quote.ConvertedAmount.IsPositive() {
+		return nil, fmt.Errorf("%w: amount is too small to convert", ErrInvalidAmount)
+	}
+
+	quote.ID, err = newQuoteID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate quote id: %w", err)
+	}
+	quote.CreatedAt = time.Now()
+	quote.ExpiresAt = quote.CreatedAt.Add(req.TTL)
+
+	stmt := `
+        INSERT INTO fx_quotes (id, base, quote, rate, spread, applied_rate, amount, converted_amount, created_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+    `
+	_, err = db.ExecContext(ctx, stmt, quote.ID, quote.From, quote.To, quote.Rate, quote.Spread, quote.AppliedRate,
+		quote.Amount, quote.ConvertedAmount, quote.CreatedAt, quote.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store quote: %w", err)
+	}
+
+	return &quote, nil
+}
+
+// useQuote locks a quote inside tx, checks that it is still valid for req and marks it as used
+func useQuote(ctx context.Context, tx *sql.Tx, req *TransferRequest) (*FXQuote, error) {
+	var quote FXQuote
+	var usedAt sql.NullTime
+	query := `
+        SELECT id, base, quote, rate, spread, applied_rate, amount, converted_amount, created_at, expires_at, used_at
+        FROM fx_quotes
+        WHERE id = $1
+        FOR UPDATE
+    `
+	err := tx.QueryRowContext(ctx, query, req.QuoteID).Scan(&quote.ID, &quote.From, &quote.To, &quote.Rate, &quote.Spread,
+		&quote.AppliedRate, &quote.Amount, &quote.ConvertedAmount, &quote.CreatedAt, &quote.ExpiresAt, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s doesn't exist", ErrInvalidQuote, req.QuoteID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quote: %w", err)
+	}
+
+	now := time.Now()
+	switch {
+	case usedAt.Valid:
+		return nil, fmt.Errorf("%w: %s was already used", ErrInvalidQuote, quote.ID)
+	case now.After(quote.ExpiresAt):
+		return nil, fmt.Errorf("%w: %s expired at %s", ErrInvalidQuote, quote.ID, quote.ExpiresAt.Format(time.RFC3339))
+	case req.Currency != "" && NormalizeCurrency(req.Currency) != quote.From:
+		return nil, fmt.Errorf("%w: %s converts from %s", ErrInvalidQuote, quote.ID, quote.From)
+	case req.DestinationCurrency != "" && NormalizeCurrency(req.DestinationCurrency) != quote.To:
+		return nil, fmt.Errorf("%w: %s converts to %s", ErrInvalidQuote, quote.ID, quote.To)
+	case !req.Amount.Equal(quote.Amount):
+		return nil, fmt.Errorf("%w: %s is for %s %s", ErrInvalidQuote, quote.ID, quote.Amount.String(), quote.From)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE fx_quotes SET used_at = $1 WHERE id = $2`, now, quote.ID); err != nil {
+		return nil, fmt.Errorf("failed to use quote: %w", err)
+	}
+
+	return &quote, nil
+}
+
+// conversionTransfer executes a transfer against a quote: the source is debited in the quote's From
+// currency, the destination credited with the converted amount in its To currency, and the two legs
+// are bridged through the FX conversion account. Like any move, a conversion between two wallets of one
+// user is charged no fee and doesn't count towards spending limits
+func conversionTransfer(ctx context.Context, tx *sql.Tx, req *TransferRequest) (int, error) {
+	details, err := req.columns()
+	if err != nil {
+		return 0, err
+	}
+
+	quote, err := useQuote(ctx, tx, req)
+	if err != nil {
+		return 0, err
+	}
+
+	sourceAccountID, _, err := walletAccountID(ctx, tx, &req.From, req.FromWalletID, quote.From)
+	if err != nil {
+		return 0, fmt.Errorf("source %w", err)
+	}
+	destinationAccountID, _, err := walletAccountID(ctx, tx, &req.To, req.ToWalletID, quote.To)
+	if err != nil {
+		return 0, fmt.Errorf("destination %w", err)
+	}
+	if sourceAccountID == destinationAccountID {
+		return 0, ErrSameAccount
+	}
+	fxSourceID, err := systemAccountID(ctx, tx, FXConversionAccount, quote.From)
+	if err != nil {
+		return 0, err
+	}
+	fxDestinationID, err := systemAccountID(ctx, tx, FXConversionAccount, quote.To)
+	if err != nil {
+		return 0, err
+	}
+
+	// Converting between the wallets of one user isn't spending it
+	move := req.From == req.To
+	fee := &FeeQuote{Type: TransactionTransfer, Amount: quote.Amount, Currency: quote.From, Total: quote.Amount}
+	locked := []int{sourceAccountID, destinationAccountID}
+	if !move {
+		if fee, err = quoteFee(ctx, tx, &FeeRequest{Type: TransactionTransfer, Amount: quote.Amount, Currency: quote.From}); err != nil {
+			return 0, err
+		}
+		limitID, err := limitAccountID(ctx, tx, req.From, quote.From)
+		if err != nil {
+			return 0, fmt.Errorf("source %w", err)
+		}
+		locked = append(locked, limitID)
+	}
+
+	if err := checkAccountStatus(ctx, tx, req.From, flowOut); err != nil {
+		return 0, fmt.Errorf("source %w", err)
+	}
+	if err := checkAccountStatus(ctx, tx, req.To, flowIn); err != nil {
+		return 0, fmt.Errorf("destination %w", err)
+	}
+	balances, err := lockAccounts(ctx, tx, locked...)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkWalletsOpen(ctx, tx, sourceAccountID, destinationAccountID); err != nil {
+		return 0, err
+	}
+
+	sourceBalance := balances[sourceAccountID]
+	if sourceBalance.LessThan(fee.Total) {
+		return 0, fmt.Errorf("%w: cannot transfer %s %s with a fee of %s, available balance is %s", ErrInsufficientBalance, quote.Amount.String(), quote.From, fee.Fee.String(), sourceBalance.String())
+	}
+
+	now := time.Now()
+	if !move {
+		if err := checkSpendingLimits(ctx, tx, req.From, quote.From, quote.Amount, now); err != nil {
+			return 0, err
+		}
+	}
+
+	var transactionID int
+	stmt := `
+        INSERT INTO transactions (type, userIDSource, userIDEndpoint, source_wallet_id, destination_wallet_id, amount, currency,
+                                  destination_amount, destination_currency, fx_rate, fx_quote_id, createdat,
+                                  description, external_reference, metadata)
+        VALUES ('transfer', $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+        RETURNING id
+    `
+	err = tx.QueryRowContext(ctx, stmt, req.From, req.To, sourceAccountID, destinationAccountID, quote.Amount, quote.From,
+		quote.ConvertedAmount, quote.To, quote.AppliedRate, quote.ID, now, details.description, details.reference,
+		details.metadata).Scan(&transactionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add transaction: %w", err)
+	}
+
+	_, err = postEntry(ctx, tx, &JournalEntry{
+		TransactionID: &transactionID,
+		Description:   "conversion transfer",
+		CreatedAt:     now,
+		Postings: []*Posting{
+			{AccountID: sourceAccountID, Currency: quote.From, Amount: quote.Amount.Neg()},
+			{AccountID: fxSourceID, Currency: quote.From, Amount: quote.Amount},
+			{AccountID: fxDestinationID, Currency: quote.To, Amount: quote.ConvertedAmount.Neg()},
+			{AccountID: destinationAccountID, Currency: quote.To, Amount: quote.ConvertedAmount},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if fee.Fee.IsPositive() {
+		if err := chargeFee(ctx, tx, transactionID, req.From, sourceAccountID, quote.From, fee.Fee, now); err != nil {
+			return 0, err
+		}
+	}
+
+	return transactionID, nil
+}
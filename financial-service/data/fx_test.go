@@ -0,0 +1,50 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConvert checks that the spread is applied and the result is rounded down to the target scale
+func TestConvert(t *testing.T) {
+	applied, converted, err := convert(decimal.RequireFromString("100"), decimal.RequireFromString("1.0850"), decimal.RequireFromString("0.005"), "USD")
+	assert.Nil(t, err)
+	assert.Equal(t, "1.079575", applied.String())
+	assert.Equal(t, "107.95", converted.String())
+
+	_, converted, err = convert(decimal.RequireFromString("10"), decimal.RequireFromString("162.37"), decimal.Zero, "JPY")
+	assert.Nil(t, err)
+	assert.Equal(t, "1623", converted.String())
+
+	_, _, err = convert(decimal.RequireFromString("10"), decimal.RequireFromString("1"), decimal.Zero, "XXX")
+	assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+}
+
+// TestReadRatesCSV checks parsing of rate files with and without a header
+func TestReadRatesCSV(t *testing.T) {
+	rates, err := readRatesCSV(strings.NewReader("base,quote,rate\nEUR,USD,1.085\nUSD, RUB, 92.5\n"))
+	assert.Nil(t, err)
+	assert.Len(t, rates, 2)
+	assert.Equal(t, "EUR", rates[0].Base)
+	assert.Equal(t, "USD", rates[0].Quote)
+	assert.True(t, rates[0].Rate.Equal(decimal.RequireFromString("1.085")))
+	assert.Equal(t, "RUB", rates[1].Quote)
+
+	rates, err = readRatesCSV(strings.NewReader("EUR,USD,1.085\n"))
+	assert.Nil(t, err)
+	assert.Len(t, rates, 1)
+
+	_, err = readRatesCSV(strings.NewReader("EUR,USD,abc\n"))
+	assert.NotNil(t, err)
+}
+
+// TestFXRateValidate checks that rates need two distinct supported currencies and a positive rate
+func TestFXRateValidate(t *testing.T) {
+	assert.Nil(t, (&FXRate{Base: "eur", Quote: "usd", Rate: decimal.RequireFromString("1.08")}).validate())
+	assert.NotNil(t, (&FXRate{Base: "EUR", Quote: "EUR", Rate: decimal.RequireFromString("1")}).validate())
+	assert.NotNil(t, (&FXRate{Base: "EUR", Quote: "USD", Rate: decimal.Zero}).validate())
+	assert.ErrorIs(t, (&FXRate{Base: "EUR", Quote: "XXX", Rate: decimal.RequireFromString("1")}).validate(), ErrUnsupportedCurrency)
+}
@@ -0,0 +1,365 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultHistoryLimit is the page size of the transaction history when the caller doesn't ask for one
+	DefaultHistoryLimit = 20
+	// MaxHistoryLimit is the largest page of transaction history that can be requested
+	MaxHistoryLimit = 100
+)
+
+// HistoryCursor marks a position in a user's transaction history, which is ordered newest first by
+// creation time and id. A forward cursor continues with older transactions, a backward one returns to
+// newer ones. Cursors refer to rows rather than offsets, so pages don't shift while transactions arrive
+type HistoryCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"id"`
+	Backward  bool      `json:"b,omitempty"`
+}
+
+// Encode returns the cursor as an opaque URL-safe string
+func (c *HistoryCursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// ParseHistoryCursor decodes a cursor returned by Encode
+func ParseHistoryCursor(s string) (*HistoryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	var c HistoryCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if c.ID <= 0 || c.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// HistoryDirection narrows a history to the money a user received or sent
+type HistoryDirection string
+
+const (
+	HistoryIncoming HistoryDirection = "incoming"
+	HistoryOutgoing HistoryDirection = "outgoing"
+)
+
+// HistoryFilter narrows a user's transaction history. From is inclusive and To exclusive; amounts are
+// compared in the currency the transaction was made in. CounterpartyID is the other user of a movement,
+// on whichever side Direction leaves open. Zero fields don't filter
+type HistoryFilter struct {
+	From           *time.Time
+	To             *time.Time
+	MinAmount      decimal.NullDecimal
+	MaxAmount      decimal.NullDecimal
+	Direction      HistoryDirection
+	CounterpartyID int
+	Types          []TransactionType
+	Reference      string
+}
+
+// validate checks that the filter's ranges aren't inverted and its enumerations are known
+func (f *HistoryFilter) validate() error {
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return fmt.Errorf("%w: from must be before to", ErrInvalidFilter)
+	}
+	if f.MinAmount.Valid && f.MaxAmount.Valid && f.MinAmount.Decimal.GreaterThan(f.MaxAmount.Decimal) {
+		return fmt.Errorf("%w: minimum amount is above the maximum", ErrInvalidFilter)
+	}
+	switch f.Direction {
+	case "", HistoryIncoming, HistoryOutgoing:
+	default:
+		return fmt.Errorf("%w: direction must be incoming or outgoing", ErrInvalidFilter)
+	}
+	if f.CounterpartyID < 0 {
+		return fmt.Errorf("%w: invalid counterparty", ErrInvalidFilter)
+	}
+	for _, t := range f.Types {
+		switch t {
+		case TransactionDeposit, TransactionWithdrawal, TransactionTransfer, TransactionFee, TransactionRefund, TransactionAdjustment:
+		default:
+			return fmt.Errorf("%w: unknown transaction type %q", ErrInvalidFilter, t)
+		}
+	}
+
+	return nil
+}
+
+// conditions returns the WHERE conditions selecting the transactions of userID that pass the filter,
+// adding their values to args
+func (f *HistoryFilter) conditions(userID int, args *queryArgs) []string {
+	user := args.add(userID)
+
+	var conditions []string
+	switch {
+	case f.Direction == HistoryIncoming && f.CounterpartyID != 0:
+		conditions = append(conditions, fmt.Sprintf("useridendpoint = %s AND useridsource = %s", user, args.add(f.CounterpartyID)))
+	case f.Direction == HistoryOutgoing && f.CounterpartyID != 0:
+		conditions = append(conditions, fmt.Sprintf("useridsource = %s AND useridendpoint = %s", user, args.add(f.CounterpartyID)))
+	case f.Direction == HistoryIncoming:
+		conditions = append(conditions, "useridendpoint = "+user)
+	case f.Direction == HistoryOutgoing:
+		conditions = append(conditions, "useridsource = "+user)
+	case f.CounterpartyID != 0:
+		counterparty := args.add(f.CounterpartyID)
+		conditions = append(conditions, fmt.Sprintf("((useridsource = %s AND useridendpoint = %s) OR (useridendpoint = %s AND useridsource = %s))",
+			user, counterparty, user, counterparty))
+	default:
+		conditions = append(conditions, fmt.Sprintf("(useridsource = %s OR useridendpoint = %s)", user, user))
+	}
+
+	if f.From != nil {
+		conditions = append(conditions, "createdat >= "+args.add(*f.From))
+	}
+	if f.To != nil {
+		conditions = append(conditions, "createdat < "+args.add(*f.To))
+	}
+	if f.MinAmount.Valid {
+		conditions = append(conditions, "amount >= "+args.add(f.MinAmount.Decimal))
+	}
+	if f.MaxAmount.Valid {
+		conditions = append(conditions, "amount <= "+args.add(f.MaxAmount.Decimal))
+	}
+	if len(f.Types) > 0 {
+		placeholders := make([]string, len(f.Types))
+		for i, t := range f.Types {
+			placeholders[i] = args.add(string(t))
+		}
+		conditions = append(conditions, "type IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if reference := strings.TrimSpace(f.Reference); reference != "" {
+		conditions = append(conditions, "external_reference = "+args.add(reference))
+	}
+
+	return conditions
+}
+
+// queryArgs collects the arguments of a parameterized query
+type queryArgs []any
+
+// add appends an argument and returns its placeholder
+func (a *queryArgs) add(v any) string {
+	*a = append(*a, v)
+	return fmt.Sprintf("$%d", len(*a))
+}
+
+// HistoryRequest asks for a page of up to Limit transactions of a user that pass Filter, starting at
+// Cursor or at the newest transaction without one
+type HistoryRequest struct {
+	UserID int
+	Limit  int
+	Cursor *HistoryCursor
+	Filter HistoryFilter
+}
+
+// TransactionPage is a page of transaction history. NextCursor leads to older transactions and
+// PrevCursor back to newer ones; each is empty when there is nothing in that direction
+type TransactionPage struct {
+	Transactions []*Transactions `json:"Transactions"`
+	NextCursor   string          `json:"NextCursor"`
+	PrevCursor   string          `json:"PrevCursor"`
+}
+
+// GetTransactionHistory returns a page of the transactions of a user, newest first
+func (u *PostgresRepository) GetTransactionHistory(ctx context.Context, req *HistoryRequest) (*TransactionPage, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	limit = min(limit, MaxHistoryLimit)
+
+	if err := req.Filter.validate(); err != nil {
+		return nil, err
+	}
+
+	query, args := historyQuery(req, limit)
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	transactions := []*Transactions{}
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		transactions = append(transactions, transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+
+	return newTransactionPage(transactions, limit, req.Cursor), nil
+}
+
+// TransactionStatus is the state of a transaction. Movements complete when they are recorded; refunds
+// move a transaction on to partially refunded and then refunded
+type TransactionStatus string
+
+const (
+	TransactionCompleted         TransactionStatus = "completed"
+	TransactionPartiallyRefunded TransactionStatus = "partially_refunded"
+	TransactionRefunded          TransactionStatus = "refunded"
+)
+
+// TransactionRecord is a transaction together with its status and the transactions linked to it: Parent
+// is the transaction a fee or refund belongs to, Linked the fees and refunds that belong to this one,
+// oldest first. RefundedAt is when the latest refund was made
+type TransactionRecord struct {
+	*Transactions
+	Status     TransactionStatus `json:"Status"`
+	Refunded   decimal.Decimal   `json:"Refunded"`
+	RefundedAt *time.Time        `json:"RefundedAt"`
+	Parent     *Transactions     `json:"Parent"`
+	Linked     []*Transactions   `json:"Linked"`
+}
+
+// GetTransaction returns a transaction by its id with the transactions linked to it
+func (u *PostgresRepository) GetTransaction(ctx context.Context, id int) (*TransactionRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	transaction, err := scanTransaction(db.QueryRowContext(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("transaction %d: %w", id, ErrTransactionNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+
+	record := &TransactionRecord{Transactions: transaction, Linked: []*Transactions{}}
+	if transaction.ParentID != nil {
+		query := `SELECT ` + transactionColumns + ` FROM transactions WHERE id = $1`
+		if record.Parent, err = scanTransaction(db.QueryRowContext(ctx, query, *transaction.ParentID)); err != nil {
+			return nil, fmt.Errorf("failed to get parent transaction: %w", err)
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE parent_id = $1 ORDER BY createdat, id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query linked transactions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		linked, err := scanTransaction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		record.Linked = append(record.Linked, linked)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query linked transactions: %w", err)
+	}
+
+	record.settle()
+
+	return record, nil
+}
+
+// settle derives the status of the record from the refunds among its linked transactions
+func (r *TransactionRecord) settle() {
+	r.Status, r.Refunded, r.RefundedAt = TransactionCompleted, decimal.Zero, nil
+	for _, linked := range r.Linked {
+		if linked.Type != TransactionRefund {
+			continue
+		}
+		r.Refunded = r.Refunded.Add(linked.Amount)
+		r.RefundedAt = &linked.CreatedAt
+	}
+
+	switch {
+	case !r.Refunded.IsPositive():
+	case r.Refunded.GreaterThanOrEqual(r.Amount):
+		r.Status = TransactionRefunded
+	default:
+		r.Status = TransactionPartiallyRefunded
+	}
+}
+
+// historyQuery builds the query selecting limit+1 transactions of a history page, the extra row telling
+// whether there is anything beyond the page. Pages going backward are selected oldest first
+func historyQuery(req *HistoryRequest, limit int) (string, []any) {
+	var args queryArgs
+	conditions := req.Filter.conditions(req.UserID, &args)
+
+	order := "DESC"
+	if req.Cursor != nil {
+		comparison := "<"
+		if req.Cursor.Backward {
+			comparison, order = ">", "ASC"
+		}
+		conditions = append(conditions, fmt.Sprintf("(createdat, id) %s (%s, %s)", comparison, args.add(req.Cursor.CreatedAt), args.add(req.Cursor.ID)))
+	}
+
+	query := `
+        SELECT ` + transactionColumns + `
+        FROM transactions
+        WHERE ` + strings.Join(conditions, " AND ") + `
+        ORDER BY createdat ` + order + `, id ` + order + `
+        LIMIT ` + args.add(limit+1)
+
+	return query, args
+}
+
+// newTransactionPage trims rows, fetched with one row beyond limit in the direction of cursor, to a
+// page ordered newest first and sets the cursors leading away from it
+func newTransactionPage(rows []*Transactions, limit int, cursor *HistoryCursor) *TransactionPage {
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+
+	backward := cursor != nil && cursor.Backward
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	page := &TransactionPage{Transactions: rows}
+	if len(rows) == 0 {
+		// An empty page past either end of the history leads back the way it came
+		if cursor != nil {
+			turned := &HistoryCursor{CreatedAt: cursor.CreatedAt, ID: cursor.ID, Backward: !backward}
+			if backward {
+				page.NextCursor = turned.Encode()
+			} else {
+				page.PrevCursor = turned.Encode()
+			}
+		}
+		return page
+	}
+
+	first, last := rows[0], rows[len(rows)-1]
+	if backward || more {
+		page.NextCursor = (&HistoryCursor{CreatedAt: last.CreatedAt, ID: last.ID}).Encode()
+	}
+	if (backward && more) || (!backward && cursor != nil) {
+		page.PrevCursor = (&HistoryCursor{CreatedAt: first.CreatedAt, ID: first.ID, Backward: true}).Encode()
+	}
+
+	return page
+}
@@ -0,0 +1,304 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// historyRows returns transactions with the given ids, created a minute apart in id order
+func historyRows(ids ...int) []*Transactions {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	rows := make([]*Transactions, len(ids))
+	for i, id := range ids {
+		rows[i] = &Transactions{ID: id, CreatedAt: start.Add(time.Duration(id) * time.Minute)}
+	}
+	return rows
+}
+
+// pageIDs returns the ids of the transactions on a page
+func pageIDs(page *TransactionPage) []int {
+	ids := []int{}
+	for _, t := range page.Transactions {
+		ids = append(ids, t.ID)
+	}
+	return ids
+}
+
+// TestHistoryCursorRoundTrip checks that cursors survive encoding and garbage is refused
+func TestHistoryCursorRoundTrip(t *testing.T) {
+	cursor := &HistoryCursor{CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC), ID: 42, Backward: true}
+
+	parsed, err := ParseHistoryCursor(cursor.Encode())
+	assert.NoError(t, err)
+	assert.True(t, cursor.CreatedAt.Equal(parsed.CreatedAt))
+	assert.Equal(t, 42, parsed.ID)
+	assert.True(t, parsed.Backward)
+
+	for _, invalid := range []string{"", "not base64!", "e30"} {
+		_, err := ParseHistoryCursor(invalid)
+		assert.ErrorIs(t, err, ErrInvalidCursor, invalid)
+	}
+}
+
+// TestNewTransactionPage_Forward checks the first and a middle page walking towards older transactions
+func TestNewTransactionPage_Forward(t *testing.T) {
+	page := newTransactionPage(historyRows(10, 9, 8), 2, nil)
+	assert.Equal(t, []int{10, 9}, pageIDs(page))
+	assert.Empty(t, page.PrevCursor)
+
+	next, err := ParseHistoryCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, next.ID)
+	assert.False(t, next.Backward)
+
+	page = newTransactionPage(historyRows(8, 7), 2, next)
+	assert.Equal(t, []int{8, 7}, pageIDs(page))
+	assert.Empty(t, page.NextCursor)
+
+	prev, err := ParseHistoryCursor(page.PrevCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, prev.ID)
+	assert.True(t, prev.Backward)
+}
+
+// TestNewTransactionPage_Backward checks that pages fetched oldest first are returned newest first
+func TestNewTransactionPage_Backward(t *testing.T) {
+	cursor := &HistoryCursor{CreatedAt: historyRows(7)[0].CreatedAt, ID: 7, Backward: true}
+
+	page := newTransactionPage(historyRows(8, 9, 10), 2, cursor)
+	assert.Equal(t, []int{9, 8}, pageIDs(page))
+	assert.NotEmpty(t, page.PrevCursor)
+	assert.NotEmpty(t, page.NextCursor)
+
+	page = newTransactionPage(historyRows(8, 9), 2, cursor)
+	assert.Equal(t, []int{9, 8}, pageIDs(page))
+	assert.Empty(t, page.PrevCursor)
+
+	next, err := ParseHistoryCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, next.ID)
+	assert.False(t, next.Backward)
+}
+
+// TestNewTransactionPage_Empty checks that an empty page past the end leads back
+func TestNewTransactionPage_Empty(t *testing.T) {
+	page := newTransactionPage(nil, 2, nil)
+	assert.Empty(t, page.Transactions)
+	assert.Empty(t, page.NextCursor)
+	assert.Empty(t, page.PrevCursor)
+
+	cursor := &HistoryCursor{CreatedAt: historyRows(3)[0].CreatedAt, ID: 3}
+	page = newTransactionPage(nil, 2, cursor)
+	assert.Empty(t, page.NextCursor)
+
+	prev, err := ParseHistoryCursor(page.PrevCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, prev.ID)
+	assert.True(t, prev.Backward)
+}
+
+// TestHistoryFilterValidate checks that inverted ranges and unknown enumerations are refused
+func TestHistoryFilterValidate(t *testing.T) {
+	may := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	valid := []HistoryFilter{
+		{},
+		{From: &may, To: &june},
+		{MinAmount: decimal.NewNullDecimal(decimal.NewFromInt(5)), MaxAmount: decimal.NewNullDecimal(decimal.NewFromInt(5))},
+		{Direction: HistoryIncoming, CounterpartyID: 3, Types: []TransactionType{TransactionDeposit, TransactionAdjustment}},
+	}
+	for _, f := range valid {
+		assert.NoError(t, f.validate())
+	}
+
+	invalid := []HistoryFilter{
+		{From: &june, To: &may},
+		{From: &may, To: &may},
+		{MinAmount: decimal.NewNullDecimal(decimal.NewFromInt(6)), MaxAmount: decimal.NewNullDecimal(decimal.NewFromInt(5))},
+		{Direction: "sideways"},
+		{CounterpartyID: -1},
+		{Types: []TransactionType{TransactionTransfer, "gift"}},
+	}
+	for _, f := range invalid {
+		assert.ErrorIs(t, f.validate(), ErrInvalidFilter)
+	}
+}
+
+// TestHistoryFilterConditions checks the conditions and arguments built for each filter and their combinations
+func TestHistoryFilterConditions(t *testing.T) {
+	may := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	ten := decimal.NewFromInt(10)
+	hundred := decimal.NewFromInt(100)
+
+	tests := []struct {
+		name       string
+		filter     HistoryFilter
+		conditions []string
+		args       []any
+	}{
+		{
+			name:       "none",
+			conditions: []string{"(useridsource = $1 OR useridendpoint = $1)"},
+			args:       []any{7},
+		},
+		{
+			name:       "incoming",
+			filter:     HistoryFilter{Direction: HistoryIncoming},
+			conditions: []string{"useridendpoint = $1"},
+			args:       []any{7},
+		},
+		{
+			name:       "outgoing",
+			filter:     HistoryFilter{Direction: HistoryOutgoing},
+			conditions: []string{"useridsource = $1"},
+			args:       []any{7},
+		},
+		{
+			name:       "counterparty",
+			filter:     HistoryFilter{CounterpartyID: 3},
+			conditions: []string{"((useridsource = $1 AND useridendpoint = $2) OR (useridendpoint = $1 AND useridsource = $2))"},
+			args:       []any{7, 3},
+		},
+		{
+			name:       "incoming from counterparty",
+			filter:     HistoryFilter{Direction: HistoryIncoming, CounterpartyID: 3},
+			conditions: []string{"useridendpoint = $1 AND useridsource = $2"},
+			args:       []any{7, 3},
+		},
+		{
+			name:       "outgoing to counterparty",
+			filter:     HistoryFilter{Direction: HistoryOutgoing, CounterpartyID: 3},
+			conditions: []string{"useridsource = $1 AND useridendpoint = $2"},
+			args:       []any{7, 3},
+		},
+		{
+			name:       "date range",
+			filter:     HistoryFilter{From: &may, To: &june},
+			conditions: []string{"(useridsource = $1 OR useridendpoint = $1)", "createdat >= $2", "createdat < $3"},
+			args:       []any{7, may, june},
+		},
+		{
+			name:       "from only",
+			filter:     HistoryFilter{From: &may},
+			conditions: []string{"(useridsource = $1 OR useridendpoint = $1)", "createdat >= $2"},
+			args:       []any{7, may},
+		},
+		{
+			name:       "amount range",
+			filter:     HistoryFilter{MinAmount: decimal.NewNullDecimal(ten), MaxAmount: decimal.NewNullDecimal(hundred)},
+			conditions: []string{"(useridsource = $1 OR useridendpoint = $1)", "amount >= $2", "amount <= $3"},
+			args:       []any{7, ten, hundred},
+		},
+		{
+			name:       "max amount only",
+			filter:     HistoryFilter{MaxAmount: decimal.NewNullDecimal(hundred)},
+			conditions: []string{"(useridsource = $1 OR useridendpoint = $1)", "amount <= $2"},
+			args:       []any{7, hundred},
+		},
+		{
+			name:       "types",
+			filter:     HistoryFilter{Types: []TransactionType{TransactionDeposit, TransactionRefund}},
+			conditions: []string{"(useridsource = $1 OR useridendpoint = $1)", "type IN ($2, $3)"},
+			args:       []any{7, "deposit", "refund"},
+		},
+		{
+			name:       "reference",
+			filter:     HistoryFilter{Reference: " INV-1 "},
+			conditions: []string{"(useridsource = $1 OR useridendpoint = $1)", "external_reference = $2"},
+			args:       []any{7, "INV-1"},
+		},
+		{
+			name: "everything",
+			filter: HistoryFilter{
+				From:           &may,
+				To:             &june,
+				MinAmount:      decimal.NewNullDecimal(ten),
+				MaxAmount:      decimal.NewNullDecimal(hundred),
+				Direction:      HistoryOutgoing,
+				CounterpartyID: 3,
+				Types:          []TransactionType{TransactionTransfer},
+				Reference:      "INV-1",
+			},
+			conditions: []string{
+				"useridsource = $1 AND useridendpoint = $2",
+				"createdat >= $3",
+				"createdat < $4",
+				"amount >= $5",
+				"amount <= $6",
+				"type IN ($7)",
+				"external_reference = $8",
+			},
+			args: []any{7, 3, may, june, ten, hundred, "transfer", "INV-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args queryArgs
+			assert.Equal(t, tt.conditions, tt.filter.conditions(7, &args))
+			assert.Equal(t, queryArgs(tt.args), args)
+		})
+	}
+}
+
+// TestHistoryQuery_Cursor checks that the cursor condition and page size come after the filter's arguments
+func TestHistoryQuery_Cursor(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	query, args := historyQuery(&HistoryRequest{
+		UserID: 7,
+		Cursor: &HistoryCursor{CreatedAt: at, ID: 40, Backward: true},
+		Filter: HistoryFilter{Direction: HistoryIncoming},
+	}, 20)
+
+	assert.Contains(t, query, "WHERE useridendpoint = $1 AND (createdat, id) > ($2, $3)")
+	assert.Contains(t, query, "ORDER BY createdat ASC, id ASC")
+	assert.Contains(t, query, "LIMIT $4")
+	assert.Equal(t, []any{7, at, 40, 21}, args)
+
+	query, args = historyQuery(&HistoryRequest{UserID: 7}, 20)
+	assert.Contains(t, query, "ORDER BY createdat DESC, id DESC")
+	assert.Contains(t, query, "LIMIT $2")
+	assert.Equal(t, []any{7, 21}, args)
+}
+
+// TestTransactionRecordSettle checks that the status follows the refunds linked to a transaction
+func TestTransactionRecordSettle(t *testing.T) {
+	refund := func(id int, amount int64) *Transactions {
+		return &Transactions{ID: id, Type: TransactionRefund, Amount: decimal.NewFromInt(amount), CreatedAt: time.Date(2024, 5, id, 0, 0, 0, 0, time.UTC)}
+	}
+	fee := &Transactions{ID: 9, Type: TransactionFee, Amount: decimal.NewFromInt(1)}
+
+	tests := []struct {
+		name     string
+		linked   []*Transactions
+		status   TransactionStatus
+		refunded int64
+		lastID   int
+	}{
+		{"no links", nil, TransactionCompleted, 0, 0},
+		{"fee only", []*Transactions{fee}, TransactionCompleted, 0, 0},
+		{"partial refund", []*Transactions{fee, refund(2, 40)}, TransactionPartiallyRefunded, 40, 2},
+		{"several refunds", []*Transactions{refund(2, 40), fee, refund(3, 60)}, TransactionRefunded, 100, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := &TransactionRecord{Transactions: &Transactions{ID: 1, Amount: decimal.NewFromInt(100)}, Linked: tt.linked}
+			record.settle()
+
+			assert.Equal(t, tt.status, record.Status)
+			assert.True(t, decimal.NewFromInt(tt.refunded).Equal(record.Refunded))
+			if tt.lastID == 0 {
+				assert.Nil(t, record.RefundedAt)
+			} else {
+				assert.Equal(t, time.Date(2024, 5, tt.lastID, 0, 0, 0, 0, time.UTC), *record.RefundedAt)
+			}
+		})
+	}
+}
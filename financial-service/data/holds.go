@@ -0,0 +1,297 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// HoldStatus is the lifecycle state of a hold
+type HoldStatus string
+
+const (
+	HoldAuthorized HoldStatus = "authorized"
+	HoldCaptured   HoldStatus = "captured"
+	HoldVoided     HoldStatus = "voided"
+	HoldExpired    HoldStatus = "expired"
+)
+
+// HoldRequest asks to reserve Amount of a user's balance for a later transfer to DestinationID
+type HoldRequest struct {
+	UserID        int
+	DestinationID int
+	Amount        decimal.Decimal
+	Currency      string
+	TTL           time.Duration
+}
+
+// Hold is a reservation of funds. While authorized it lowers the available balance of the user but not
+// the ledger balance; capturing turns it into a transfer. Balance is the user's balance after the last
+// operation on the hold
+type Hold struct {
+	ID             int                 `json:"ID"`
+	UserID         int                 `json:"UserID"`
+	DestinationID  int                 `json:"DestinationID"`
+	Amount         decimal.Decimal     `json:"Amount"`
+	CapturedAmount decimal.NullDecimal `json:"CapturedAmount"`
+	Currency       string              `json:"Currency"`
+	Status         HoldStatus          `json:"Status"`
+	TransactionID  *int                `json:"TransactionID"`
+	CreatedAt      time.Time           `json:"CreatedAt"`
+	UpdatedAt      time.Time           `json:"UpdatedAt"`
+	ExpiresAt      time.Time           `json:"ExpiresAt"`
+	Balance        *Balance            `json:"Balance,omitempty"`
+
+	accountID int
+}
+
+// AuthorizeHold reserves funds on the user's account. It fails when the available balance can't cover the hold
+func (u *PostgresRepository) AuthorizeHold(ctx context.Context, req *HoldRequest) (*Hold, error) {
+	currency := NormalizeCurrency(req.Currency)
+	if err := ValidateAmount(currency, req.Amount); err != nil {
+		return nil, err
+	}
+	if req.UserID == req.DestinationID {
+		return nil, ErrSameAccount
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	accountID, err := userAccountID(ctx, tx, req.UserID, currency)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := userAccountID(ctx, tx, req.DestinationID, currency); err != nil {
+		return nil, fmt.Errorf("destination %w", err)
+	}
+
+	if err := checkAccountStatus(ctx, tx, req.UserID, flowOut); err != nil {
+		return nil, err
+	}
+	if err := checkAccountStatus(ctx, tx, req.DestinationID, flowIn); err != nil {
+		return nil, fmt.Errorf("destination %w", err)
+	}
+	available, err := lockAccounts(ctx, tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if available[accountID].LessThan(req.Amount) {
+		return nil, fmt.Errorf("%w: cannot hold %s %s, available balance is %s", ErrInsufficientBalance, req.Amount.String(), currency, available[accountID].String())
+	}
+
+	now := time.Now()
+	hold := Hold{
+		UserID:        req.UserID,
+		DestinationID: req.DestinationID,
+		Amount:        req.Amount,
+		Currency:      currency,
+		Status:        HoldAuthorized,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		ExpiresAt:     now.Add(req.TTL),
+		accountID:     accountID,
+	}
+
+	stmt := `
+        INSERT INTO holds (user_id, account_id, destination_id, amount, currency, status, created_at, updated_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id
+    `
+	err = tx.QueryRowContext(ctx, stmt, hold.UserID, hold.accountID, hold.DestinationID, hold.Amount, hold.Currency,
+		hold.Status, hold.CreatedAt, hold.UpdatedAt, hold.ExpiresAt).Scan(&hold.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add hold: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE accounts SET held = held + $1 WHERE id = $2`, hold.Amount, accountID); err != nil {
+		return nil, fmt.Errorf("failed to reserve funds: %w", err)
+	}
+
+	if hold.Balance, err = accountBalance(ctx, tx, accountID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &hold, nil
+}
+
+// CaptureHold transfers amount of an authorized hold to its destination and releases the rest of the
+// reservation. A zero amount captures the whole hold
+func (u *PostgresRepository) CaptureHold(ctx context.Context, id int, amount decimal.Decimal) (*Hold, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	hold, err := releaseHold(ctx, tx, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount.IsZero() {
+		amount = hold.Amount
+	}
+	if amount.GreaterThan(hold.Amount) {
+		return nil, fmt.Errorf("%w: hold %d is for %s %s", ErrCaptureExceedsHold, hold.ID, hold.Amount.String(), hold.Currency)
+	}
+
+	transactionID, err := transfer(ctx, tx, &TransferRequest{
+		From:     hold.UserID,
+		To:       hold.DestinationID,
+		Amount:   amount,
+		Currency: hold.Currency,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hold.Status = HoldCaptured
+	hold.CapturedAmount = decimal.NewNullDecimal(amount)
+	hold.TransactionID = &transactionID
+
+	stmt := `UPDATE holds SET status = $1, captured_amount = $2, transaction_id = $3, updated_at = $4 WHERE id = $5`
+	if _, err := tx.ExecContext(ctx, stmt, hold.Status, amount, transactionID, hold.UpdatedAt, hold.ID); err != nil {
+		return nil, fmt.Errorf("failed to capture hold: %w", err)
+	}
+
+	if hold.Balance, err = accountBalance(ctx, tx, hold.accountID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return hold, nil
+}
+
+// VoidHold cancels an authorized hold and releases the reserved funds
+func (u *PostgresRepository) VoidHold(ctx context.Context, id int) (*Hold, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	hold, err := releaseHold(ctx, tx, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	hold.Status = HoldVoided
+	stmt := `UPDATE holds SET status = $1, updated_at = $2 WHERE id = $3`
+	if _, err := tx.ExecContext(ctx, stmt, hold.Status, hold.UpdatedAt, hold.ID); err != nil {
+		return nil, fmt.Errorf("failed to void hold: %w", err)
+	}
+
+	if hold.Balance, err = accountBalance(ctx, tx, hold.accountID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return hold, nil
+}
+
+// ExpireHolds marks authorized holds past their expiry as expired and releases their funds. It returns
+// how many holds expired
+func (u *PostgresRepository) ExpireHolds(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	stmt := `
+        WITH expired AS (
+            UPDATE holds SET status = 'expired', updated_at = $1
+            WHERE status = 'authorized' AND expires_at <= $1
+            RETURNING account_id, amount
+        ), released AS (
+            UPDATE accounts a SET held = a.held - e.amount
+            FROM (SELECT account_id, SUM(amount) AS amount FROM expired GROUP BY account_id) e
+            WHERE a.id = e.account_id
+        )
+        SELECT COUNT(*) FROM expired
+    `
+	var expired int64
+	if err := db.QueryRowContext(ctx, stmt, time.Now()).Scan(&expired); err != nil {
+		return 0, fmt.Errorf("failed to expire holds: %w", err)
+	}
+
+	return expired, nil
+}
+
+// releaseHold locks an authorized hold and its account, lowers the reserved funds of the account by the
+// hold amount and returns the hold. Holds past their expiry are treated as expired even if the sweeper
+// hasn't run yet. Only a capture resolves and locks the destination's account as well; a void leaves
+// the destination alone, so it works even after the destination user was deleted
+func releaseHold(ctx context.Context, tx *sql.Tx, id int, capture bool) (*Hold, error) {
+	var hold Hold
+	query := `
+        SELECT id, user_id, account_id, destination_id, amount, currency, status, created_at, expires_at
+        FROM holds
+        WHERE id = $1
+        FOR UPDATE
+    `
+	err := tx.QueryRowContext(ctx, query, id).Scan(&hold.ID, &hold.UserID, &hold.accountID, &hold.DestinationID,
+		&hold.Amount, &hold.Currency, &hold.Status, &hold.CreatedAt, &hold.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("hold %d: %w", id, ErrHoldNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hold: %w", err)
+	}
+
+	hold.UpdatedAt = time.Now()
+	if hold.Status != HoldAuthorized {
+		return nil, fmt.Errorf("%w: hold %d is %s", ErrHoldNotActive, hold.ID, hold.Status)
+	}
+	if !hold.UpdatedAt.Before(hold.ExpiresAt) {
+		return nil, fmt.Errorf("%w: hold %d expired at %s", ErrHoldNotActive, hold.ID, hold.ExpiresAt.Format(time.RFC3339))
+	}
+
+	// The destination is locked too, so a capture takes the user and account locks in the same order as
+	// transfer: both users first, then both accounts
+	locked := []int{hold.accountID}
+	if capture {
+		if err := checkAccountStatus(ctx, tx, hold.UserID, flowOut); err != nil {
+			return nil, err
+		}
+		if err := checkAccountStatus(ctx, tx, hold.DestinationID, flowIn); err != nil {
+			return nil, fmt.Errorf("destination %w", err)
+		}
+		destinationAccountID, err := userAccountID(ctx, tx, hold.DestinationID, hold.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("destination %w", err)
+		}
+		locked = append(locked, destinationAccountID)
+	}
+	if _, err := lockAccounts(ctx, tx, locked...); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE accounts SET held = held - $1 WHERE id = $2`, hold.Amount, hold.accountID); err != nil {
+		return nil, fmt.Errorf("failed to release funds: %w", err)
+	}
+
+	return &hold, nil
+}
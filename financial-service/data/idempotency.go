@@ -0,0 +1,150 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type idempotencyKeyCtx struct{}
+
+// IdempotencyKey is a client supplied key that makes a money movement safe to retry. Response is the
+// result the request produces on success; it is stored with the key and replayed for retries. When
+// Response is a JSON object, the id of the transaction the movement recorded is added to it as
+// transaction_id
+type IdempotencyKey struct {
+	Key         string
+	Endpoint    string
+	Fingerprint string
+	StatusCode  int
+	Response    []byte
+	TTL         time.Duration
+}
+
+// ReplayError is returned when a request reuses an idempotency key of an already completed request
+type ReplayError struct {
+	StatusCode int
+	Response   []byte
+}
+
+func (e *ReplayError) Error() string {
+	return "request has already been processed"
+}
+
+// WithIdempotencyKey attaches key to ctx, so money movements started with that context claim the key
+// in the same database transaction as the movement itself
+func WithIdempotencyKey(ctx context.Context, key *IdempotencyKey) context.Context {
+	return context.WithValue(ctx, idempotencyKeyCtx{}, key)
+}
+
+// claimIdempotencyKey stores the idempotency key from ctx, if any. A key that was already used returns
+// a *ReplayError with the stored response, or ErrIdempotencyConflict when the request differs.
+// Concurrent requests with the same key block on the primary key until the first one finishes
+func claimIdempotencyKey(ctx context.Context, tx *sql.Tx) error {
+	key, ok := ctx.Value(idempotencyKeyCtx{}).(*IdempotencyKey)
+	if !ok || key == nil {
+		return nil
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(key.TTL)
+
+	stmt := `
+        INSERT INTO idempotency_keys (endpoint, key, fingerprint, status_code, response, created_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (endpoint, key) DO NOTHING
+    `
+	res, err := tx.ExecContext(ctx, stmt, key.Endpoint, key.Key, key.Fingerprint, key.StatusCode, key.Response, now, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotency key: %w", err)
+	}
+	if inserted, err := res.RowsAffected(); err == nil && inserted == 1 {
+		return nil
+	}
+
+	var stored IdempotencyKey
+	var storedExpiresAt time.Time
+	query := `
+        SELECT fingerprint, status_code, response, expires_at
+        FROM idempotency_keys
+        WHERE endpoint = $1 AND key = $2
+        FOR UPDATE
+    `
+	err = tx.QueryRowContext(ctx, query, key.Endpoint, key.Key).Scan(&stored.Fingerprint, &stored.StatusCode, &stored.Response, &storedExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	if storedExpiresAt.Before(now) {
+		stmt = `
+            UPDATE idempotency_keys
+            SET fingerprint = $3, status_code = $4, response = $5, created_at = $6, expires_at = $7
+            WHERE endpoint = $1 AND key = $2
+        `
+		_, err = tx.ExecContext(ctx, stmt, key.Endpoint, key.Key, key.Fingerprint, key.StatusCode, key.Response, now, expiresAt)
+		if err != nil {
+			return fmt.Errorf("failed to store idempotency key: %w", err)
+		}
+		return nil
+	}
+
+	if stored.Fingerprint != key.Fingerprint {
+		return ErrIdempotencyConflict
+	}
+
+	return &ReplayError{StatusCode: stored.StatusCode, Response: stored.Response}
+}
+
+// completeIdempotencyKey adds transactionID to the response stored for the idempotency key from ctx,
+// which claimIdempotencyKey stored before the transaction existed
+func completeIdempotencyKey(ctx context.Context, tx *sql.Tx, transactionID int) error {
+	key, ok := ctx.Value(idempotencyKeyCtx{}).(*IdempotencyKey)
+	if !ok || key == nil {
+		return nil
+	}
+
+	stored, ok := withTransactionID(key.Response, transactionID)
+	if !ok {
+		return nil
+	}
+
+	stmt := `UPDATE idempotency_keys SET response = $3 WHERE endpoint = $1 AND key = $2`
+	if _, err := tx.ExecContext(ctx, stmt, key.Endpoint, key.Key, stored); err != nil {
+		return fmt.Errorf("failed to store idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// withTransactionID sets transaction_id in a JSON object response. It reports false for responses that
+// aren't objects
+func withTransactionID(response []byte, transactionID int) ([]byte, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(response, &fields); err != nil || fields == nil {
+		return nil, false
+	}
+	fields["transaction_id"] = json.RawMessage(strconv.Itoa(transactionID))
+
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+
+	return out, true
+}
+
+// PurgeIdempotencyKeys deletes keys that expired before now and returns how many were removed
+func (u *PostgresRepository) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < $1`, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge idempotency keys: %w", err)
+	}
+
+	return res.RowsAffected()
+}
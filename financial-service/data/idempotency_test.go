@@ -0,0 +1,23 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithTransactionID checks that the transaction id is added to object responses only
+func TestWithTransactionID(t *testing.T) {
+	out, ok := withTransactionID([]byte(`{"error":false,"message":"Transfer money worked successfully"}`), 42)
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"error":false,"message":"Transfer money worked successfully","transaction_id":42}`, string(out))
+
+	out, ok = withTransactionID([]byte(`{"transaction_id":1}`), 2)
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"transaction_id":2}`, string(out))
+
+	for _, response := range []string{`[1,2]`, `"done"`, `null`, `not json`} {
+		_, ok := withTransactionID([]byte(response), 42)
+		assert.False(t, ok, response)
+	}
+}
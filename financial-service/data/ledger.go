@@ -0,0 +1,273 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"sort"
+	"time"
+)
+
+// ExternalFundingAccount is the system account that money enters and leaves the service through. There is
+// one such account per currency
+const ExternalFundingAccount = "external_funding"
+
+// Posting is a single leg of a journal entry. Positive amounts credit the account, negative amounts debit it
+type Posting struct {
+	AccountID int             `json:"AccountID"`
+	Currency  string          `json:"Currency"`
+	Amount    decimal.Decimal `json:"Amount"`
+}
+
+// JournalEntry is a set of postings that sum to zero in every currency, optionally linked to a row in transactions
+type JournalEntry struct {
+	ID            int        `json:"ID"`
+	TransactionID *int       `json:"TransactionID"`
+	Description   string     `json:"Description"`
+	CreatedAt     time.Time  `json:"CreatedAt"`
+	Postings      []*Posting `json:"Postings"`
+}
+
+// BalanceCheck compares the balance cached on an account with the balance derived from its postings
+type BalanceCheck struct {
+	UserID    int             `json:"UserID"`
+	AccountID int             `json:"AccountID"`
+	Currency  string          `json:"Currency"`
+	Cached    decimal.Decimal `json:"Cached"`
+	Ledger    decimal.Decimal `json:"Ledger"`
+	Balanced  bool            `json:"Balanced"`
+}
+
+// validate checks that the entry has at least two non-zero postings that sum to zero in each currency
+func (e *JournalEntry) validate() error {
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("%w: entry needs at least two postings", ErrUnbalancedEntry)
+	}
+
+	totals := make(map[string]decimal.Decimal)
+	for _, p := range e.Postings {
+		if p.Amount.IsZero() {
+			return fmt.Errorf("%w: posting to account %d has zero amount", ErrUnbalancedEntry, p.AccountID)
+		}
+		if _, err := CurrencyScale(p.Currency); err != nil {
+			return err
+		}
+		totals[p.Currency] = totals[p.Currency].Add(p.Amount)
+	}
+	for currency, total := range totals {
+		if !total.IsZero() {
+			return fmt.Errorf("%w: %s postings sum to %s", ErrUnbalancedEntry, currency, total.String())
+		}
+	}
+
+	return nil
+}
+
+// postEntry validates that entry is balanced, writes it with its postings and applies the postings to
+// the cached account balances, noting when a user account went negative. Postings to a user's default
+// DefaultCurrency wallet are mirrored to users.balance
+func postEntry(ctx context.Context, tx *sql.Tx, entry *JournalEntry) (int, error) {
+	if err := insertEntry(ctx, tx, entry); err != nil {
+		return 0, err
+	}
+
+	for _, p := range entry.Postings {
+		if err := applyPosting(ctx, tx, p, entry.CreatedAt); err != nil {
+			return 0, err
+		}
+	}
+
+	return entry.ID, nil
+}
+
+// insertEntry validates that entry is balanced and writes it with its postings, without touching the
+// cached balances
+func insertEntry(ctx context.Context, tx *sql.Tx, entry *JournalEntry) error {
+	if err := entry.validate(); err != nil {
+		return err
+	}
+
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	stmt := `INSERT INTO journal_entries (transaction_id, description, created_at) VALUES ($1, $2, $3) RETURNING id`
+	err := tx.QueryRowContext(ctx, stmt, entry.TransactionID, entry.Description, entry.CreatedAt).Scan(&entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to add journal entry: %w", err)
+	}
+
+	for _, p := range entry.Postings {
+		stmt = `INSERT INTO postings (entry_id, account_id, amount) VALUES ($1, $2, $3)`
+		if _, err := tx.ExecContext(ctx, stmt, entry.ID, p.AccountID, p.Amount); err != nil {
+			return fmt.Errorf("failed to add posting: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// applyPosting adds a posting to the cached balance of its account, and to users.balance for a user's
+// default DefaultCurrency wallet
+func applyPosting(ctx context.Context, tx *sql.Tx, p *Posting, at time.Time) error {
+	var userID sql.NullInt64
+	var currency string
+	var isDefault bool
+	stmt := `
+        UPDATE accounts SET balance = balance + $1, updated_at = $2,
+            negative_since = CASE WHEN user_id IS NOT NULL AND balance + $1 < 0 THEN COALESCE(negative_since, $2) END
+        WHERE id = $3
+        RETURNING user_id, currency, is_default
+    `
+	err := tx.QueryRowContext(ctx, stmt, p.Amount, at, p.AccountID).Scan(&userID, &currency, &isDefault)
+	if err != nil {
+		return fmt.Errorf("failed to update account balance: %w", err)
+	}
+	if currency != p.Currency {
+		return fmt.Errorf("%w: account %d holds %s, posting is in %s", ErrCurrencyMismatch, p.AccountID, currency, p.Currency)
+	}
+
+	if userID.Valid && isDefault && currency == DefaultCurrency {
+		stmt = `UPDATE users SET balance = balance + $1, updated_at = $2 WHERE id = $3`
+		if _, err := tx.ExecContext(ctx, stmt, p.Amount, at, userID.Int64); err != nil {
+			return fmt.Errorf("failed to update balance: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// userAccountID returns the default wallet of a user in currency, opening it if the user has none yet
+func userAccountID(ctx context.Context, tx *sql.Tx, userID int, currency string) (int, error) {
+	var id int
+	err := tx.QueryRowContext(ctx, `SELECT id FROM accounts WHERE user_id = $1 AND currency = $2 AND is_default`, userID, currency).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to get account: %w", err)
+	}
+
+	stmt := `
+        INSERT INTO accounts (user_id, currency, name, is_default)
+        SELECT id, $2, $3, TRUE FROM users WHERE id = $1 AND deleted_at IS NULL
+        ON CONFLICT (user_id, currency) WHERE is_default DO UPDATE SET user_id = EXCLUDED.user_id
+        RETURNING id
+    `
+	err = tx.QueryRowContext(ctx, stmt, userID, currency, DefaultWalletName).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("user %d: %w", userID, ErrUserNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open account: %w", err)
+	}
+
+	return id, nil
+}
+
+// systemAccountID returns the id of the system account with the given code in currency, opening it on first use
+func systemAccountID(ctx context.Context, tx *sql.Tx, code, currency string) (int, error) {
+	stmt := `
+        INSERT INTO accounts (code, currency) VALUES ($1, $2)
+        ON CONFLICT (code, currency) DO UPDATE SET code = EXCLUDED.code
+        RETURNING id
+    `
+	var id int
+	err := tx.QueryRowContext(ctx, stmt, code, currency).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get system account %s %s: %w", code, currency, err)
+	}
+
+	return id, nil
+}
+
+// lockAccounts locks the given accounts in ascending id order, so that concurrent movements between the
+// same accounts can't deadlock, and returns their available balances: the cached balance less active
+// holds plus the overdraft limit
+func lockAccounts(ctx context.Context, tx *sql.Tx, ids ...int) (map[int]decimal.Decimal, error) {
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+
+	balances := make(map[int]decimal.Decimal, len(sorted))
+	for _, id := range sorted {
+		if _, ok := balances[id]; ok {
+			continue
+		}
+
+		var balance decimal.Decimal
+		err := tx.QueryRowContext(ctx, `SELECT balance - held + overdraft_limit FROM accounts WHERE id = $1 FOR UPDATE`, id).Scan(&balance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock account %d: %w", id, err)
+		}
+		balances[id] = balance
+	}
+
+	return balances, nil
+}
+
+// PostEntry writes a balanced journal entry in its own database transaction
+func (u *PostgresRepository) PostEntry(ctx context.Context, entry *JournalEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := postEntry(ctx, tx, entry); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyBalance compares the cached balance of every account of a user with the sum of its postings
+func (u *PostgresRepository) VerifyBalance(ctx context.Context, userID int) ([]*BalanceCheck, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to verify balance: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("user %d: %w", userID, ErrUserNotFound)
+	}
+
+	query := `
+        SELECT a.id, a.currency, a.balance, COALESCE(SUM(p.amount), 0)
+        FROM accounts a
+        LEFT JOIN postings p ON p.account_id = a.id
+        WHERE a.user_id = $1
+        GROUP BY a.id, a.currency, a.balance
+        ORDER BY a.id
+    `
+	rows, err := db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify balance: %w", err)
+	}
+	defer rows.Close()
+
+	var checks []*BalanceCheck
+	for rows.Next() {
+		check := BalanceCheck{UserID: userID}
+		if err := rows.Scan(&check.AccountID, &check.Currency, &check.Cached, &check.Ledger); err != nil {
+			return nil, fmt.Errorf("failed to scan balance: %w", err)
+		}
+		check.Balanced = check.Cached.Equal(check.Ledger)
+		checks = append(checks, &check)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to verify balance: %w", err)
+	}
+
+	return checks, nil
+}
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestJournalEntryValidate checks that only balanced entries are accepted
+func TestJournalEntryValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		postings []*Posting
+		valid    bool
+	}{
+		{
+			name: "balanced",
+			postings: []*Posting{
+				{AccountID: 1, Currency: "RUB", Amount: decimal.RequireFromString("-10.50")},
+				{AccountID: 2, Currency: "RUB", Amount: decimal.RequireFromString("10.50")},
+			},
+			valid: true,
+		},
+		{
+			name: "balanced across three legs",
+			postings: []*Posting{
+				{AccountID: 1, Currency: "RUB", Amount: decimal.RequireFromString("-10")},
+				{AccountID: 2, Currency: "RUB", Amount: decimal.RequireFromString("9.50")},
+				{AccountID: 3, Currency: "RUB", Amount: decimal.RequireFromString("0.50")},
+			},
+			valid: true,
+		},
+		{
+			name: "unbalanced",
+			postings: []*Posting{
+				{AccountID: 1, Currency: "RUB", Amount: decimal.RequireFromString("-10")},
+				{AccountID: 2, Currency: "RUB", Amount: decimal.RequireFromString("9.99")},
+			},
+		},
+		{
+			name: "balanced in total but not per currency",
+			postings: []*Posting{
+				{AccountID: 1, Currency: "EUR", Amount: decimal.RequireFromString("-10")},
+				{AccountID: 2, Currency: "USD", Amount: decimal.RequireFromString("10")},
+			},
+		},
+		{
+			name: "single posting",
+			postings: []*Posting{
+				{AccountID: 1, Currency: "RUB", Amount: decimal.RequireFromString("10")},
+			},
+		},
+		{
+			name: "zero posting",
+			postings: []*Posting{
+				{AccountID: 1, Currency: "RUB", Amount: decimal.Zero},
+				{AccountID: 2, Currency: "RUB", Amount: decimal.Zero},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &JournalEntry{Postings: tt.postings}
+			err := entry.validate()
+			if tt.valid {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrUnbalancedEntry)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// LimitKind names one of the velocity limits of a SpendingLimit
+type LimitKind string
+
+const (
+	LimitDailyAmount   LimitKind = "daily_amount"
+	LimitDailyCount    LimitKind = "daily_count"
+	LimitMonthlyAmount LimitKind = "monthly_amount"
+	LimitMonthlyCount  LimitKind = "monthly_count"
+)
+
+// SpendingLimit caps the outgoing transfers and withdrawals of a user in one currency per calendar day and
+// month, in server time. Nil fields are unlimited. The limit with a nil UserID is the global default; a
+// user's own limit for the currency replaces it as a whole
+type SpendingLimit struct {
+	ID            int                 `json:"ID"`
+	UserID        *int                `json:"UserID"`
+	Currency      string              `json:"Currency"`
+	DailyAmount   decimal.NullDecimal `json:"DailyAmount"`
+	DailyCount    *int                `json:"DailyCount"`
+	MonthlyAmount decimal.NullDecimal `json:"MonthlyAmount"`
+	MonthlyCount  *int                `json:"MonthlyCount"`
+	UpdatedAt     time.Time           `json:"UpdatedAt"`
+}
+
+// LimitExceededError is returned when a debit would take the user past one of their spending limits. Used
+// is the amount or count already spent in the current period, not counting the rejected debit
+type LimitExceededError struct {
+	Limit     LimitKind       `json:"Limit"`
+	Currency  string          `json:"Currency"`
+	Max       decimal.Decimal `json:"Max"`
+	Used      decimal.Decimal `json:"Used"`
+	Requested decimal.Decimal `json:"Requested"`
+	ResetsAt  time.Time       `json:"ResetsAt"`
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s: %s %s limit of %s reached, resets at %s", ErrLimitExceeded, e.Currency, e.Limit, e.Max.String(),
+		e.ResetsAt.Format(time.RFC3339))
+}
+
+// Unwrap makes errors.Is(err, ErrLimitExceeded) hold for every LimitExceededError
+func (e *LimitExceededError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// validate checks the currency and that no limit is negative
+func (l *SpendingLimit) validate() error {
+	l.Currency = NormalizeCurrency(l.Currency)
+	if _, err := CurrencyScale(l.Currency); err != nil {
+		return err
+	}
+
+	for _, amount := range []decimal.NullDecimal{l.DailyAmount, l.MonthlyAmount} {
+		if amount.Valid && amount.Decimal.IsNegative() {
+			return fmt.Errorf("%w: limits can't be negative", ErrInvalidAmount)
+		}
+	}
+	for _, count := range []*int{l.DailyCount, l.MonthlyCount} {
+		if count != nil && *count < 0 {
+			return fmt.Errorf("%w: limits can't be negative", ErrInvalidAmount)
+		}
+	}
+
+	return nil
+}
+
+const spendingLimitColumns = `id, user_id, currency, daily_amount, daily_count, monthly_amount, monthly_count, updated_at`
+
+// scanSpendingLimit reads a row selected with spendingLimitColumns
+func scanSpendingLimit(row rowScanner) (*SpendingLimit, error) {
+	var l SpendingLimit
+	err := row.Scan(&l.ID, &l.UserID, &l.Currency, &l.DailyAmount, &l.DailyCount, &l.MonthlyAmount, &l.MonthlyCount, &l.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+// effectiveLimit returns the limit that applies to a user in currency, or nil when there is none
+func effectiveLimit(ctx context.Context, q queryRower, userID int, currency string) (*SpendingLimit, error) {
+	query := `
+        SELECT ` + spendingLimitColumns + `
+        FROM spending_limits
+        WHERE currency = $2 AND (user_id = $1 OR user_id IS NULL)
+        ORDER BY user_id NULLS LAST
+        LIMIT 1
+    `
+	limit, err := scanSpendingLimit(q.QueryRowContext(ctx, query, userID, currency))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending limit: %w", err)
+	}
+
+	return limit, nil
+}
+
+// limitAccountID returns the account that debits of a user in currency lock before their spending limits
+// are checked: the user's default wallet in currency. Debits from different wallets of the user lock
+// different accounts, so this is what makes them wait for each other
+func limitAccountID(ctx context.Context, tx *sql.Tx, userID int, currency string) (int, error) {
+	return userAccountID(ctx, tx, userID, currency)
+}
+
+// checkSpendingLimits fails with a LimitExceededError when debiting amount from userID at now would exceed
+// a spending limit. The caller must hold the lock on the account returned by limitAccountID for the user
+// and currency, so that concurrent debits from any of the user's wallets are counted
+func checkSpendingLimits(ctx context.Context, tx *sql.Tx, userID int, currency string, amount decimal.Decimal, now time.Time) error {
+	limit, err := effectiveLimit(ctx, tx, userID, currency)
+	if err != nil || limit == nil {
+		return err
+	}
+
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	var dailyAmount, monthlyAmount decimal.Decimal
+	var dailyCount, monthlyCount int64
+	query := `
+        SELECT COALESCE(SUM(amount) FILTER (WHERE createdat >= $3), 0), COUNT(*) FILTER (WHERE createdat >= $3),
+               COALESCE(SUM(amount), 0), COUNT(*)
+        FROM transactions
+        WHERE useridsource = $1 AND currency = $2 AND type IN ('transfer', 'withdrawal') AND createdat >= $4
+          AND useridendpoint IS DISTINCT FROM useridsource
+    `
+	err = tx.QueryRowContext(ctx, query, userID, currency, dayStart, monthStart).Scan(&dailyAmount, &dailyCount, &monthlyAmount, &monthlyCount)
+	if err != nil {
+		return fmt.Errorf("failed to get outgoing totals: %w", err)
+	}
+
+	exceeded := func(kind LimitKind, max, used, requested decimal.Decimal, resetsAt time.Time) error {
+		return &LimitExceededError{Limit: kind, Currency: currency, Max: max, Used: used, Requested: requested, ResetsAt: resetsAt}
+	}
+
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	switch {
+	case limit.DailyCount != nil && dailyCount+1 > int64(*limit.DailyCount):
+		return exceeded(LimitDailyCount, decimal.NewFromInt(int64(*limit.DailyCount)), decimal.NewFromInt(dailyCount), decimal.NewFromInt(1), dayEnd)
+	case limit.DailyAmount.Valid && dailyAmount.Add(amount).GreaterThan(limit.DailyAmount.Decimal):
+		return exceeded(LimitDailyAmount, limit.DailyAmount.Decimal, dailyAmount, amount, dayEnd)
+	case limit.MonthlyCount != nil && monthlyCount+1 > int64(*limit.MonthlyCount):
+		return exceeded(LimitMonthlyCount, decimal.NewFromInt(int64(*limit.MonthlyCount)), decimal.NewFromInt(monthlyCount), decimal.NewFromInt(1), monthEnd)
+	case limit.MonthlyAmount.Valid && monthlyAmount.Add(amount).GreaterThan(limit.MonthlyAmount.Decimal):
+		return exceeded(LimitMonthlyAmount, limit.MonthlyAmount.Decimal, monthlyAmount, amount, monthEnd)
+	}
+
+	return nil
+}
+
+// SetSpendingLimit creates or replaces the global limit for a currency, or a user's override of it when
+// UserID is set
+func (u *PostgresRepository) SetSpendingLimit(ctx context.Context, l *SpendingLimit) (*SpendingLimit, error) {
+	if err := l.validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if l.UserID != nil {
+		if _, err := userAccountID(ctx, tx, *l.UserID, l.Currency); err != nil {
+			return nil, err
+		}
+	}
+
+	conflict := `(currency) WHERE user_id IS NULL`
+	if l.UserID != nil {
+		conflict = `(user_id, currency) WHERE user_id IS NOT NULL`
+	}
+	stmt := `
+        INSERT INTO spending_limits (user_id, currency, daily_amount, daily_count, monthly_amount, monthly_count, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT ` + conflict + ` DO UPDATE SET
+            daily_amount = EXCLUDED.daily_amount, daily_count = EXCLUDED.daily_count,
+            monthly_amount = EXCLUDED.monthly_amount, monthly_count = EXCLUDED.monthly_count,
+            updated_at = EXCLUDED.updated_at
+        RETURNING ` + spendingLimitColumns
+	limit, err := scanSpendingLimit(tx.QueryRowContext(ctx, stmt, l.UserID, l.Currency, l.DailyAmount, l.DailyCount,
+		l.MonthlyAmount, l.MonthlyCount, time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set spending limit: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return limit, nil
+}
@@ -0,0 +1,41 @@
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLimitExceededError checks that the structured error still matches ErrLimitExceeded
+func TestLimitExceededError(t *testing.T) {
+	var err error = &LimitExceededError{
+		Limit:    LimitMonthlyCount,
+		Currency: "USD",
+		Max:      decimal.NewFromInt(30),
+		ResetsAt: time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Equal(t, "limit exceeded: USD monthly_count limit of 30 reached, resets at 2024-07-01T00:00:00Z", err.Error())
+
+	var limitErr *LimitExceededError
+	assert.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, LimitMonthlyCount, limitErr.Limit)
+}
+
+// TestSpendingLimitValidate checks the currency and that limits can't be negative
+func TestSpendingLimitValidate(t *testing.T) {
+	l := &SpendingLimit{Currency: "eur", DailyAmount: decimal.NewNullDecimal(decimal.NewFromInt(100))}
+	assert.Nil(t, l.validate())
+	assert.Equal(t, "EUR", l.Currency)
+
+	count := -1
+	l = &SpendingLimit{DailyCount: &count}
+	assert.ErrorIs(t, l.validate(), ErrInvalidAmount)
+
+	l = &SpendingLimit{Currency: "XXX"}
+	assert.ErrorIs(t, l.validate(), ErrUnsupportedCurrency)
+}
@@ -3,6 +3,7 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"github.com/shopspring/decimal"
 	"time"
@@ -23,16 +24,89 @@ func NewPostgresRepository(pool *sql.DB) *PostgresRepository {
 	}
 }
 
+// TransactionType is the kind of money movement a transaction records
+type TransactionType string
+
+const (
+	TransactionDeposit    TransactionType = "deposit"
+	TransactionWithdrawal TransactionType = "withdrawal"
+	TransactionTransfer   TransactionType = "transfer"
+	TransactionFee        TransactionType = "fee"
+	TransactionRefund     TransactionType = "refund"
+	TransactionAdjustment TransactionType = "adjustment"
+)
+
+// Transactions is a recorded money movement. UserIDSource is nil for money entering the service,
+// UserIDEndpoint is nil for money leaving it. The wallet ids name the wallets of those users that were
+// debited and credited
 type Transactions struct {
-	ID             int             `json:"ID"`
-	UserIDSource   int             `json:"UserIDSource"`
-	UserIDEndpoint int             `json:"UserIDEndpoint"`
-	Amount         decimal.Decimal `json:"Amount"`
-	CreatedAt      time.Time       `json:"CreatedAt"`
+	ID                  int             `json:"ID"`
+	Type                TransactionType `json:"Type"`
+	UserIDSource        *int            `json:"UserIDSource"`
+	UserIDEndpoint      *int            `json:"UserIDEndpoint"`
+	SourceWalletID      *int            `json:"SourceWalletID"`
+	DestinationWalletID *int            `json:"DestinationWalletID"`
+	Amount              decimal.Decimal `json:"Amount"`
+	Currency            string          `json:"Currency"`
+	ParentID            *int            `json:"ParentID"`
+	CreatedAt           time.Time       `json:"CreatedAt"`
+
+	// Set for transfers that converted between currencies
+	DestinationAmount   decimal.NullDecimal `json:"DestinationAmount"`
+	DestinationCurrency *string             `json:"DestinationCurrency"`
+	FXRate              decimal.NullDecimal `json:"FXRate"`
+	FXQuoteID           *string             `json:"FXQuoteID"`
+
+	// Set by the caller of a deposit, withdrawal or transfer
+	Description *string        `json:"Description"`
+	Reference   *string        `json:"Reference"`
+	Metadata    map[string]any `json:"Metadata"`
+}
+
+// DepositRequest describes money entering the service for a user, into WalletID or, without one, the
+// user's default wallet in Currency
+type DepositRequest struct {
+	UserID   int
+	WalletID int
+	Amount   decimal.Decimal
+	Currency string
+	TransactionDetails
 }
 
-// AddMoney adds some amount of money to users balance
+// WithdrawalRequest describes money leaving the service from a user, out of WalletID or, without one,
+// the user's default wallet in Currency
+type WithdrawalRequest struct {
+	UserID   int
+	WalletID int
+	Amount   decimal.Decimal
+	Currency string
+	TransactionDetails
+}
+
+// TransferRequest describes a movement of money between two users. The source user is debited in
+// Currency; DestinationCurrency defaults to Currency. A transfer that crosses currencies must execute
+// against a quote from CreateQuote, given by QuoteID. FromWalletID and ToWalletID pick wallets other than
+// the users' defaults; From and To may then be left zero. A transfer between two wallets of the same
+// user is a move, which is charged no fee and doesn't count towards spending limits
+type TransferRequest struct {
+	From                int
+	To                  int
+	FromWalletID        int
+	ToWalletID          int
+	Amount              decimal.Decimal
+	Currency            string
+	DestinationCurrency string
+	Convert             bool
+	QuoteID             string
+	TransactionDetails
+}
+
+// AddMoney adds some amount of money to users balance, funded from the external account
 func (u *PostgresRepository) AddMoney(id int, amount decimal.Decimal) error {
+	if err := ValidateAmount(DefaultCurrency, amount); err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
 	defer cancel()
 
@@ -44,10 +118,31 @@ func (u *PostgresRepository) AddMoney(id int, amount decimal.Decimal) error {
 	}
 	defer tx.Rollback()
 
-	stmt := `UPDATE users SET balance = balance + $1, updated_at = $2 WHERE id = $3`
-	_, err = tx.ExecContext(ctx, stmt, amount, time.Now(), id)
+	accountID, err := userAccountID(ctx, tx, id, DefaultCurrency)
 	if err != nil {
-		return fmt.Errorf("failed to update balance: %w", err)
+		return err
+	}
+	fundingID, err := systemAccountID(ctx, tx, ExternalFundingAccount, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+
+	if err := checkAccountStatus(ctx, tx, id, flowIn); err != nil {
+		return err
+	}
+	if _, err := lockAccounts(ctx, tx, accountID); err != nil {
+		return err
+	}
+
+	_, err = postEntry(ctx, tx, &JournalEntry{
+		Description: "credit",
+		Postings: []*Posting{
+			{AccountID: fundingID, Currency: DefaultCurrency, Amount: amount.Neg()},
+			{AccountID: accountID, Currency: DefaultCurrency, Amount: amount},
+		},
+	})
+	if err != nil {
+		return err
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -57,8 +152,12 @@ func (u *PostgresRepository) AddMoney(id int, amount decimal.Decimal) error {
 	return nil
 }
 
-// DecreaseMoney decreasing users balance for some amount
+// DecreaseMoney decreasing users balance for some amount, returning it to the external account
 func (u *PostgresRepository) DecreaseMoney(idSource int, amount decimal.Decimal) error {
+	if err := ValidateAmount(DefaultCurrency, amount); err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
 	defer cancel()
 
@@ -68,21 +167,37 @@ func (u *PostgresRepository) DecreaseMoney(idSource int, amount decimal.Decimal)
 	}
 	defer tx.Rollback()
 
-	var currentBalance decimal.Decimal
-	err = tx.QueryRowContext(ctx, "SELECT balance FROM users WHERE id = $1", idSource).Scan(&currentBalance)
+	accountID, err := userAccountID(ctx, tx, idSource, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	fundingID, err := systemAccountID(ctx, tx, ExternalFundingAccount, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+
+	if err := checkAccountStatus(ctx, tx, idSource, flowOut); err != nil {
+		return err
+	}
+	balances, err := lockAccounts(ctx, tx, accountID)
 	if err != nil {
-		return fmt.Errorf("failed to get current balance: %w", err)
+		return err
 	}
 
-	newBalance := currentBalance.Sub(amount)
-	if newBalance.IsNegative() {
-		return fmt.Errorf("insufficient balance: cannot decrease balance by %s, current balance is %s", amount.String(), currentBalance.String())
+	currentBalance := balances[accountID]
+	if currentBalance.LessThan(amount) {
+		return fmt.Errorf("%w: cannot decrease balance by %s, available balance is %s", ErrInsufficientBalance, amount.String(), currentBalance.String())
 	}
 
-	stmt := `UPDATE users SET balance = balance - $1, updated_at = $2 WHERE id = $3`
-	_, err = tx.ExecContext(ctx, stmt, amount, time.Now(), idSource)
+	_, err = postEntry(ctx, tx, &JournalEntry{
+		Description: "debit",
+		Postings: []*Posting{
+			{AccountID: accountID, Currency: DefaultCurrency, Amount: amount.Neg()},
+			{AccountID: fundingID, Currency: DefaultCurrency, Amount: amount},
+		},
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update balance: %w", err)
+		return err
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -106,7 +221,7 @@ func (u *PostgresRepository) GetLastTransactions(id int) ([]*Transactions, error
 	defer tx.Rollback()
 
 	query := `
-        SELECT id, useridsource, useridendpoint, amount, createdat
+        SELECT ` + transactionColumns + `
         FROM transactions
         WHERE useridsource = $1 OR useridendpoint = $1
         ORDER BY createdat DESC
@@ -120,18 +235,11 @@ func (u *PostgresRepository) GetLastTransactions(id int) ([]*Transactions, error
 
 	var transactions []*Transactions
 	for rows.Next() {
-		var transaction Transactions
-		err := rows.Scan(
-			&transaction.ID,
-			&transaction.UserIDSource,
-			&transaction.UserIDEndpoint,
-			&transaction.Amount,
-			&transaction.CreatedAt,
-		)
+		transaction, err := scanTransaction(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan transaction: %w", err)
 		}
-		transactions = append(transactions, &transaction)
+		transactions = append(transactions, transaction)
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -141,33 +249,434 @@ func (u *PostgresRepository) GetLastTransactions(id int) ([]*Transactions, error
 	return transactions, nil
 }
 
-// AddTransaction adds transaction to the database
-func (u *PostgresRepository) AddTransaction(amount decimal.Decimal, id ...int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// transactionColumns are the columns scanned by scanTransaction
+const transactionColumns = `
+    id, type, useridsource, useridendpoint, source_wallet_id, destination_wallet_id, amount, currency, parent_id,
+    createdat, destination_amount, destination_currency, fx_rate, fx_quote_id, description, external_reference, metadata
+`
+
+// scanTransaction reads a row selected with transactionColumns
+func scanTransaction(row rowScanner) (*Transactions, error) {
+	var t Transactions
+	var metadata []byte
+	err := row.Scan(
+		&t.ID,
+		&t.Type,
+		&t.UserIDSource,
+		&t.UserIDEndpoint,
+		&t.SourceWalletID,
+		&t.DestinationWalletID,
+		&t.Amount,
+		&t.Currency,
+		&t.ParentID,
+		&t.CreatedAt,
+		&t.DestinationAmount,
+		&t.DestinationCurrency,
+		&t.FXRate,
+		&t.FXQuoteID,
+		&t.Description,
+		&t.Reference,
+		&metadata,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if metadata != nil {
+		if err := json.Unmarshal(metadata, &t.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode metadata: %w", err)
+		}
+	}
+
+	return &t, nil
+}
+
+// AddTransaction records a transaction, posts its journal entry and sets the transaction's ID. Deposits
+// need UserIDEndpoint, withdrawals UserIDSource and transfers both; the other types are created by their
+// own flows
+func (u *PostgresRepository) AddTransaction(ctx context.Context, t *Transactions) error {
+	details := TransactionDetails{Metadata: t.Metadata}
+	if t.Description != nil {
+		details.Description = *t.Description
+	}
+	if t.Reference != nil {
+		details.Reference = *t.Reference
+	}
+
+	var err error
+	switch t.Type {
+	case TransactionDeposit:
+		if t.UserIDEndpoint == nil || t.UserIDSource != nil {
+			return fmt.Errorf("%w: a deposit needs only a destination", ErrInvalidParties)
+		}
+		t.ID, err = u.Deposit(ctx, &DepositRequest{UserID: *t.UserIDEndpoint, Amount: t.Amount, Currency: t.Currency, TransactionDetails: details})
+	case TransactionWithdrawal:
+		if t.UserIDSource == nil || t.UserIDEndpoint != nil {
+			return fmt.Errorf("%w: a withdrawal needs only a source", ErrInvalidParties)
+		}
+		t.ID, err = u.Withdraw(ctx, &WithdrawalRequest{UserID: *t.UserIDSource, Amount: t.Amount, Currency: t.Currency, TransactionDetails: details})
+	case TransactionTransfer:
+		if t.UserIDSource == nil || t.UserIDEndpoint == nil {
+			return fmt.Errorf("%w: a transfer needs a source and a destination", ErrInvalidParties)
+		}
+		t.ID, err = u.Transfer(ctx, &TransferRequest{From: *t.UserIDSource, To: *t.UserIDEndpoint, Amount: t.Amount, Currency: t.Currency, TransactionDetails: details})
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedTransactionType, t.Type)
+	}
+
+	return err
+}
+
+// Deposit credits a user from the external account and records the transaction in a single database
+// transaction, returning its id. An idempotency key attached to ctx is claimed in that same transaction
+func (u *PostgresRepository) Deposit(ctx context.Context, req *DepositRequest) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
 	defer cancel()
 
-	tx, err := db.BeginTx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelSerializable,
-	})
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := claimIdempotencyKey(ctx, tx); err != nil {
+		return 0, err
+	}
+
+	transactionID, err := deposit(ctx, tx, req)
+	if err != nil {
+		return 0, err
+	}
+	if err := completeIdempotencyKey(ctx, tx, transactionID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return transactionID, nil
+}
+
+// Withdraw debits a user back to the external account and records the transaction in a single database
+// transaction, returning its id. An idempotency key attached to ctx is claimed in that same transaction
+func (u *PostgresRepository) Withdraw(ctx context.Context, req *WithdrawalRequest) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := claimIdempotencyKey(ctx, tx); err != nil {
+		return 0, err
+	}
+
+	transactionID, err := withdraw(ctx, tx, req)
+	if err != nil {
+		return 0, err
+	}
+	if err := completeIdempotencyKey(ctx, tx, transactionID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return transactionID, nil
+}
+
+// Transfer moves amount from one user to another and records the transaction, all inside a single
+// database transaction, and returns the transaction's id. Both accounts are locked in ascending id order
+// so concurrent transfers between the same pair of users can't deadlock. An idempotency key attached to
+// ctx is claimed in that same transaction.
+func (u *PostgresRepository) Transfer(ctx context.Context, req *TransferRequest) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	if err := claimIdempotencyKey(ctx, tx); err != nil {
+		return 0, err
+	}
+
+	transactionID, err := transfer(ctx, tx, req)
+	if err != nil {
+		return 0, err
+	}
+	if err := completeIdempotencyKey(ctx, tx, transactionID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return transactionID, nil
+}
+
+// deposit credits a user inside tx and returns the id of the recorded transaction
+func deposit(ctx context.Context, tx *sql.Tx, req *DepositRequest) (int, error) {
+	details, err := req.columns()
+	if err != nil {
+		return 0, err
+	}
+
+	accountID, currency, err := walletAccountID(ctx, tx, &req.UserID, req.WalletID, req.Currency)
+	if err != nil {
+		return 0, err
+	}
+	if err := ValidateAmount(currency, req.Amount); err != nil {
+		return 0, err
+	}
+	fundingID, err := systemAccountID(ctx, tx, ExternalFundingAccount, currency)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := checkAccountStatus(ctx, tx, req.UserID, flowIn); err != nil {
+		return 0, err
+	}
+	if _, err := lockAccounts(ctx, tx, accountID); err != nil {
+		return 0, err
+	}
+	if err := checkWalletsOpen(ctx, tx, accountID); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+
+	var transactionID int
 	stmt := `
-        INSERT INTO transactions (userIDSource, userIDEndpoint, amount, createdat)
-        VALUES ($1, $2, $3, $4)
+        INSERT INTO transactions (type, userIDSource, userIDEndpoint, destination_wallet_id, amount, currency, createdat,
+                                  description, external_reference, metadata)
+        VALUES ('deposit', NULL, $1, $2, $3, $4, $5, $6, $7, $8)
         RETURNING id
     `
-	var newID int
-	err = tx.QueryRowContext(ctx, stmt, id[0], id[1], amount, time.Now()).Scan(&newID)
+	err = tx.QueryRowContext(ctx, stmt, req.UserID, accountID, req.Amount, currency, now, details.description,
+		details.reference, details.metadata).Scan(&transactionID)
 	if err != nil {
-		return fmt.Errorf("failed to add transaction: %w", err)
+		return 0, fmt.Errorf("failed to add transaction: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	_, err = postEntry(ctx, tx, &JournalEntry{
+		TransactionID: &transactionID,
+		Description:   "deposit",
+		CreatedAt:     now,
+		Postings: []*Posting{
+			{AccountID: fundingID, Currency: currency, Amount: req.Amount.Neg()},
+			{AccountID: accountID, Currency: currency, Amount: req.Amount},
+		},
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return transactionID, nil
+}
+
+// withdraw debits a user inside tx and returns the id of the recorded transaction
+func withdraw(ctx context.Context, tx *sql.Tx, req *WithdrawalRequest) (int, error) {
+	details, err := req.columns()
+	if err != nil {
+		return 0, err
+	}
+
+	accountID, currency, err := walletAccountID(ctx, tx, &req.UserID, req.WalletID, req.Currency)
+	if err != nil {
+		return 0, err
+	}
+	if err := ValidateAmount(currency, req.Amount); err != nil {
+		return 0, err
+	}
+	fundingID, err := systemAccountID(ctx, tx, ExternalFundingAccount, currency)
+	if err != nil {
+		return 0, err
+	}
+	fee, err := quoteFee(ctx, tx, &FeeRequest{Type: TransactionWithdrawal, Amount: req.Amount, Currency: currency})
+	if err != nil {
+		return 0, err
+	}
+	limitID, err := limitAccountID(ctx, tx, req.UserID, currency)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := checkAccountStatus(ctx, tx, req.UserID, flowOut); err != nil {
+		return 0, err
+	}
+	balances, err := lockAccounts(ctx, tx, accountID, limitID)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkWalletsOpen(ctx, tx, accountID); err != nil {
+		return 0, err
+	}
+
+	currentBalance := balances[accountID]
+	if currentBalance.LessThan(fee.Total) {
+		return 0, fmt.Errorf("%w: cannot withdraw %s %s with a fee of %s, available balance is %s", ErrInsufficientBalance, req.Amount.String(), currency, fee.Fee.String(), currentBalance.String())
+	}
+
+	now := time.Now()
+	if err := checkSpendingLimits(ctx, tx, req.UserID, currency, req.Amount, now); err != nil {
+		return 0, err
+	}
+
+	var transactionID int
+	stmt := `
+        INSERT INTO transactions (type, userIDSource, userIDEndpoint, source_wallet_id, amount, currency, createdat,
+                                  description, external_reference, metadata)
+        VALUES ('withdrawal', $1, NULL, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id
+    `
+	err = tx.QueryRowContext(ctx, stmt, req.UserID, accountID, req.Amount, currency, now, details.description,
+		details.reference, details.metadata).Scan(&transactionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add transaction: %w", err)
+	}
+
+	_, err = postEntry(ctx, tx, &JournalEntry{
+		TransactionID: &transactionID,
+		Description:   "withdrawal",
+		CreatedAt:     now,
+		Postings: []*Posting{
+			{AccountID: accountID, Currency: currency, Amount: req.Amount.Neg()},
+			{AccountID: fundingID, Currency: currency, Amount: req.Amount},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if fee.Fee.IsPositive() {
+		if err := chargeFee(ctx, tx, transactionID, req.UserID, accountID, currency, fee.Fee, now); err != nil {
+			return 0, err
+		}
+	}
+
+	return transactionID, nil
+}
+
+// transfer moves amount between two users inside tx and returns the id of the recorded transaction
+func transfer(ctx context.Context, tx *sql.Tx, req *TransferRequest) (int, error) {
+	if req.QuoteID != "" {
+		return conversionTransfer(ctx, tx, req)
+	}
+
+	if req.FromWalletID == 0 && req.ToWalletID == 0 && req.From == req.To {
+		return 0, ErrSameAccount
+	}
+	details, err := req.columns()
+	if err != nil {
+		return 0, err
+	}
+
+	sourceAccountID, currency, err := walletAccountID(ctx, tx, &req.From, req.FromWalletID, req.Currency)
+	if err != nil {
+		return 0, fmt.Errorf("source %w", err)
+	}
+	if err := ValidateAmount(currency, req.Amount); err != nil {
+		return 0, err
+	}
+
+	destinationCurrency := req.DestinationCurrency
+	if destinationCurrency == "" && req.ToWalletID == 0 {
+		destinationCurrency = currency
+	}
+	destinationAccountID, destinationCurrency, err := walletAccountID(ctx, tx, &req.To, req.ToWalletID, destinationCurrency)
+	if err != nil {
+		return 0, fmt.Errorf("destination %w", err)
+	}
+	if destinationCurrency != currency {
+		if !req.Convert {
+			return 0, fmt.Errorf("%w: %s to %s", ErrCurrencyMismatch, currency, destinationCurrency)
+		}
+		return 0, fmt.Errorf("%w: %s to %s", ErrQuoteRequired, currency, destinationCurrency)
+	}
+	if sourceAccountID == destinationAccountID {
+		return 0, ErrSameAccount
+	}
+
+	// Moving money between the wallets of one user isn't spending it
+	move := req.From == req.To
+	fee := &FeeQuote{Type: TransactionTransfer, Amount: req.Amount, Currency: currency, Total: req.Amount}
+	locked := []int{sourceAccountID, destinationAccountID}
+	if !move {
+		if fee, err = quoteFee(ctx, tx, &FeeRequest{Type: TransactionTransfer, Amount: req.Amount, Currency: currency}); err != nil {
+			return 0, err
+		}
+		limitID, err := limitAccountID(ctx, tx, req.From, currency)
+		if err != nil {
+			return 0, fmt.Errorf("source %w", err)
+		}
+		locked = append(locked, limitID)
+	}
+
+	if err := checkAccountStatus(ctx, tx, req.From, flowOut); err != nil {
+		return 0, fmt.Errorf("source %w", err)
+	}
+	if err := checkAccountStatus(ctx, tx, req.To, flowIn); err != nil {
+		return 0, fmt.Errorf("destination %w", err)
+	}
+	balances, err := lockAccounts(ctx, tx, locked...)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkWalletsOpen(ctx, tx, sourceAccountID, destinationAccountID); err != nil {
+		return 0, err
+	}
+
+	sourceBalance := balances[sourceAccountID]
+	if sourceBalance.LessThan(fee.Total) {
+		return 0, fmt.Errorf("%w: cannot transfer %s %s with a fee of %s, available balance is %s", ErrInsufficientBalance, req.Amount.String(), currency, fee.Fee.String(), sourceBalance.String())
+	}
+
+	now := time.Now()
+	if !move {
+		if err := checkSpendingLimits(ctx, tx, req.From, currency, req.Amount, now); err != nil {
+			return 0, err
+		}
+	}
+
+	var transactionID int
+	stmt := `
+        INSERT INTO transactions (type, userIDSource, userIDEndpoint, source_wallet_id, destination_wallet_id, amount, currency, createdat,
+                                  description, external_reference, metadata)
+        VALUES ('transfer', $1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING id
+    `
+	err = tx.QueryRowContext(ctx, stmt, req.From, req.To, sourceAccountID, destinationAccountID, req.Amount, currency, now,
+		details.description, details.reference, details.metadata).Scan(&transactionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add transaction: %w", err)
+	}
+
+	_, err = postEntry(ctx, tx, &JournalEntry{
+		TransactionID: &transactionID,
+		Description:   "transfer",
+		CreatedAt:     now,
+		Postings: []*Posting{
+			{AccountID: sourceAccountID, Currency: currency, Amount: req.Amount.Neg()},
+			{AccountID: destinationAccountID, Currency: currency, Amount: req.Amount},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if fee.Fee.IsPositive() {
+		if err := chargeFee(ctx, tx, transactionID, req.From, sourceAccountID, currency, fee.Fee, now); err != nil {
+			return 0, err
+		}
+	}
+
+	return transactionID, nil
 }
@@ -0,0 +1,101 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// Overdraft is a user account whose ledger balance is below zero
+type Overdraft struct {
+	UserID             int             `json:"UserID"`
+	Currency           string          `json:"Currency"`
+	Balance            decimal.Decimal `json:"Balance"`
+	OverdraftLimit     decimal.Decimal `json:"OverdraftLimit"`
+	NegativeSince      time.Time       `json:"NegativeSince"`
+	NegativeForSeconds int64           `json:"NegativeForSeconds"`
+}
+
+// SetOverdraftLimit lets the user's account in currency go down to -limit. A zero limit removes the
+// overdraft; lowering the limit below the current debt only blocks further debits
+func (u *PostgresRepository) SetOverdraftLimit(ctx context.Context, userID int, currency string, limit decimal.Decimal) (*Balance, error) {
+	currency = NormalizeCurrency(currency)
+	if limit.IsNegative() {
+		return nil, ErrInvalidAmount
+	}
+	if !limit.IsZero() {
+		if err := ValidateAmount(currency, limit); err != nil {
+			return nil, err
+		}
+	} else if _, err := CurrencyScale(currency); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	accountID, err := userAccountID(ctx, tx, userID, currency)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := lockAccounts(ctx, tx, accountID); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE accounts SET overdraft_limit = $1 WHERE id = $2`, limit, accountID); err != nil {
+		return nil, fmt.Errorf("failed to set overdraft limit: %w", err)
+	}
+
+	balance, err := accountBalance(ctx, tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return balance, nil
+}
+
+// ListOverdrafts returns the user accounts that are currently negative, longest in overdraft first
+func (u *PostgresRepository) ListOverdrafts(ctx context.Context) ([]*Overdraft, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+        SELECT user_id, currency, balance, overdraft_limit, negative_since
+        FROM accounts
+        WHERE user_id IS NOT NULL AND balance < 0 AND negative_since IS NOT NULL
+        ORDER BY negative_since, user_id
+    `
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get overdrafts: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+
+	var overdrafts []*Overdraft
+	for rows.Next() {
+		var o Overdraft
+		if err := rows.Scan(&o.UserID, &o.Currency, &o.Balance, &o.OverdraftLimit, &o.NegativeSince); err != nil {
+			return nil, fmt.Errorf("failed to scan overdraft: %w", err)
+		}
+		o.NegativeForSeconds = int64(now.Sub(o.NegativeSince) / time.Second)
+		overdrafts = append(overdrafts, &o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get overdrafts: %w", err)
+	}
+
+	return overdrafts, nil
+}
@@ -0,0 +1,639 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// PayoutMode decides what happens to a batch when one of its payouts is rejected
+type PayoutMode string
+
+const (
+	// PayoutAllOrNothing rolls the whole batch back when any payout is rejected
+	PayoutAllOrNothing PayoutMode = "all_or_nothing"
+	// PayoutBestEffort records rejected payouts as failed and carries on with the rest
+	PayoutBestEffort PayoutMode = "best_effort"
+)
+
+// PayoutStatus is the lifecycle state of a payout batch
+type PayoutStatus string
+
+const (
+	PayoutPending    PayoutStatus = "pending"
+	PayoutProcessing PayoutStatus = "processing"
+	PayoutCompleted  PayoutStatus = "completed"
+	PayoutFailed     PayoutStatus = "failed"
+)
+
+// PayoutItemStatus is the outcome of a single payout of a batch
+type PayoutItemStatus string
+
+const (
+	PayoutItemPending   PayoutItemStatus = "pending"
+	PayoutItemSucceeded PayoutItemStatus = "succeeded"
+	PayoutItemFailed    PayoutItemStatus = "failed"
+)
+
+const (
+	// MaxPayoutItems is the largest batch that is accepted
+	MaxPayoutItems = 10000
+	// MaxAllOrNothingPayoutItems is the largest all-or-nothing batch that is accepted. Such a batch keeps
+	// the accounts of every destination locked until it is done, so it has to fit well inside dbTimeout
+	MaxAllOrNothingPayoutItems = 100
+	// payoutChunkSize is how many payouts of a best-effort batch are executed per database transaction
+	payoutChunkSize = 50
+	// payoutTimeout bounds each chunk of a best-effort batch
+	payoutTimeout = 2 * time.Minute
+)
+
+// PayoutItem is one transfer of a batch from the batch's source to DestinationID
+type PayoutItem struct {
+	ID            int              `json:"ID"`
+	Position      int              `json:"Position"`
+	DestinationID int              `json:"DestinationID"`
+	Amount        decimal.Decimal  `json:"Amount"`
+	Status        PayoutItemStatus `json:"Status"`
+	TransactionID *int             `json:"TransactionID"`
+	Error         *string          `json:"Error"`
+}
+
+// PayoutBatch is a list of transfers from one source user in one currency. Total is the sum of the item
+// amounts and Fees the fees they are expected to be charged, both worked out when the batch is created
+type PayoutBatch struct {
+	ID          int             `json:"ID"`
+	SourceID    int             `json:"SourceID"`
+	Currency    string          `json:"Currency"`
+	Mode        PayoutMode      `json:"Mode"`
+	Status      PayoutStatus    `json:"Status"`
+	Total       decimal.Decimal `json:"Total"`
+	Fees        decimal.Decimal `json:"Fees"`
+	ItemCount   int             `json:"ItemCount"`
+	Succeeded   int             `json:"Succeeded"`
+	Failed      int             `json:"Failed"`
+	Error       *string         `json:"Error"`
+	CreatedAt   time.Time       `json:"CreatedAt"`
+	UpdatedAt   time.Time       `json:"UpdatedAt"`
+	CompletedAt *time.Time      `json:"CompletedAt"`
+	Items       []*PayoutItem   `json:"Items"`
+}
+
+// validate checks the mode and the items of a new batch and normalizes its currency
+func (b *PayoutBatch) validate() error {
+	b.Currency = NormalizeCurrency(b.Currency)
+	if _, err := CurrencyScale(b.Currency); err != nil {
+		return err
+	}
+	if b.Mode != PayoutAllOrNothing && b.Mode != PayoutBestEffort {
+		return fmt.Errorf("%w: mode must be %s or %s", ErrInvalidPayoutBatch, PayoutAllOrNothing, PayoutBestEffort)
+	}
+	if len(b.Items) == 0 {
+		return fmt.Errorf("%w: batch has no items", ErrInvalidPayoutBatch)
+	}
+	if len(b.Items) > MaxPayoutItems {
+		return fmt.Errorf("%w: batch has %d items, at most %d are allowed", ErrInvalidPayoutBatch, len(b.Items), MaxPayoutItems)
+	}
+	if b.Mode == PayoutAllOrNothing && len(b.Items) > MaxAllOrNothingPayoutItems {
+		return fmt.Errorf("%w: an %s batch has %d items, at most %d are allowed", ErrInvalidPayoutBatch, PayoutAllOrNothing,
+			len(b.Items), MaxAllOrNothingPayoutItems)
+	}
+
+	for i, item := range b.Items {
+		if item.DestinationID == b.SourceID {
+			return fmt.Errorf("%w: item %d pays the source user", ErrInvalidPayoutBatch, i)
+		}
+		if err := ValidateAmount(b.Currency, item.Amount); err != nil {
+			return fmt.Errorf("%w: item %d: %w", ErrInvalidPayoutBatch, i, err)
+		}
+	}
+
+	return nil
+}
+
+// CreatePayoutBatch validates a batch and stores it as pending. The amounts and their fees are checked
+// against the available balance of the source up front, so a batch that can't be covered is refused
+// outright. The batch is executed by ProcessPayoutBatch
+func (u *PostgresRepository) CreatePayoutBatch(ctx context.Context, batch *PayoutBatch) (*PayoutBatch, error) {
+	if err := batch.validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, payoutTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	batch.Total, batch.Fees = decimal.Zero, decimal.Zero
+	fees := make(map[string]decimal.Decimal)
+	for _, item := range batch.Items {
+		fee, ok := fees[item.Amount.String()]
+		if !ok {
+			quote, err := quoteFee(ctx, tx, &FeeRequest{Type: TransactionTransfer, Amount: item.Amount, Currency: batch.Currency})
+			if err != nil {
+				return nil, err
+			}
+			fee = quote.Fee
+			fees[item.Amount.String()] = fee
+		}
+		batch.Total = batch.Total.Add(item.Amount)
+		batch.Fees = batch.Fees.Add(fee)
+	}
+
+	accountID, err := userAccountID(ctx, tx, batch.SourceID, batch.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("source %w", err)
+	}
+	if err := checkAccountStatus(ctx, tx, batch.SourceID, flowOut); err != nil {
+		return nil, fmt.Errorf("source %w", err)
+	}
+	available, err := lockAccounts(ctx, tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if required := batch.Total.Add(batch.Fees); available[accountID].LessThan(required) {
+		return nil, fmt.Errorf("%w: batch needs %s %s including fees, available balance is %s", ErrInsufficientBalance, required.String(), batch.Currency, available[accountID].String())
+	}
+
+	now := time.Now()
+	batch.Status = PayoutPending
+	batch.ItemCount = len(batch.Items)
+	batch.CreatedAt, batch.UpdatedAt = now, now
+
+	stmt := `
+        INSERT INTO payout_batches (source_id, currency, mode, status, total, fees, item_count, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id
+    `
+	err = tx.QueryRowContext(ctx, stmt, batch.SourceID, batch.Currency, batch.Mode, batch.Status, batch.Total, batch.Fees,
+		batch.ItemCount, batch.CreatedAt, batch.UpdatedAt).Scan(&batch.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add payout batch: %w", err)
+	}
+
+	insert, err := tx.PrepareContext(ctx, `
+        INSERT INTO payout_items (batch_id, position, destination_id, amount, status)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare payout items: %w", err)
+	}
+	defer insert.Close()
+
+	for i, item := range batch.Items {
+		item.Position = i
+		item.Status = PayoutItemPending
+		if err := insert.QueryRowContext(ctx, batch.ID, item.Position, item.DestinationID, item.Amount, item.Status).Scan(&item.ID); err != nil {
+			return nil, fmt.Errorf("failed to add payout item: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return batch, nil
+}
+
+// GetPayoutBatch returns a batch with the result of each of its payouts
+func (u *PostgresRepository) GetPayoutBatch(ctx context.Context, id int) (*PayoutBatch, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	return getPayoutBatch(ctx, db, id)
+}
+
+// ProcessPayoutBatch executes the pending payouts of a batch and returns the batch with their results.
+// A batch that another worker is processing is returned as it stands
+func (u *PostgresRepository) ProcessPayoutBatch(ctx context.Context, id int) (*PayoutBatch, error) {
+	mode, err := payoutMode(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == PayoutAllOrNothing {
+		err = processAllOrNothing(ctx, id)
+	} else {
+		err = processBestEffort(ctx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return u.GetPayoutBatch(ctx, id)
+}
+
+// payoutMode returns the mode of a batch
+func payoutMode(ctx context.Context, id int) (PayoutMode, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	var mode PayoutMode
+	err := db.QueryRowContext(ctx, `SELECT mode FROM payout_batches WHERE id = $1`, id).Scan(&mode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("payout batch %d: %w", id, ErrPayoutBatchNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get payout batch: %w", err)
+	}
+
+	return mode, nil
+}
+
+// ProcessPendingPayouts executes the batches that are still pending or were interrupted mid-way, oldest
+// first. It returns how many batches it went through
+func (u *PostgresRepository) ProcessPendingPayouts(ctx context.Context) (int, error) {
+	ids, err := openPayoutBatchIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var processed int
+	var errs []error
+	for _, id := range ids {
+		if _, err := u.ProcessPayoutBatch(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("payout batch %d: %w", id, err))
+			continue
+		}
+		processed++
+	}
+
+	return processed, errors.Join(errs...)
+}
+
+// openPayoutBatchIDs lists the batches that still have payouts to execute
+func openPayoutBatchIDs(ctx context.Context) ([]int, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `SELECT id FROM payout_batches WHERE status IN ('pending', 'processing') ORDER BY id LIMIT 100`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open payout batches: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan payout batch: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get open payout batches: %w", err)
+	}
+
+	return ids, nil
+}
+
+// processAllOrNothing executes every payout of a batch in a single database transaction. The accounts of
+// the source and of every destination are locked up front, in id order, so the batch can't deadlock with
+// concurrent transfers; MaxAllOrNothingPayoutItems keeps that within dbTimeout. When a payout is
+// rejected nothing is paid and the batch is recorded as failed; any other error leaves the batch pending
+func processAllOrNothing(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	batch, err := lockPayoutBatch(ctx, tx, id)
+	if batch == nil || err != nil {
+		return err
+	}
+
+	failed, reason, err := payAll(ctx, tx, batch)
+	if err != nil {
+		return err
+	}
+	if failed != nil {
+		if err := tx.Rollback(); err != nil {
+			return fmt.Errorf("failed to roll back payouts: %w", err)
+		}
+		return failPayoutBatch(ctx, id, failed, reason)
+	}
+
+	now := time.Now()
+	stmt := `UPDATE payout_items SET status = $1, transaction_id = $2 WHERE id = $3`
+	for _, item := range batch.Items {
+		if _, err := tx.ExecContext(ctx, stmt, PayoutItemSucceeded, item.TransactionID, item.ID); err != nil {
+			return fmt.Errorf("failed to update payout item: %w", err)
+		}
+	}
+
+	stmt = `
+        UPDATE payout_batches SET status = $1, succeeded = item_count, failed = 0, updated_at = $2, completed_at = $2
+        WHERE id = $3
+    `
+	if _, err := tx.ExecContext(ctx, stmt, PayoutCompleted, now, id); err != nil {
+		return fmt.Errorf("failed to update payout batch: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// payAll locks the users and accounts of a batch and executes its pending payouts. It returns the first
+// rejected payout with the reason, leaving tx to be rolled back by the caller
+func payAll(ctx context.Context, tx *sql.Tx, batch *PayoutBatch) (*PayoutItem, string, error) {
+	sourceAccountID, err := userAccountID(ctx, tx, batch.SourceID, batch.Currency)
+	if err != nil {
+		return nil, "", fmt.Errorf("source %w", err)
+	}
+
+	// Every user is share-locked before the accounts, in the same order as a single transfer
+	if err := checkAccountStatus(ctx, tx, batch.SourceID, flowOut); err != nil {
+		return nil, "", fmt.Errorf("source %w", err)
+	}
+	for _, item := range batch.Items {
+		err := checkAccountStatus(ctx, tx, item.DestinationID, flowIn)
+		if isTransferRejection(err) {
+			return item, fmt.Errorf("destination %w", err).Error(), nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	accountIDs := []int{sourceAccountID}
+	for _, item := range batch.Items {
+		accountID, err := userAccountID(ctx, tx, item.DestinationID, batch.Currency)
+		if errors.Is(err, ErrUserNotFound) {
+			return item, fmt.Errorf("destination %w", err).Error(), nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		accountIDs = append(accountIDs, accountID)
+	}
+	if _, err := lockAccounts(ctx, tx, accountIDs...); err != nil {
+		return nil, "", err
+	}
+
+	for _, item := range batch.Items {
+		transactionID, err := transfer(ctx, tx, &TransferRequest{
+			From:     batch.SourceID,
+			To:       item.DestinationID,
+			Amount:   item.Amount,
+			Currency: batch.Currency,
+		})
+		if err != nil {
+			if isTransferRejection(err) {
+				return item, err.Error(), nil
+			}
+			return nil, "", err
+		}
+		item.TransactionID = &transactionID
+	}
+
+	return nil, "", nil
+}
+
+// failPayoutBatch records that an all-or-nothing batch was rolled back because the payout failed was rejected for reason
+func failPayoutBatch(ctx context.Context, id int, failed *PayoutItem, reason string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	batch, err := lockPayoutBatch(ctx, tx, id)
+	if batch == nil || err != nil {
+		return err
+	}
+
+	stmt := `
+        UPDATE payout_items SET status = $1, error = CASE WHEN id = $2 THEN $3 ELSE 'batch rolled back' END
+        WHERE batch_id = $4 AND status = 'pending'
+    `
+	if _, err := tx.ExecContext(ctx, stmt, PayoutItemFailed, failed.ID, reason, id); err != nil {
+		return fmt.Errorf("failed to update payout items: %w", err)
+	}
+
+	message := fmt.Sprintf("item %d: %s", failed.Position, reason)
+	stmt = `
+        UPDATE payout_batches SET status = $1, succeeded = 0, failed = item_count, error = $2, updated_at = $3, completed_at = $3
+        WHERE id = $4
+    `
+	if _, err := tx.ExecContext(ctx, stmt, PayoutFailed, message, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to update payout batch: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// processBestEffort executes the pending payouts of a batch a chunk at a time, each chunk in its own
+// database transaction so a large batch doesn't hold the source account for long. Rejected payouts are
+// rolled back to a savepoint and recorded as failed. Because every chunk records its results as it
+// commits, a batch interrupted by a restart picks up where it stopped
+func processBestEffort(ctx context.Context, id int) error {
+	for {
+		done, err := processPayoutChunk(ctx, id)
+		if done || err != nil {
+			return err
+		}
+	}
+}
+
+// processPayoutChunk executes the next chunk of a best-effort batch. It reports whether the batch has no
+// pending payouts left or is being processed elsewhere
+func processPayoutChunk(ctx context.Context, id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, payoutTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	batch, err := lockPayoutBatch(ctx, tx, id)
+	if batch == nil || err != nil {
+		return true, err
+	}
+
+	now := time.Now()
+	status := PayoutProcessing
+	if len(batch.Items) == 0 {
+		status = PayoutCompleted
+	}
+
+	chunk := batch.Items
+	if len(chunk) > payoutChunkSize {
+		chunk = chunk[:payoutChunkSize]
+	}
+
+	// The users of the chunk are share-locked before the first transfer locks accounts, so the chunk
+	// takes users before accounts like a single transfer does. Rejections are left to transfer, which
+	// records them per payout
+	if err := checkAccountStatus(ctx, tx, batch.SourceID, flowOut); err != nil && !isTransferRejection(err) {
+		return false, err
+	}
+	for _, item := range chunk {
+		if err := checkAccountStatus(ctx, tx, item.DestinationID, flowIn); err != nil && !isTransferRejection(err) {
+			return false, err
+		}
+	}
+
+	var succeeded, failed int
+	for _, item := range chunk {
+
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT payout_item`); err != nil {
+			return false, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+
+		transactionID, err := transfer(ctx, tx, &TransferRequest{
+			From:     batch.SourceID,
+			To:       item.DestinationID,
+			Amount:   item.Amount,
+			Currency: batch.Currency,
+		})
+		if err != nil {
+			if !isTransferRejection(err) {
+				return false, err
+			}
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT payout_item`); err != nil {
+				return false, fmt.Errorf("failed to roll back to savepoint: %w", err)
+			}
+			message := err.Error()
+			item.Status, item.Error = PayoutItemFailed, &message
+			failed++
+		} else {
+			item.Status, item.TransactionID = PayoutItemSucceeded, &transactionID
+			succeeded++
+		}
+
+		stmt := `UPDATE payout_items SET status = $1, transaction_id = $2, error = $3 WHERE id = $4`
+		if _, err := tx.ExecContext(ctx, stmt, item.Status, item.TransactionID, item.Error, item.ID); err != nil {
+			return false, fmt.Errorf("failed to update payout item: %w", err)
+		}
+	}
+
+	var completedAt *time.Time
+	if status == PayoutCompleted {
+		completedAt = &now
+	}
+	stmt := `
+        UPDATE payout_batches SET status = $1, succeeded = succeeded + $2, failed = failed + $3, updated_at = $4, completed_at = $5
+        WHERE id = $6
+    `
+	if _, err := tx.ExecContext(ctx, stmt, status, succeeded, failed, now, completedAt, id); err != nil {
+		return false, fmt.Errorf("failed to update payout batch: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return status == PayoutCompleted, nil
+}
+
+// lockPayoutBatch locks an open batch and loads its pending payouts in order. It returns nil when the
+// batch is already finished or another worker holds it
+func lockPayoutBatch(ctx context.Context, tx *sql.Tx, id int) (*PayoutBatch, error) {
+	row := tx.QueryRowContext(ctx, `SELECT `+payoutBatchColumns+` FROM payout_batches WHERE id = $1 FOR UPDATE SKIP LOCKED`, id)
+	batch, err := scanPayoutBatch(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock payout batch: %w", err)
+	}
+	if batch.Status != PayoutPending && batch.Status != PayoutProcessing {
+		return nil, nil
+	}
+
+	if batch.Items, err = payoutItems(ctx, tx, id, true); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// querier is implemented by *sql.DB and *sql.Tx
+type querier interface {
+	queryRower
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// payoutBatchColumns are the columns scanned by scanPayoutBatch
+const payoutBatchColumns = `
+    id, source_id, currency, mode, status, total, fees, item_count, succeeded, failed, error, created_at,
+    updated_at, completed_at
+`
+
+// scanPayoutBatch reads a row selected with payoutBatchColumns
+func scanPayoutBatch(row rowScanner) (*PayoutBatch, error) {
+	var b PayoutBatch
+	err := row.Scan(&b.ID, &b.SourceID, &b.Currency, &b.Mode, &b.Status, &b.Total, &b.Fees, &b.ItemCount,
+		&b.Succeeded, &b.Failed, &b.Error, &b.CreatedAt, &b.UpdatedAt, &b.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// getPayoutBatch loads a batch with all of its items
+func getPayoutBatch(ctx context.Context, q querier, id int) (*PayoutBatch, error) {
+	batch, err := scanPayoutBatch(q.QueryRowContext(ctx, `SELECT `+payoutBatchColumns+` FROM payout_batches WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("payout batch %d: %w", id, ErrPayoutBatchNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payout batch: %w", err)
+	}
+
+	if batch.Items, err = payoutItems(ctx, q, id, false); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// payoutItems loads the items of a batch in order, only the pending ones if pending is set
+func payoutItems(ctx context.Context, q querier, batchID int, pending bool) ([]*PayoutItem, error) {
+	query := `
+        SELECT id, position, destination_id, amount, status, transaction_id, error
+        FROM payout_items
+        WHERE batch_id = $1 AND (NOT $2 OR status = 'pending')
+        ORDER BY position
+    `
+	rows, err := q.QueryContext(ctx, query, batchID, pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payout items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []*PayoutItem{}
+	for rows.Next() {
+		var item PayoutItem
+		if err := rows.Scan(&item.ID, &item.Position, &item.DestinationID, &item.Amount, &item.Status, &item.TransactionID, &item.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan payout item: %w", err)
+		}
+		items = append(items, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get payout items: %w", err)
+	}
+
+	return items, nil
+}
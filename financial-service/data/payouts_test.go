@@ -0,0 +1,93 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPayoutBatchValidate checks the batches that are refused before touching the database
+func TestPayoutBatchValidate(t *testing.T) {
+	item := func(destination int, amount string) *PayoutItem {
+		return &PayoutItem{DestinationID: destination, Amount: decimal.RequireFromString(amount)}
+	}
+
+	tests := []struct {
+		name  string
+		batch PayoutBatch
+		err   error
+	}{
+		{
+			name:  "valid batch",
+			batch: PayoutBatch{SourceID: 1, Currency: "usd", Mode: PayoutBestEffort, Items: []*PayoutItem{item(2, "10"), item(3, "0.5")}},
+		},
+		{
+			name:  "unknown mode",
+			batch: PayoutBatch{SourceID: 1, Currency: "RUB", Mode: "sometimes", Items: []*PayoutItem{item(2, "10")}},
+			err:   ErrInvalidPayoutBatch,
+		},
+		{
+			name:  "no items",
+			batch: PayoutBatch{SourceID: 1, Currency: "RUB", Mode: PayoutAllOrNothing},
+			err:   ErrInvalidPayoutBatch,
+		},
+		{
+			name:  "pays the source",
+			batch: PayoutBatch{SourceID: 1, Currency: "RUB", Mode: PayoutAllOrNothing, Items: []*PayoutItem{item(2, "10"), item(1, "10")}},
+			err:   ErrInvalidPayoutBatch,
+		},
+		{
+			name:  "negative amount",
+			batch: PayoutBatch{SourceID: 1, Currency: "RUB", Mode: PayoutBestEffort, Items: []*PayoutItem{item(2, "-10")}},
+			err:   ErrInvalidAmount,
+		},
+		{
+			name:  "too many decimal places",
+			batch: PayoutBatch{SourceID: 1, Currency: "JPY", Mode: PayoutBestEffort, Items: []*PayoutItem{item(2, "10.5")}},
+			err:   ErrInvalidScale,
+		},
+		{
+			name:  "unsupported currency",
+			batch: PayoutBatch{SourceID: 1, Currency: "XXX", Mode: PayoutBestEffort, Items: []*PayoutItem{item(2, "10")}},
+			err:   ErrUnsupportedCurrency,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.batch.validate()
+			if tt.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+// TestPayoutBatchValidate_TooManyItems checks the cap on the size of a batch
+func TestPayoutBatchValidate_TooManyItems(t *testing.T) {
+	batch := PayoutBatch{SourceID: 1, Currency: "RUB", Mode: PayoutBestEffort}
+	for i := 0; i <= MaxPayoutItems; i++ {
+		batch.Items = append(batch.Items, &PayoutItem{DestinationID: 2, Amount: decimal.NewFromInt(1)})
+	}
+
+	assert.ErrorIs(t, batch.validate(), ErrInvalidPayoutBatch)
+	assert.Equal(t, "RUB", batch.Currency)
+}
+
+// TestPayoutBatchValidate_AllOrNothingTooManyItems checks the lower cap on all-or-nothing batches
+func TestPayoutBatchValidate_AllOrNothingTooManyItems(t *testing.T) {
+	batch := PayoutBatch{SourceID: 1, Currency: "RUB", Mode: PayoutAllOrNothing}
+	for i := 0; i < MaxAllOrNothingPayoutItems; i++ {
+		batch.Items = append(batch.Items, &PayoutItem{DestinationID: 2, Amount: decimal.NewFromInt(1)})
+	}
+	assert.NoError(t, batch.validate())
+
+	batch.Items = append(batch.Items, &PayoutItem{DestinationID: 2, Amount: decimal.NewFromInt(1)})
+	assert.ErrorIs(t, batch.validate(), ErrInvalidPayoutBatch)
+
+	batch.Mode = PayoutBestEffort
+	assert.NoError(t, batch.validate())
+}
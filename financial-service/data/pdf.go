@@ -0,0 +1,87 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	pdfLinesPerPage = 64
+	pdfFontSize     = 8
+	pdfLeading      = 11
+	pdfPageWidth    = 595
+	pdfPageHeight   = 842
+	pdfMargin       = 40
+)
+
+// renderPDF lays out lines of text in a monospaced font on A4 pages. The output has no timestamps or
+// random ids, so the same lines always produce the same bytes
+func renderPDF(lines []string) []byte {
+	var pages [][]string
+	for len(lines) > pdfLinesPerPage {
+		pages = append(pages, lines[:pdfLinesPerPage])
+		lines = lines[pdfLinesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// Objects 1-3 are the catalog, the page tree and the font; each page then takes a page object and
+	// a content stream
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>")
+
+	for i, page := range pages {
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", pdfFontSize, pdfLeading, pdfMargin, pdfPageHeight-pdfMargin)
+		for _, line := range page {
+			fmt.Fprintf(&content, "(%s) '\n", pdfEscape(line))
+		}
+		content.WriteString("ET")
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 5+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes()
+}
+
+// pdfEscape escapes a line for a PDF string literal, replacing characters outside printable ASCII, which
+// the standard fonts can't show
+func pdfEscape(line string) string {
+	var b strings.Builder
+	for _, r := range line {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,264 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// ReconciliationAccount is the system account that absorbs the adjustments written when reconciliation
+// repairs drift. There is one such account per currency
+const ReconciliationAccount = "reconciliation"
+
+const defaultReconcileBatchSize = 500
+
+// ReconcileOptions controls a reconciliation run. Without Repair the run only reports
+type ReconcileOptions struct {
+	BatchSize int
+	Repair    bool
+}
+
+// BalanceMismatch is a user account whose cached balance doesn't match its ledger. Expected is the sum of
+// the postings of every transaction that touched the account; AccountBalance is accounts.balance and
+// UserBalance is users.balance, which is only compared for default DefaultCurrency wallets
+type BalanceMismatch struct {
+	UserID         int                 `json:"UserID"`
+	AccountID      int                 `json:"AccountID"`
+	Currency       string              `json:"Currency"`
+	Expected       decimal.Decimal     `json:"Expected"`
+	AccountBalance decimal.Decimal     `json:"AccountBalance"`
+	UserBalance    decimal.NullDecimal `json:"UserBalance"`
+	Difference     decimal.Decimal     `json:"Difference"`
+	Repaired       bool                `json:"Repaired"`
+	TransactionID  *int                `json:"TransactionID"`
+}
+
+// ReconciliationReport is the outcome of a reconciliation run
+type ReconciliationReport struct {
+	StartedAt  time.Time          `json:"StartedAt"`
+	FinishedAt time.Time          `json:"FinishedAt"`
+	Scanned    int                `json:"Scanned"`
+	Repair     bool               `json:"Repair"`
+	Repaired   int                `json:"Repaired"`
+	Mismatches []*BalanceMismatch `json:"Mismatches"`
+}
+
+// actual returns the balance the user sees: users.balance for default DefaultCurrency wallets, the cached
+// account balance otherwise
+func (m *BalanceMismatch) actual() decimal.Decimal {
+	if m.UserBalance.Valid {
+		return m.UserBalance.Decimal
+	}
+	return m.AccountBalance
+}
+
+// drifted reports whether any of the balances disagree
+func (m *BalanceMismatch) drifted() bool {
+	if m.UserBalance.Valid && !m.UserBalance.Decimal.Equal(m.AccountBalance) {
+		return true
+	}
+	return !m.AccountBalance.Equal(m.Expected)
+}
+
+// Reconcile compares the cached balances of every user account with its ledger, a batch of accounts at a
+// time so that no long-lived locks are taken. Each batch is read in a single statement, which sees the
+// cached balances and the postings in one consistent state. With Repair set every mismatch is corrected
+// in its own transaction: an adjustment against the reconciliation account brings the ledger in line with
+// the balance the user sees, and the cached balances are aligned with it
+func (u *PostgresRepository) Reconcile(ctx context.Context, opts *ReconcileOptions) (*ReconciliationReport, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultReconcileBatchSize
+	}
+
+	report := ReconciliationReport{
+		StartedAt:  time.Now(),
+		Repair:     opts.Repair,
+		Mismatches: []*BalanceMismatch{},
+	}
+
+	lastID := 0
+	for {
+		mismatches, scanned, next, err := reconcileBatch(ctx, lastID, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		report.Scanned += scanned
+
+		for _, m := range mismatches {
+			if opts.Repair {
+				repaired, err := repairAccount(ctx, m.AccountID)
+				if err != nil {
+					return nil, fmt.Errorf("account %d: %w", m.AccountID, err)
+				}
+				if repaired != nil {
+					m = repaired
+					report.Repaired++
+				}
+			}
+			report.Mismatches = append(report.Mismatches, m)
+		}
+
+		if scanned < batchSize {
+			break
+		}
+		lastID = next
+	}
+
+	report.FinishedAt = time.Now()
+
+	return &report, nil
+}
+
+// reconcileBatch checks up to limit user accounts with ids above afterID. It returns the mismatches, how
+// many accounts it scanned and the last id it saw
+func reconcileBatch(ctx context.Context, afterID, limit int) ([]*BalanceMismatch, int, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+        SELECT a.id, a.user_id, a.currency, a.balance, CASE WHEN a.currency = $3 AND a.is_default THEN u.balance END,
+               COALESCE((SELECT SUM(p.amount) FROM postings p WHERE p.account_id = a.id), 0)
+        FROM accounts a
+        JOIN users u ON u.id = a.user_id
+        WHERE a.id > $1
+        ORDER BY a.id
+        LIMIT $2
+    `
+	rows, err := db.QueryContext(ctx, query, afterID, limit, DefaultCurrency)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to reconcile accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var mismatches []*BalanceMismatch
+	scanned, lastID := 0, afterID
+	for rows.Next() {
+		var m BalanceMismatch
+		if err := rows.Scan(&m.AccountID, &m.UserID, &m.Currency, &m.AccountBalance, &m.UserBalance, &m.Expected); err != nil {
+			return nil, 0, 0, fmt.Errorf("failed to scan account: %w", err)
+		}
+		scanned++
+		lastID = m.AccountID
+
+		if m.drifted() {
+			m.Difference = m.actual().Sub(m.Expected)
+			mismatches = append(mismatches, &m)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to reconcile accounts: %w", err)
+	}
+
+	return mismatches, scanned, lastID, nil
+}
+
+// repairAccount re-checks an account under lock and corrects it. It returns nil when the account no
+// longer drifts, for example because the mismatch was seen mid-way through another correction
+func repairAccount(ctx context.Context, accountID int) (*BalanceMismatch, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	m := BalanceMismatch{AccountID: accountID}
+	query := `
+        SELECT a.user_id, a.currency, a.balance, CASE WHEN a.currency = $2 AND a.is_default THEN u.balance END
+        FROM accounts a
+        JOIN users u ON u.id = a.user_id
+        WHERE a.id = $1
+        FOR UPDATE OF a, u
+    `
+	err = tx.QueryRowContext(ctx, query, accountID, DefaultCurrency).Scan(&m.UserID, &m.Currency, &m.AccountBalance, &m.UserBalance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock account: %w", err)
+	}
+
+	query = `SELECT COALESCE(SUM(amount), 0) FROM postings WHERE account_id = $1`
+	if err := tx.QueryRowContext(ctx, query, accountID).Scan(&m.Expected); err != nil {
+		return nil, fmt.Errorf("failed to sum postings: %w", err)
+	}
+	if !m.drifted() {
+		return nil, nil
+	}
+
+	now := time.Now()
+	target := m.actual()
+	m.Difference = target.Sub(m.Expected)
+
+	if !m.Difference.IsZero() {
+		reconciliationID, err := systemAccountID(ctx, tx, ReconciliationAccount, m.Currency)
+		if err != nil {
+			return nil, err
+		}
+
+		var transactionID int
+		stmt := `
+            INSERT INTO transactions (type, userIDSource, userIDEndpoint, source_wallet_id, destination_wallet_id, amount, currency, createdat)
+            VALUES ('adjustment', $1, $2, $3, $4, $5, $6, $7)
+            RETURNING id
+        `
+		var source, endpoint, sourceWallet, destinationWallet *int
+		if m.Difference.IsPositive() {
+			endpoint, destinationWallet = &m.UserID, &accountID
+		} else {
+			source, sourceWallet = &m.UserID, &accountID
+		}
+		err = tx.QueryRowContext(ctx, stmt, source, endpoint, sourceWallet, destinationWallet, m.Difference.Abs(), m.Currency, now).Scan(&transactionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add transaction: %w", err)
+		}
+		m.TransactionID = &transactionID
+
+		// Only the reconciliation side goes through the caches: the user's side already holds the
+		// balance the adjustment brings the ledger to
+		counterpart := &Posting{AccountID: reconciliationID, Currency: m.Currency, Amount: m.Difference.Neg()}
+		err = insertEntry(ctx, tx, &JournalEntry{
+			TransactionID: &transactionID,
+			Description:   "reconciliation adjustment",
+			CreatedAt:     now,
+			Postings: []*Posting{
+				{AccountID: accountID, Currency: m.Currency, Amount: m.Difference},
+				counterpart,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := applyPosting(ctx, tx, counterpart, now); err != nil {
+			return nil, err
+		}
+	}
+
+	stmt := `
+        UPDATE accounts SET balance = $1, updated_at = $2,
+            negative_since = CASE WHEN $1 < 0 THEN COALESCE(negative_since, $2) END
+        WHERE id = $3
+    `
+	if _, err := tx.ExecContext(ctx, stmt, target, now, accountID); err != nil {
+		return nil, fmt.Errorf("failed to update account balance: %w", err)
+	}
+	if m.UserBalance.Valid {
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET balance = $1, updated_at = $2 WHERE id = $3`, target, now, m.UserID); err != nil {
+			return nil, fmt.Errorf("failed to update balance: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	m.Repaired = true
+
+	return &m, nil
+}
@@ -0,0 +1,49 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalanceMismatch_Drifted(t *testing.T) {
+	hundred := decimal.NewFromInt(100)
+
+	tests := []struct {
+		name     string
+		mismatch BalanceMismatch
+		drifted  bool
+		actual   decimal.Decimal
+	}{
+		{
+			name:     "balanced default currency account",
+			mismatch: BalanceMismatch{Expected: hundred, AccountBalance: hundred, UserBalance: decimal.NewNullDecimal(hundred)},
+			actual:   hundred,
+		},
+		{
+			name:     "users.balance drifted from the ledger",
+			mismatch: BalanceMismatch{Expected: hundred, AccountBalance: hundred, UserBalance: decimal.NewNullDecimal(decimal.NewFromInt(90))},
+			drifted:  true,
+			actual:   decimal.NewFromInt(90),
+		},
+		{
+			name:     "account cache drifted from the ledger",
+			mismatch: BalanceMismatch{Expected: hundred, AccountBalance: decimal.NewFromInt(110)},
+			drifted:  true,
+			actual:   decimal.NewFromInt(110),
+		},
+		{
+			name:     "balanced other currency account",
+			mismatch: BalanceMismatch{Expected: hundred, AccountBalance: decimal.RequireFromString("100.00")},
+			actual:   hundred,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.drifted, tt.mismatch.drifted())
+			assert.True(t, tt.mismatch.actual().Equal(tt.actual))
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// RefundRequest asks to move money of a transaction back to where it came from. A zero Amount refunds
+// everything that hasn't been refunded yet. Force lets the refund take the payer's balance below zero
+type RefundRequest struct {
+	TransactionID int
+	Amount        decimal.Decimal
+	Force         bool
+}
+
+// Refund creates a refund transaction linked to the original one and moves the money back in the same
+// database transaction. A transaction can be refunded several times until its amount is exhausted
+func (u *PostgresRepository) Refund(ctx context.Context, req *RefundRequest) (*Transactions, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := refund(ctx, tx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// refund reverses req.Amount of a transaction inside tx. The original row is locked first so concurrent
+// refunds of the same transaction can't exceed its amount
+func refund(ctx context.Context, tx *sql.Tx, req *RefundRequest) (*Transactions, error) {
+	var original Transactions
+	var destinationCurrency sql.NullString
+	query := `
+        SELECT id, type, useridsource, useridendpoint, source_wallet_id, destination_wallet_id, amount, currency, destination_currency
+        FROM transactions
+        WHERE id = $1
+        FOR UPDATE
+    `
+	err := tx.QueryRowContext(ctx, query, req.TransactionID).Scan(&original.ID, &original.Type, &original.UserIDSource,
+		&original.UserIDEndpoint, &original.SourceWalletID, &original.DestinationWalletID, &original.Amount, &original.Currency,
+		&destinationCurrency)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("transaction %d: %w", req.TransactionID, ErrTransactionNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+
+	switch original.Type {
+	case TransactionDeposit, TransactionWithdrawal, TransactionTransfer:
+	default:
+		return nil, fmt.Errorf("%w: %s transactions can't be refunded", ErrNotRefundable, original.Type)
+	}
+	if destinationCurrency.Valid {
+		return nil, fmt.Errorf("%w: conversion transfers can't be refunded", ErrNotRefundable)
+	}
+
+	var refunded decimal.Decimal
+	query = `SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE parent_id = $1 AND type = 'refund'`
+	if err := tx.QueryRowContext(ctx, query, original.ID).Scan(&refunded); err != nil {
+		return nil, fmt.Errorf("failed to get refunded amount: %w", err)
+	}
+
+	remaining := original.Amount.Sub(refunded)
+	if !remaining.IsPositive() {
+		return nil, fmt.Errorf("%w: transaction %d is already fully refunded", ErrRefundExceedsOriginal, original.ID)
+	}
+
+	amount := req.Amount
+	if amount.IsZero() {
+		amount = remaining
+	}
+	if err := ValidateAmount(original.Currency, amount); err != nil {
+		return nil, err
+	}
+	if amount.GreaterThan(remaining) {
+		return nil, fmt.Errorf("%w: only %s of transaction %d is left to refund", ErrRefundExceedsOriginal, remaining.String(), original.ID)
+	}
+
+	// The refund runs the original movement backwards: whoever received the money pays it back
+	payerID, err := partyAccountID(ctx, tx, original.UserIDEndpoint, original.DestinationWalletID, original.Currency)
+	if err != nil {
+		return nil, err
+	}
+	payeeID, err := partyAccountID(ctx, tx, original.UserIDSource, original.SourceWalletID, original.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	if original.UserIDEndpoint != nil {
+		if err := checkAccountStatus(ctx, tx, *original.UserIDEndpoint, flowOut); err != nil {
+			return nil, err
+		}
+	}
+	if original.UserIDSource != nil {
+		if err := checkAccountStatus(ctx, tx, *original.UserIDSource, flowIn); err != nil {
+			return nil, err
+		}
+	}
+	balances, err := lockAccounts(ctx, tx, payerID, payeeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkWalletsOpen(ctx, tx, payerID, payeeID); err != nil {
+		return nil, err
+	}
+
+	if original.UserIDEndpoint != nil && !req.Force && balances[payerID].LessThan(amount) {
+		return nil, fmt.Errorf("%w: cannot refund %s %s, recipient balance is %s", ErrInsufficientBalance, amount.String(), original.Currency, balances[payerID].String())
+	}
+
+	result := Transactions{
+		Type:           TransactionRefund,
+		UserIDSource:   original.UserIDEndpoint,
+		UserIDEndpoint: original.UserIDSource,
+		Amount:         amount,
+		Currency:       original.Currency,
+		ParentID:       &original.ID,
+		CreatedAt:      time.Now(),
+	}
+	if result.UserIDSource != nil {
+		result.SourceWalletID = &payerID
+	}
+	if result.UserIDEndpoint != nil {
+		result.DestinationWalletID = &payeeID
+	}
+
+	stmt := `
+        INSERT INTO transactions (type, userIDSource, userIDEndpoint, source_wallet_id, destination_wallet_id, amount, currency, parent_id, createdat)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id
+    `
+	err = tx.QueryRowContext(ctx, stmt, result.Type, result.UserIDSource, result.UserIDEndpoint, result.SourceWalletID,
+		result.DestinationWalletID, result.Amount, result.Currency, result.ParentID, result.CreatedAt).Scan(&result.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add transaction: %w", err)
+	}
+
+	_, err = postEntry(ctx, tx, &JournalEntry{
+		TransactionID: &result.ID,
+		Description:   "refund",
+		CreatedAt:     result.CreatedAt,
+		Postings: []*Posting{
+			{AccountID: payerID, Currency: result.Currency, Amount: amount.Neg()},
+			{AccountID: payeeID, Currency: result.Currency, Amount: amount},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// partyAccountID returns the account of a transaction party in currency: the wallet the transaction
+// used, the user's default wallet when that one has been closed since, or the external funding account
+// when the party is outside the service
+func partyAccountID(ctx context.Context, tx *sql.Tx, userID, walletID *int, currency string) (int, error) {
+	if userID == nil {
+		return systemAccountID(ctx, tx, ExternalFundingAccount, currency)
+	}
+
+	if walletID != nil {
+		var open bool
+		err := tx.QueryRowContext(ctx, `SELECT status = 'open' FROM accounts WHERE id = $1`, *walletID).Scan(&open)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get wallet: %w", err)
+		}
+		if open {
+			return *walletID, nil
+		}
+	}
+
+	return userAccountID(ctx, tx, *userID, currency)
+}
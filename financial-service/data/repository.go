@@ -1,10 +1,63 @@
 package data
 
-import "github.com/shopspring/decimal"
+import (
+	"context"
+	"github.com/shopspring/decimal"
+	"time"
+)
 
 type Repository interface {
 	GetLastTransactions(id int) ([]*Transactions, error)
 	AddMoney(id int, amount decimal.Decimal) error
 	DecreaseMoney(idSource int, amount decimal.Decimal) error
-	AddTransaction(amount decimal.Decimal, id ...int) error
+	AddTransaction(ctx context.Context, t *Transactions) error
+	Deposit(ctx context.Context, req *DepositRequest) (int, error)
+	Withdraw(ctx context.Context, req *WithdrawalRequest) (int, error)
+	Transfer(ctx context.Context, req *TransferRequest) (int, error)
+	PostEntry(ctx context.Context, entry *JournalEntry) error
+	VerifyBalance(ctx context.Context, userID int) ([]*BalanceCheck, error)
+	PurgeIdempotencyKeys(ctx context.Context) (int64, error)
+	SetRates(ctx context.Context, rates []*FXRate) error
+	CreateQuote(ctx context.Context, req *QuoteRequest) (*FXQuote, error)
+	Refund(ctx context.Context, req *RefundRequest) (*Transactions, error)
+	AuthorizeHold(ctx context.Context, req *HoldRequest) (*Hold, error)
+	CaptureHold(ctx context.Context, id int, amount decimal.Decimal) (*Hold, error)
+	VoidHold(ctx context.Context, id int) (*Hold, error)
+	ExpireHolds(ctx context.Context) (int64, error)
+	CreateSchedule(ctx context.Context, s *Schedule) (*Schedule, error)
+	GetSchedule(ctx context.Context, id int) (*Schedule, error)
+	CancelSchedule(ctx context.Context, id int) (*Schedule, error)
+	RunDueSchedules(ctx context.Context, now time.Time) (int, error)
+	QuoteFee(ctx context.Context, req *FeeRequest) (*FeeQuote, error)
+	CreateFeeRule(ctx context.Context, r *FeeRule) (*FeeRule, error)
+	ListFeeRules(ctx context.Context) ([]*FeeRule, error)
+	DeactivateFeeRule(ctx context.Context, id int) error
+	SetOverdraftLimit(ctx context.Context, userID int, currency string, limit decimal.Decimal) (*Balance, error)
+	ListOverdrafts(ctx context.Context) ([]*Overdraft, error)
+	SetSpendingLimit(ctx context.Context, l *SpendingLimit) (*SpendingLimit, error)
+	BalanceAsOf(ctx context.Context, userID int, currency string, asOf time.Time) (*HistoricalBalance, error)
+	SnapshotBalances(ctx context.Context, asOf time.Time) (int64, error)
+	GetStatement(ctx context.Context, req *StatementRequest) (*StatementDocument, error)
+	Reconcile(ctx context.Context, opts *ReconcileOptions) (*ReconciliationReport, error)
+	CreatePayoutBatch(ctx context.Context, batch *PayoutBatch) (*PayoutBatch, error)
+	GetPayoutBatch(ctx context.Context, id int) (*PayoutBatch, error)
+	ProcessPayoutBatch(ctx context.Context, id int) (*PayoutBatch, error)
+	ProcessPendingPayouts(ctx context.Context) (int, error)
+	CreateWallet(ctx context.Context, w *Wallet) (*Wallet, error)
+	ListWallets(ctx context.Context, userID int) ([]*Wallet, error)
+	GetWallet(ctx context.Context, id int) (*Wallet, error)
+	RenameWallet(ctx context.Context, id int, name string) (*Wallet, error)
+	CloseWallet(ctx context.Context, id int) (*Wallet, error)
+	GetWalletTransactions(walletID int) ([]*Transactions, error)
+	SetAccountStatus(ctx context.Context, userID int, status AccountStatus, reason string) (*AccountStatusChange, error)
+	CloseAccount(ctx context.Context, req *CloseAccountRequest) (*AccountStatusChange, error)
+	CreateUser(ctx context.Context, user *User) (*User, error)
+	GetUser(ctx context.Context, id int) (*User, error)
+	ListUsers(ctx context.Context, afterID, limit int) ([]*User, error)
+	UpdateUser(ctx context.Context, id int, update *UserUpdate) (*User, error)
+	DeleteUser(ctx context.Context, id int) error
+	GetTransactionHistory(ctx context.Context, req *HistoryRequest) (*TransactionPage, error)
+	GetTransaction(ctx context.Context, id int) (*TransactionRecord, error)
+	GetBalance(ctx context.Context, userID int, currency string) (*Balance, error)
+	GetBalances(ctx context.Context, userIDs []int, currency string) ([]*Balance, error)
 }
@@ -0,0 +1,508 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// ScheduleFrequency is how often a standing order repeats
+type ScheduleFrequency string
+
+const (
+	ScheduleDaily    ScheduleFrequency = "daily"
+	ScheduleWeekly   ScheduleFrequency = "weekly"
+	ScheduleMonthly  ScheduleFrequency = "monthly"
+	ScheduleInterval ScheduleFrequency = "interval"
+)
+
+// InsufficientBalancePolicy decides what a schedule does when the source can't cover a run
+type InsufficientBalancePolicy string
+
+const (
+	// PolicySkip records the run as skipped and waits for the next occurrence
+	PolicySkip InsufficientBalancePolicy = "skip"
+	// PolicyRetry tries again every RetryIntervalSeconds, up to MaxRetries times, before giving up on the occurrence
+	PolicyRetry InsufficientBalancePolicy = "retry"
+)
+
+// ScheduleStatus is the lifecycle state of a schedule
+type ScheduleStatus string
+
+const (
+	ScheduleActive    ScheduleStatus = "active"
+	ScheduleCompleted ScheduleStatus = "completed"
+	ScheduleCancelled ScheduleStatus = "cancelled"
+)
+
+// ScheduleRunStatus is the outcome of one run of a schedule
+type ScheduleRunStatus string
+
+const (
+	RunSucceeded ScheduleRunStatus = "succeeded"
+	RunSkipped   ScheduleRunStatus = "skipped"
+	RunRetrying  ScheduleRunStatus = "retrying"
+	RunFailed    ScheduleRunStatus = "failed"
+)
+
+const defaultRetryInterval = time.Hour
+
+// Schedule is a standing order that transfers Amount from UserIDSource to UserIDEndpoint on every
+// occurrence. Daily and weekly schedules run at the time of StartAt, monthly ones on DayOfMonth (the last
+// day of shorter months) and interval ones every IntervalSeconds. A schedule completes after
+// MaxOccurrences successful transfers or once the next occurrence is past EndAt
+type Schedule struct {
+	ID                    int                       `json:"ID"`
+	UserIDSource          int                       `json:"UserIDSource"`
+	UserIDEndpoint        int                       `json:"UserIDEndpoint"`
+	Amount                decimal.Decimal           `json:"Amount"`
+	Currency              string                    `json:"Currency"`
+	Frequency             ScheduleFrequency         `json:"Frequency"`
+	IntervalSeconds       int64                     `json:"IntervalSeconds"`
+	DayOfMonth            int                       `json:"DayOfMonth"`
+	StartAt               time.Time                 `json:"StartAt"`
+	EndAt                 *time.Time                `json:"EndAt"`
+	MaxOccurrences        int                       `json:"MaxOccurrences"`
+	Occurrences           int                       `json:"Occurrences"`
+	OnInsufficientBalance InsufficientBalancePolicy `json:"OnInsufficientBalance"`
+	MaxRetries            int                       `json:"MaxRetries"`
+	RetryIntervalSeconds  int64                     `json:"RetryIntervalSeconds"`
+	Retries               int                       `json:"Retries"`
+	Status                ScheduleStatus            `json:"Status"`
+	NextRunAt             time.Time                 `json:"NextRunAt"`
+	RetryAt               *time.Time                `json:"RetryAt"`
+	CreatedAt             time.Time                 `json:"CreatedAt"`
+	UpdatedAt             time.Time                 `json:"UpdatedAt"`
+	Runs                  []*ScheduleRun            `json:"Runs,omitempty"`
+}
+
+// ScheduleRun records the outcome of one attempt to execute an occurrence of a schedule
+type ScheduleRun struct {
+	ID            int               `json:"ID"`
+	ScheduleID    int               `json:"ScheduleID"`
+	ScheduledFor  time.Time         `json:"ScheduledFor"`
+	Status        ScheduleRunStatus `json:"Status"`
+	TransactionID *int              `json:"TransactionID"`
+	Error         *string           `json:"Error"`
+	CreatedAt     time.Time         `json:"CreatedAt"`
+}
+
+const scheduleColumns = `
+    id, user_id_source, user_id_endpoint, amount, currency, frequency, COALESCE(interval_seconds, 0),
+    COALESCE(day_of_month, 0), start_at, end_at, COALESCE(max_occurrences, 0), occurrences, on_insufficient,
+    max_retries, retry_interval_seconds, retries, status, next_run_at, retry_at, created_at, updated_at
+`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSchedule reads a row selected with scheduleColumns
+func scanSchedule(row rowScanner) (*Schedule, error) {
+	var s Schedule
+	err := row.Scan(&s.ID, &s.UserIDSource, &s.UserIDEndpoint, &s.Amount, &s.Currency, &s.Frequency, &s.IntervalSeconds,
+		&s.DayOfMonth, &s.StartAt, &s.EndAt, &s.MaxOccurrences, &s.Occurrences, &s.OnInsufficientBalance,
+		&s.MaxRetries, &s.RetryIntervalSeconds, &s.Retries, &s.Status, &s.NextRunAt, &s.RetryAt, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// validate checks the transfer and the recurrence rule of a new schedule and fills in defaults
+func (s *Schedule) validate(now time.Time) error {
+	s.Currency = NormalizeCurrency(s.Currency)
+	if err := ValidateAmount(s.Currency, s.Amount); err != nil {
+		return err
+	}
+	if s.UserIDSource == s.UserIDEndpoint {
+		return ErrSameAccount
+	}
+
+	if s.StartAt.IsZero() {
+		s.StartAt = now
+	}
+	if s.EndAt != nil && s.EndAt.Before(s.StartAt) {
+		return fmt.Errorf("%w: end is before start", ErrInvalidSchedule)
+	}
+
+	switch s.Frequency {
+	case ScheduleDaily, ScheduleWeekly:
+	case ScheduleMonthly:
+		if s.DayOfMonth == 0 {
+			s.DayOfMonth = s.StartAt.Day()
+		}
+		if s.DayOfMonth < 1 || s.DayOfMonth > 31 {
+			return fmt.Errorf("%w: day of month must be between 1 and 31", ErrInvalidSchedule)
+		}
+	case ScheduleInterval:
+		if s.IntervalSeconds <= 0 {
+			return fmt.Errorf("%w: interval must be positive", ErrInvalidSchedule)
+		}
+	default:
+		return fmt.Errorf("%w: unknown frequency %q", ErrInvalidSchedule, s.Frequency)
+	}
+
+	if s.OnInsufficientBalance == "" {
+		s.OnInsufficientBalance = PolicySkip
+	}
+	if s.OnInsufficientBalance != PolicySkip && s.OnInsufficientBalance != PolicyRetry {
+		return fmt.Errorf("%w: unknown insufficient balance policy %q", ErrInvalidSchedule, s.OnInsufficientBalance)
+	}
+	if s.RetryIntervalSeconds == 0 {
+		s.RetryIntervalSeconds = int64(defaultRetryInterval / time.Second)
+	}
+	if s.MaxOccurrences < 0 || s.MaxRetries < 0 || s.RetryIntervalSeconds < 0 {
+		return fmt.Errorf("%w: counts and intervals can't be negative", ErrInvalidSchedule)
+	}
+
+	return nil
+}
+
+// firstRun returns the first occurrence of the schedule at or after StartAt
+func (s *Schedule) firstRun() time.Time {
+	if s.Frequency != ScheduleMonthly {
+		return s.StartAt
+	}
+
+	first := s.monthlyRun(s.StartAt.Year(), s.StartAt.Month())
+	if first.Before(s.StartAt) {
+		first = s.monthlyRun(s.StartAt.Year(), s.StartAt.Month()+1)
+	}
+
+	return first
+}
+
+// nextRun returns the occurrence that follows the one at last
+func (s *Schedule) nextRun(last time.Time) time.Time {
+	switch s.Frequency {
+	case ScheduleDaily:
+		return last.AddDate(0, 0, 1)
+	case ScheduleWeekly:
+		return last.AddDate(0, 0, 7)
+	case ScheduleMonthly:
+		return s.monthlyRun(last.Year(), last.Month()+1)
+	default:
+		return last.Add(time.Duration(s.IntervalSeconds) * time.Second)
+	}
+}
+
+// monthlyRun returns the occurrence of a monthly schedule in the given month, moved to the last day of
+// the month when it is shorter than DayOfMonth
+func (s *Schedule) monthlyRun(year int, month time.Month) time.Time {
+	day := s.DayOfMonth
+	if last := time.Date(year, month+1, 0, 0, 0, 0, 0, s.StartAt.Location()).Day(); day > last {
+		day = last
+	}
+
+	return time.Date(year, month, day, s.StartAt.Hour(), s.StartAt.Minute(), s.StartAt.Second(), 0, s.StartAt.Location())
+}
+
+// advance moves the schedule past the occurrence at NextRunAt, completing it when no occurrences are left
+func (s *Schedule) advance() {
+	s.Retries = 0
+	s.RetryAt = nil
+	s.NextRunAt = s.nextRun(s.NextRunAt)
+
+	if s.MaxOccurrences > 0 && s.Occurrences >= s.MaxOccurrences {
+		s.Status = ScheduleCompleted
+	}
+	if s.EndAt != nil && s.NextRunAt.After(*s.EndAt) {
+		s.Status = ScheduleCompleted
+	}
+}
+
+// dueAt returns when the schedule should run next, which is the pending retry if there is one
+func (s *Schedule) dueAt() time.Time {
+	if s.RetryAt != nil {
+		return *s.RetryAt
+	}
+	return s.NextRunAt
+}
+
+// CreateSchedule validates and stores a new standing order
+func (u *PostgresRepository) CreateSchedule(ctx context.Context, s *Schedule) (*Schedule, error) {
+	now := time.Now()
+	if err := s.validate(now); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := userAccountID(ctx, tx, s.UserIDSource, s.Currency); err != nil {
+		return nil, fmt.Errorf("source %w", err)
+	}
+	if _, err := userAccountID(ctx, tx, s.UserIDEndpoint, s.Currency); err != nil {
+		return nil, fmt.Errorf("destination %w", err)
+	}
+
+	s.Status = ScheduleActive
+	s.NextRunAt = s.firstRun()
+	s.CreatedAt = now
+	s.UpdatedAt = now
+
+	stmt := `
+        INSERT INTO schedules (user_id_source, user_id_endpoint, amount, currency, frequency, interval_seconds,
+            day_of_month, start_at, end_at, max_occurrences, on_insufficient, max_retries, retry_interval_seconds,
+            status, next_run_at, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), NULLIF($7, 0), $8, $9, NULLIF($10, 0), $11, $12, $13, $14, $15, $16, $17)
+        RETURNING id
+    `
+	err = tx.QueryRowContext(ctx, stmt, s.UserIDSource, s.UserIDEndpoint, s.Amount, s.Currency, s.Frequency,
+		s.IntervalSeconds, s.DayOfMonth, s.StartAt, s.EndAt, s.MaxOccurrences, s.OnInsufficientBalance, s.MaxRetries,
+		s.RetryIntervalSeconds, s.Status, s.NextRunAt, s.CreatedAt, s.UpdatedAt).Scan(&s.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add schedule: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return s, nil
+}
+
+// GetSchedule returns a schedule with its most recent runs
+func (u *PostgresRepository) GetSchedule(ctx context.Context, id int) (*Schedule, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	s, err := scanSchedule(db.QueryRowContext(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("schedule %d: %w", id, ErrScheduleNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+
+	query := `
+        SELECT id, schedule_id, scheduled_for, status, transaction_id, error, created_at
+        FROM schedule_runs
+        WHERE schedule_id = $1
+        ORDER BY id DESC
+        LIMIT 10
+    `
+	rows, err := db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule runs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var run ScheduleRun
+		if err := rows.Scan(&run.ID, &run.ScheduleID, &run.ScheduledFor, &run.Status, &run.TransactionID, &run.Error, &run.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule run: %w", err)
+		}
+		s.Runs = append(s.Runs, &run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get schedule runs: %w", err)
+	}
+
+	return s, nil
+}
+
+// CancelSchedule stops an active schedule; runs that already happened are kept
+func (u *PostgresRepository) CancelSchedule(ctx context.Context, id int) (*Schedule, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	stmt := `UPDATE schedules SET status = 'cancelled', retry_at = NULL, updated_at = $1 WHERE id = $2 AND status = 'active' RETURNING ` + scheduleColumns
+	s, err := scanSchedule(db.QueryRowContext(ctx, stmt, time.Now(), id))
+	if err == nil {
+		return s, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to cancel schedule: %w", err)
+	}
+
+	var status ScheduleStatus
+	err = db.QueryRowContext(ctx, `SELECT status FROM schedules WHERE id = $1`, id).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("schedule %d: %w", id, ErrScheduleNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel schedule: %w", err)
+	}
+
+	return nil, fmt.Errorf("%w: schedule %d is %s", ErrScheduleNotActive, id, status)
+}
+
+// RunDueSchedules executes every active schedule that is due at now, each in its own database
+// transaction, and returns how many runs were recorded. A schedule that fell behind runs one occurrence
+// per call. Schedules locked by another runner are left to it
+func (u *PostgresRepository) RunDueSchedules(ctx context.Context, now time.Time) (int, error) {
+	ids, err := dueScheduleIDs(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var runs int
+	var errs []error
+	for _, id := range ids {
+		ran, err := runSchedule(ctx, id, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("schedule %d: %w", id, err))
+			continue
+		}
+		if ran {
+			runs++
+		}
+	}
+
+	return runs, errors.Join(errs...)
+}
+
+// dueScheduleIDs lists the active schedules due at now, oldest first
+func dueScheduleIDs(ctx context.Context, now time.Time) ([]int, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `
+        SELECT id FROM schedules
+        WHERE status = 'active' AND COALESCE(retry_at, next_run_at) <= $1
+        ORDER BY COALESCE(retry_at, next_run_at)
+        LIMIT 100
+    `
+	rows, err := db.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get due schedules: %w", err)
+	}
+
+	return ids, nil
+}
+
+// runSchedule executes the due occurrence of a schedule through the same transfer logic as Transfer and
+// records the outcome. Rejected transfers are rolled back to a savepoint so the run can still be recorded;
+// any other error aborts the run and leaves the schedule due
+func runSchedule(ctx context.Context, id int, now time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	s, err := scanSchedule(tx.QueryRowContext(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE id = $1 FOR UPDATE SKIP LOCKED`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock schedule: %w", err)
+	}
+	if s.Status != ScheduleActive || s.dueAt().After(now) {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `SAVEPOINT schedule_run`); err != nil {
+		return false, fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	run := ScheduleRun{ScheduleID: s.ID, ScheduledFor: s.NextRunAt, CreatedAt: now}
+	transactionID, err := transfer(ctx, tx, &TransferRequest{
+		From:     s.UserIDSource,
+		To:       s.UserIDEndpoint,
+		Amount:   s.Amount,
+		Currency: s.Currency,
+	})
+	if err != nil {
+		if !isTransferRejection(err) {
+			return false, err
+		}
+		if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT schedule_run`); err != nil {
+			return false, fmt.Errorf("failed to roll back to savepoint: %w", err)
+		}
+		message := err.Error()
+		run.Error = &message
+	}
+
+	switch {
+	case err == nil:
+		run.Status = RunSucceeded
+		run.TransactionID = &transactionID
+		s.Occurrences++
+		s.advance()
+	case errors.Is(err, ErrInsufficientBalance) && s.OnInsufficientBalance == PolicyRetry && s.Retries < s.MaxRetries:
+		run.Status = RunRetrying
+		s.Retries++
+		retryAt := now.Add(time.Duration(s.RetryIntervalSeconds) * time.Second)
+		s.RetryAt = &retryAt
+	case errors.Is(err, ErrInsufficientBalance) && s.OnInsufficientBalance == PolicySkip:
+		run.Status = RunSkipped
+		s.advance()
+	default:
+		run.Status = RunFailed
+		s.advance()
+	}
+
+	stmt := `
+        UPDATE schedules SET occurrences = $1, retries = $2, status = $3, next_run_at = $4, retry_at = $5, updated_at = $6
+        WHERE id = $7
+    `
+	if _, err := tx.ExecContext(ctx, stmt, s.Occurrences, s.Retries, s.Status, s.NextRunAt, s.RetryAt, now, s.ID); err != nil {
+		return false, fmt.Errorf("failed to update schedule: %w", err)
+	}
+
+	stmt = `
+        INSERT INTO schedule_runs (schedule_id, scheduled_for, status, transaction_id, error, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `
+	if _, err := tx.ExecContext(ctx, stmt, run.ScheduleID, run.ScheduledFor, run.Status, run.TransactionID, run.Error, run.CreatedAt); err != nil {
+		return false, fmt.Errorf("failed to add schedule run: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// isTransferRejection reports whether err means the transfer was refused rather than failed
+func isTransferRejection(err error) bool {
+	for _, rejection := range []error{
+		ErrInsufficientBalance,
+		ErrUserNotFound,
+		ErrInvalidAmount,
+		ErrSameAccount,
+		ErrUnsupportedCurrency,
+		ErrInvalidScale,
+		ErrCurrencyMismatch,
+		ErrLimitExceeded,
+		ErrAccountFrozen,
+		ErrAccountClosed,
+	} {
+		if errors.Is(err, rejection) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,73 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestScheduleMonthlyRuns checks that monthly schedules keep their day and fall back to the end of short months
+func TestScheduleMonthlyRuns(t *testing.T) {
+	s := &Schedule{
+		Frequency:  ScheduleMonthly,
+		DayOfMonth: 31,
+		StartAt:    time.Date(2024, time.January, 15, 9, 30, 0, 0, time.UTC),
+	}
+
+	first := s.firstRun()
+	assert.Equal(t, time.Date(2024, time.January, 31, 9, 30, 0, 0, time.UTC), first)
+
+	second := s.nextRun(first)
+	assert.Equal(t, time.Date(2024, time.February, 29, 9, 30, 0, 0, time.UTC), second)
+	assert.Equal(t, time.Date(2024, time.March, 31, 9, 30, 0, 0, time.UTC), s.nextRun(second))
+
+	s.DayOfMonth = 10
+	assert.Equal(t, time.Date(2024, time.February, 10, 9, 30, 0, 0, time.UTC), s.firstRun())
+}
+
+// TestScheduleAdvance checks that a schedule completes after its last occurrence
+func TestScheduleAdvance(t *testing.T) {
+	start := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 14)
+	s := &Schedule{Frequency: ScheduleWeekly, StartAt: start, NextRunAt: start, EndAt: &end, Status: ScheduleActive}
+
+	s.advance()
+	assert.Equal(t, start.AddDate(0, 0, 7), s.NextRunAt)
+	assert.Equal(t, ScheduleActive, s.Status)
+
+	s.advance()
+	s.advance()
+	assert.Equal(t, ScheduleCompleted, s.Status)
+
+	s = &Schedule{Frequency: ScheduleInterval, IntervalSeconds: 90, NextRunAt: start, MaxOccurrences: 2, Occurrences: 2, Status: ScheduleActive}
+	s.advance()
+	assert.Equal(t, start.Add(90*time.Second), s.NextRunAt)
+	assert.Equal(t, ScheduleCompleted, s.Status)
+}
+
+// TestScheduleValidate checks the defaults and the rejected recurrence rules
+func TestScheduleValidate(t *testing.T) {
+	now := time.Date(2024, time.May, 17, 12, 0, 0, 0, time.UTC)
+
+	s := &Schedule{UserIDSource: 1, UserIDEndpoint: 2, Amount: decimal.NewFromInt(10), Frequency: ScheduleMonthly}
+	assert.Nil(t, s.validate(now))
+	assert.Equal(t, DefaultCurrency, s.Currency)
+	assert.Equal(t, now, s.StartAt)
+	assert.Equal(t, 17, s.DayOfMonth)
+	assert.Equal(t, PolicySkip, s.OnInsufficientBalance)
+	assert.Equal(t, int64(3600), s.RetryIntervalSeconds)
+
+	s = &Schedule{UserIDSource: 1, UserIDEndpoint: 2, Amount: decimal.NewFromInt(10), Frequency: ScheduleInterval}
+	assert.ErrorIs(t, s.validate(now), ErrInvalidSchedule)
+
+	s = &Schedule{UserIDSource: 1, UserIDEndpoint: 2, Amount: decimal.NewFromInt(10), Frequency: "hourly"}
+	assert.ErrorIs(t, s.validate(now), ErrInvalidSchedule)
+
+	s = &Schedule{UserIDSource: 1, UserIDEndpoint: 2, Amount: decimal.NewFromInt(10), Frequency: ScheduleDaily, OnInsufficientBalance: "wait"}
+	assert.ErrorIs(t, s.validate(now), ErrInvalidSchedule)
+
+	s = &Schedule{UserIDSource: 1, UserIDEndpoint: 1, Amount: decimal.NewFromInt(10), Frequency: ScheduleDaily}
+	assert.ErrorIs(t, s.validate(now), ErrSameAccount)
+}
@@ -0,0 +1,377 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"strconv"
+	"time"
+)
+
+// StatementFormat is a format a statement can be rendered in
+type StatementFormat string
+
+const (
+	StatementJSON StatementFormat = "json"
+	StatementCSV  StatementFormat = "csv"
+	StatementPDF  StatementFormat = "pdf"
+)
+
+// statementPeriodLayout is the layout of statement periods, a year and a month
+const statementPeriodLayout = "2006-01"
+
+// statementSettleDelay is how long after a month ends before its statement is persisted, so that entries
+// still being committed at midnight are included
+const statementSettleDelay = 10 * time.Minute
+
+// Statement lists everything that moved a user's wallets in one currency over a calendar month. The
+// balances are those of all the wallets together
+type Statement struct {
+	UserID         int              `json:"UserID"`
+	Currency       string           `json:"Currency"`
+	Period         string           `json:"Period"`
+	From           time.Time        `json:"From"`
+	To             time.Time        `json:"To"`
+	OpeningBalance decimal.Decimal  `json:"OpeningBalance"`
+	TotalIn        decimal.Decimal  `json:"TotalIn"`
+	TotalOut       decimal.Decimal  `json:"TotalOut"`
+	ClosingBalance decimal.Decimal  `json:"ClosingBalance"`
+	Lines          []*StatementLine `json:"Lines"`
+}
+
+// StatementLine is one movement on a wallet with the balance of all the wallets after it. Entries that
+// predate transaction records have no TransactionID or Type
+type StatementLine struct {
+	Date          time.Time        `json:"Date"`
+	EntryID       int              `json:"EntryID"`
+	WalletID      int              `json:"WalletID"`
+	TransactionID *int             `json:"TransactionID"`
+	Type          *TransactionType `json:"Type"`
+	Description   string           `json:"Description"`
+	Counterparty  *int             `json:"Counterparty"`
+	Amount        decimal.Decimal  `json:"Amount"`
+	Balance       decimal.Decimal  `json:"Balance"`
+}
+
+// StatementDocument is a rendered statement. Persisted is set once the month has closed and the document
+// was stored, after which every download returns the same bytes
+type StatementDocument struct {
+	Format    StatementFormat
+	Content   []byte
+	Persisted bool
+}
+
+// ContentType returns the MIME type of the document
+func (d *StatementDocument) ContentType() string {
+	switch d.Format {
+	case StatementCSV:
+		return "text/csv; charset=utf-8"
+	case StatementPDF:
+		return "application/pdf"
+	default:
+		return "application/json; charset=utf-8"
+	}
+}
+
+// StatementRequest asks for the statement of a user for Period, formatted as yyyy-mm
+type StatementRequest struct {
+	UserID   int
+	Currency string
+	Period   string
+	Format   StatementFormat
+}
+
+// GetStatement returns the statement of a closed or current month in the requested format. Statements of
+// closed months are generated once and then served from the statements table
+func (u *PostgresRepository) GetStatement(ctx context.Context, req *StatementRequest) (*StatementDocument, error) {
+	currency := NormalizeCurrency(req.Currency)
+	if _, err := CurrencyScale(currency); err != nil {
+		return nil, err
+	}
+	format := req.Format
+	if format == "" {
+		format = StatementJSON
+	}
+	if format != StatementJSON && format != StatementCSV && format != StatementPDF {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+
+	from, err := time.ParseInLocation(statementPeriodLayout, req.Period, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q is not yyyy-mm", ErrInvalidPeriod, req.Period)
+	}
+	to := from.AddDate(0, 1, 0)
+	now := time.Now()
+	if from.After(now) {
+		return nil, fmt.Errorf("%w: %s hasn't started yet", ErrInvalidPeriod, req.Period)
+	}
+	closed := now.Sub(to) >= statementSettleDelay
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	document := StatementDocument{Format: format, Persisted: closed}
+	if closed {
+		query := `SELECT content FROM statements WHERE user_id = $1 AND currency = $2 AND period = $3 AND format = $4`
+		err := db.QueryRowContext(ctx, query, req.UserID, currency, req.Period, format).Scan(&document.Content)
+		if err == nil {
+			return &document, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to get statement: %w", err)
+		}
+	}
+
+	statement, err := buildStatement(ctx, req.UserID, currency, req.Period, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if document.Content, err = statement.render(format); err != nil {
+		return nil, err
+	}
+	if !closed {
+		return &document, nil
+	}
+
+	// Two concurrent first downloads may both build the statement; the one stored first wins
+	stmt := `
+        INSERT INTO statements (user_id, currency, period, format, content, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (user_id, currency, period, format) DO UPDATE SET user_id = EXCLUDED.user_id
+        RETURNING content
+    `
+	stored := document.Content
+	err = db.QueryRowContext(ctx, stmt, req.UserID, currency, req.Period, format, stored, now).Scan(&document.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save statement: %w", err)
+	}
+
+	return &document, nil
+}
+
+// buildStatement reads the opening balance and the postings of every wallet the user has in currency in
+// [from, to) in a single snapshot of the database
+func buildStatement(ctx context.Context, userID int, currency, period string, from, to time.Time) (*Statement, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	statement := Statement{
+		UserID:   userID,
+		Currency: currency,
+		Period:   period,
+		From:     from,
+		To:       to,
+		Lines:    []*StatementLine{},
+	}
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("user %d: %w", userID, ErrUserNotFound)
+	}
+
+	accountIDs, err := statementAccounts(ctx, tx, userID, currency)
+	if err != nil {
+		return nil, err
+	}
+	for _, accountID := range accountIDs {
+		opening, err := balanceBefore(ctx, tx, accountID, from)
+		if err != nil {
+			return nil, err
+		}
+		statement.OpeningBalance = statement.OpeningBalance.Add(opening)
+	}
+
+	query := `
+        SELECT e.id, e.created_at, e.description, p.account_id, p.amount, t.id, t.type, t.useridsource, t.useridendpoint
+        FROM postings p
+        JOIN accounts a ON a.id = p.account_id
+        JOIN journal_entries e ON e.id = p.entry_id
+        LEFT JOIN transactions t ON t.id = e.transaction_id
+        WHERE a.user_id = $1 AND a.currency = $2 AND e.created_at >= $3 AND e.created_at < $4
+        ORDER BY e.created_at, e.id, p.id
+    `
+	rows, err := tx.QueryContext(ctx, query, userID, currency, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statement lines: %w", err)
+	}
+	defer rows.Close()
+
+	balance := statement.OpeningBalance
+	for rows.Next() {
+		var line StatementLine
+		var source, endpoint *int
+		err := rows.Scan(&line.EntryID, &line.Date, &line.Description, &line.WalletID, &line.Amount, &line.TransactionID,
+			&line.Type, &source, &endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan statement line: %w", err)
+		}
+
+		line.Counterparty = endpoint
+		if endpoint != nil && *endpoint == userID {
+			line.Counterparty = source
+		}
+
+		balance = balance.Add(line.Amount)
+		line.Balance = balance
+		if line.Amount.IsPositive() {
+			statement.TotalIn = statement.TotalIn.Add(line.Amount)
+		} else {
+			statement.TotalOut = statement.TotalOut.Add(line.Amount.Neg())
+		}
+		statement.Lines = append(statement.Lines, &line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get statement lines: %w", err)
+	}
+	statement.ClosingBalance = balance
+
+	return &statement, nil
+}
+
+// statementAccounts returns the ids of the wallets, open and closed, that a user has in currency
+func statementAccounts(ctx context.Context, tx *sql.Tx, userID int, currency string) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM accounts WHERE user_id = $1 AND currency = $2 ORDER BY id`, userID, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallets: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan wallet: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get wallets: %w", err)
+	}
+
+	return ids, nil
+}
+
+// balanceBefore returns the ledger balance of an account counting the entries created before t, starting
+// from the latest snapshot at or before t
+func balanceBefore(ctx context.Context, tx *sql.Tx, accountID int, t time.Time) (decimal.Decimal, error) {
+	var snapshot decimal.Decimal
+	var snapshotAsOf *time.Time
+
+	query := `SELECT as_of, balance FROM balance_snapshots WHERE account_id = $1 AND as_of <= $2 ORDER BY as_of DESC LIMIT 1`
+	err := tx.QueryRowContext(ctx, query, accountID, t).Scan(&snapshotAsOf, &snapshot)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return decimal.Zero, fmt.Errorf("failed to get balance snapshot: %w", err)
+	}
+
+	var delta decimal.Decimal
+	query = `
+        SELECT COALESCE(SUM(p.amount), 0)
+        FROM postings p
+        JOIN journal_entries e ON e.id = p.entry_id
+        WHERE p.account_id = $1 AND e.created_at < $2 AND ($3::timestamp IS NULL OR e.created_at >= $3)
+    `
+	if err := tx.QueryRowContext(ctx, query, accountID, t, snapshotAsOf).Scan(&delta); err != nil {
+		return decimal.Zero, fmt.Errorf("failed to sum postings: %w", err)
+	}
+
+	return snapshot.Add(delta), nil
+}
+
+// render formats the statement
+func (s *Statement) render(format StatementFormat) ([]byte, error) {
+	switch format {
+	case StatementCSV:
+		return s.csv()
+	case StatementPDF:
+		return renderPDF(s.text()), nil
+	default:
+		return json.Marshal(s)
+	}
+}
+
+// csv renders the statement as one row per line, framed by the opening balance, the totals and the
+// closing balance
+func (s *Statement) csv() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	records := [][]string{
+		{"date", "transaction_id", "type", "description", "counterparty", "amount", "balance"},
+		{s.From.Format(time.RFC3339), "", "", "Opening balance", "", "", s.OpeningBalance.String()},
+	}
+	for _, line := range s.Lines {
+		records = append(records, []string{
+			line.Date.Format(time.RFC3339),
+			optionalInt(line.TransactionID),
+			optionalType(line.Type),
+			line.Description,
+			optionalInt(line.Counterparty),
+			line.Amount.String(),
+			line.Balance.String(),
+		})
+	}
+	records = append(records,
+		[]string{s.To.Format(time.RFC3339), "", "", "Total in", "", s.TotalIn.String(), ""},
+		[]string{s.To.Format(time.RFC3339), "", "", "Total out", "", s.TotalOut.Neg().String(), ""},
+		[]string{s.To.Format(time.RFC3339), "", "", "Closing balance", "", "", s.ClosingBalance.String()},
+	)
+
+	if err := w.WriteAll(records); err != nil {
+		return nil, fmt.Errorf("failed to write statement: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// text renders the statement as fixed-width lines for the PDF
+func (s *Statement) text() []string {
+	const row = "%-19s %-8s %-10s %-24.24s %-8s %14s %14s"
+
+	lines := []string{
+		fmt.Sprintf("Statement for user %d, %s %s", s.UserID, s.Period, s.Currency),
+		fmt.Sprintf("Period %s - %s", s.From.Format(time.DateTime), s.To.Format(time.DateTime)),
+		"",
+		fmt.Sprintf("Opening balance %s", s.OpeningBalance.String()),
+		"",
+		fmt.Sprintf(row, "Date", "Tx", "Type", "Description", "Party", "Amount", "Balance"),
+	}
+	for _, line := range s.Lines {
+		lines = append(lines, fmt.Sprintf(row, line.Date.Format(time.DateTime), optionalInt(line.TransactionID),
+			optionalType(line.Type), line.Description, optionalInt(line.Counterparty), line.Amount.String(), line.Balance.String()))
+	}
+	lines = append(lines,
+		"",
+		fmt.Sprintf("Total in        %s", s.TotalIn.String()),
+		fmt.Sprintf("Total out       %s", s.TotalOut.String()),
+		fmt.Sprintf("Closing balance %s", s.ClosingBalance.String()),
+	)
+
+	return lines
+}
+
+// optionalInt formats a nullable id, empty when it is nil
+func optionalInt(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
+
+// optionalType formats a nullable transaction type, empty when it is nil
+func optionalType(t *TransactionType) string {
+	if t == nil {
+		return ""
+	}
+	return string(*t)
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStatement is a statement with a deposit, an outgoing transfer and an entry without a transaction
+func testStatement() *Statement {
+	deposit, transfer := TransactionDeposit, TransactionTransfer
+	from := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	return &Statement{
+		UserID:         1,
+		Currency:       "RUB",
+		Period:         "2024-05",
+		From:           from,
+		To:             from.AddDate(0, 1, 0),
+		OpeningBalance: decimal.NewFromInt(100),
+		TotalIn:        decimal.NewFromInt(50),
+		TotalOut:       decimal.NewFromInt(30),
+		ClosingBalance: decimal.NewFromInt(120),
+		Lines: []*StatementLine{
+			{Date: from.Add(time.Hour), EntryID: 10, TransactionID: intPtr(5), Type: &deposit, Description: "deposit", Amount: decimal.NewFromInt(50), Balance: decimal.NewFromInt(150)},
+			{Date: from.Add(2 * time.Hour), EntryID: 11, TransactionID: intPtr(6), Type: &transfer, Description: "transfer", Counterparty: intPtr(2), Amount: decimal.NewFromInt(-30), Balance: decimal.NewFromInt(120)},
+			{Date: from.Add(3 * time.Hour), EntryID: 12, Description: "correction, (manual)", Amount: decimal.NewFromInt(0), Balance: decimal.NewFromInt(120)},
+		},
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// TestStatementCSV checks the framing rows and that descriptions with commas are quoted
+func TestStatementCSV(t *testing.T) {
+	out, err := testStatement().render(StatementCSV)
+	assert.Nil(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	assert.Len(t, lines, 8)
+	assert.Equal(t, "date,transaction_id,type,description,counterparty,amount,balance", lines[0])
+	assert.Equal(t, "2024-05-01T00:00:00Z,,,Opening balance,,,100", lines[1])
+	assert.Equal(t, "2024-05-01T02:00:00Z,6,transfer,transfer,2,-30,120", lines[3])
+	assert.Equal(t, `2024-05-01T03:00:00Z,,,"correction, (manual)",,0,120`, lines[4])
+	assert.Equal(t, "2024-06-01T00:00:00Z,,,Closing balance,,,120", lines[7])
+}
+
+// TestStatementPDF checks that the PDF is well formed, escapes parentheses and is byte-identical across renders
+func TestStatementPDF(t *testing.T) {
+	first, err := testStatement().render(StatementPDF)
+	assert.Nil(t, err)
+	second, err := testStatement().render(StatementPDF)
+	assert.Nil(t, err)
+
+	assert.True(t, bytes.Equal(first, second))
+	assert.True(t, bytes.HasPrefix(first, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(first, []byte("%%EOF\n")))
+	assert.Contains(t, string(first), `correction, \(manual\)`)
+	assert.Contains(t, string(first), "/Count 1")
+}
+
+// TestRenderPDFPages checks that long statements are split across pages
+func TestRenderPDFPages(t *testing.T) {
+	lines := make([]string, pdfLinesPerPage*2+1)
+	for i := range lines {
+		lines[i] = "line"
+	}
+
+	out := string(renderPDF(lines))
+	assert.Contains(t, out, "/Count 3")
+	assert.Contains(t, out, "/Kids [4 0 R 6 0 R 8 0 R]")
+}
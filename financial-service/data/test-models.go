@@ -1,8 +1,10 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"github.com/shopspring/decimal"
+	"time"
 )
 
 type PostgresTestRepository struct {
@@ -27,6 +29,216 @@ func (u *PostgresTestRepository) GetLastTransactions(id int) ([]*Transactions, e
 	return nil, nil
 }
 
-func (u *PostgresTestRepository) AddTransaction(amount decimal.Decimal, id ...int) error {
+func (u *PostgresTestRepository) AddTransaction(ctx context.Context, t *Transactions) error {
 	return nil
 }
+
+func (u *PostgresTestRepository) Withdraw(ctx context.Context, req *WithdrawalRequest) (int, error) {
+	return 1, nil
+}
+
+func (u *PostgresTestRepository) Transfer(ctx context.Context, req *TransferRequest) (int, error) {
+	return 1, nil
+}
+
+func (u *PostgresTestRepository) Deposit(ctx context.Context, req *DepositRequest) (int, error) {
+	return 1, nil
+}
+
+func (u *PostgresTestRepository) PostEntry(ctx context.Context, entry *JournalEntry) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) VerifyBalance(ctx context.Context, userID int) ([]*BalanceCheck, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (u *PostgresTestRepository) SetRates(ctx context.Context, rates []*FXRate) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) CreateQuote(ctx context.Context, req *QuoteRequest) (*FXQuote, error) {
+	return &FXQuote{From: req.From, To: req.To, Amount: req.Amount, Spread: req.Spread}, nil
+}
+
+func (u *PostgresTestRepository) Refund(ctx context.Context, req *RefundRequest) (*Transactions, error) {
+	return &Transactions{Type: TransactionRefund, ParentID: &req.TransactionID, Amount: req.Amount}, nil
+}
+
+func (u *PostgresTestRepository) AuthorizeHold(ctx context.Context, req *HoldRequest) (*Hold, error) {
+	return &Hold{UserID: req.UserID, DestinationID: req.DestinationID, Amount: req.Amount, Currency: req.Currency, Status: HoldAuthorized}, nil
+}
+
+func (u *PostgresTestRepository) CaptureHold(ctx context.Context, id int, amount decimal.Decimal) (*Hold, error) {
+	return &Hold{ID: id, Status: HoldCaptured, CapturedAmount: decimal.NewNullDecimal(amount)}, nil
+}
+
+func (u *PostgresTestRepository) VoidHold(ctx context.Context, id int) (*Hold, error) {
+	return &Hold{ID: id, Status: HoldVoided}, nil
+}
+
+func (u *PostgresTestRepository) ExpireHolds(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (u *PostgresTestRepository) CreateSchedule(ctx context.Context, s *Schedule) (*Schedule, error) {
+	return s, nil
+}
+
+func (u *PostgresTestRepository) GetSchedule(ctx context.Context, id int) (*Schedule, error) {
+	return &Schedule{ID: id, Status: ScheduleActive}, nil
+}
+
+func (u *PostgresTestRepository) CancelSchedule(ctx context.Context, id int) (*Schedule, error) {
+	return &Schedule{ID: id, Status: ScheduleCancelled}, nil
+}
+
+func (u *PostgresTestRepository) RunDueSchedules(ctx context.Context, now time.Time) (int, error) {
+	return 0, nil
+}
+
+func (u *PostgresTestRepository) QuoteFee(ctx context.Context, req *FeeRequest) (*FeeQuote, error) {
+	return &FeeQuote{Type: req.Type, Amount: req.Amount, Currency: req.Currency, Fee: decimal.Zero, Total: req.Amount}, nil
+}
+
+func (u *PostgresTestRepository) CreateFeeRule(ctx context.Context, r *FeeRule) (*FeeRule, error) {
+	return r, nil
+}
+
+func (u *PostgresTestRepository) ListFeeRules(ctx context.Context) ([]*FeeRule, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) DeactivateFeeRule(ctx context.Context, id int) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) SetOverdraftLimit(ctx context.Context, userID int, currency string, limit decimal.Decimal) (*Balance, error) {
+	return &Balance{UserID: userID, Currency: currency, OverdraftLimit: limit, Available: limit}, nil
+}
+
+func (u *PostgresTestRepository) ListOverdrafts(ctx context.Context) ([]*Overdraft, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) SetSpendingLimit(ctx context.Context, l *SpendingLimit) (*SpendingLimit, error) {
+	return l, nil
+}
+
+func (u *PostgresTestRepository) BalanceAsOf(ctx context.Context, userID int, currency string, asOf time.Time) (*HistoricalBalance, error) {
+	return &HistoricalBalance{UserID: userID, Currency: currency, AsOf: asOf}, nil
+}
+
+func (u *PostgresTestRepository) SnapshotBalances(ctx context.Context, asOf time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (u *PostgresTestRepository) GetStatement(ctx context.Context, req *StatementRequest) (*StatementDocument, error) {
+	return &StatementDocument{Format: req.Format, Content: []byte("{}")}, nil
+}
+
+func (u *PostgresTestRepository) Reconcile(ctx context.Context, opts *ReconcileOptions) (*ReconciliationReport, error) {
+	return &ReconciliationReport{Repair: opts.Repair, Mismatches: []*BalanceMismatch{}}, nil
+}
+
+func (u *PostgresTestRepository) CreatePayoutBatch(ctx context.Context, batch *PayoutBatch) (*PayoutBatch, error) {
+	batch.Status = PayoutPending
+	batch.ItemCount = len(batch.Items)
+	return batch, nil
+}
+
+func (u *PostgresTestRepository) GetPayoutBatch(ctx context.Context, id int) (*PayoutBatch, error) {
+	return &PayoutBatch{ID: id, Status: PayoutCompleted, Items: []*PayoutItem{}}, nil
+}
+
+func (u *PostgresTestRepository) ProcessPayoutBatch(ctx context.Context, id int) (*PayoutBatch, error) {
+	return &PayoutBatch{ID: id, Status: PayoutCompleted, Items: []*PayoutItem{}}, nil
+}
+
+func (u *PostgresTestRepository) ProcessPendingPayouts(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (u *PostgresTestRepository) CreateWallet(ctx context.Context, w *Wallet) (*Wallet, error) {
+	w.Status = WalletOpen
+	return w, nil
+}
+
+func (u *PostgresTestRepository) ListWallets(ctx context.Context, userID int) ([]*Wallet, error) {
+	return []*Wallet{}, nil
+}
+
+func (u *PostgresTestRepository) GetWallet(ctx context.Context, id int) (*Wallet, error) {
+	return &Wallet{ID: id, UserID: 1, Status: WalletOpen}, nil
+}
+
+func (u *PostgresTestRepository) RenameWallet(ctx context.Context, id int, name string) (*Wallet, error) {
+	return &Wallet{ID: id, Name: name, Status: WalletOpen}, nil
+}
+
+func (u *PostgresTestRepository) CloseWallet(ctx context.Context, id int) (*Wallet, error) {
+	return &Wallet{ID: id, Status: WalletClosed}, nil
+}
+
+func (u *PostgresTestRepository) GetWalletTransactions(walletID int) ([]*Transactions, error) {
+	return nil, nil
+}
+
+func (u *PostgresTestRepository) SetAccountStatus(ctx context.Context, userID int, status AccountStatus, reason string) (*AccountStatusChange, error) {
+	return &AccountStatusChange{UserID: userID, From: AccountActive, To: status, Reason: reason}, nil
+}
+
+func (u *PostgresTestRepository) CloseAccount(ctx context.Context, req *CloseAccountRequest) (*AccountStatusChange, error) {
+	return &AccountStatusChange{UserID: req.UserID, From: AccountActive, To: AccountClosed, Reason: req.Reason}, nil
+}
+
+func (u *PostgresTestRepository) CreateUser(ctx context.Context, user *User) (*User, error) {
+	user.ID = 1
+	user.Status = AccountActive
+	return user, nil
+}
+
+func (u *PostgresTestRepository) GetUser(ctx context.Context, id int) (*User, error) {
+	return &User{ID: id, Status: AccountActive}, nil
+}
+
+func (u *PostgresTestRepository) ListUsers(ctx context.Context, afterID, limit int) ([]*User, error) {
+	return []*User{}, nil
+}
+
+func (u *PostgresTestRepository) UpdateUser(ctx context.Context, id int, update *UserUpdate) (*User, error) {
+	return &User{ID: id, Status: AccountActive}, nil
+}
+
+func (u *PostgresTestRepository) DeleteUser(ctx context.Context, id int) error {
+	return nil
+}
+
+func (u *PostgresTestRepository) GetTransactionHistory(ctx context.Context, req *HistoryRequest) (*TransactionPage, error) {
+	return &TransactionPage{Transactions: []*Transactions{}}, nil
+}
+
+func (u *PostgresTestRepository) GetTransaction(ctx context.Context, id int) (*TransactionRecord, error) {
+	endpoint := 123
+	return &TransactionRecord{
+		Transactions: &Transactions{ID: id, Type: TransactionDeposit, UserIDEndpoint: &endpoint, Amount: decimal.NewFromInt(100), Currency: DefaultCurrency},
+		Status:       TransactionCompleted,
+		Linked:       []*Transactions{},
+	}, nil
+}
+
+func (u *PostgresTestRepository) GetBalance(ctx context.Context, userID int, currency string) (*Balance, error) {
+	return &Balance{UserID: userID, Currency: NormalizeCurrency(currency)}, nil
+}
+
+func (u *PostgresTestRepository) GetBalances(ctx context.Context, userIDs []int, currency string) ([]*Balance, error) {
+	balances := make([]*Balance, len(userIDs))
+	for i, id := range userIDs {
+		balances[i] = &Balance{UserID: id, Currency: NormalizeCurrency(currency)}
+	}
+	return balances, nil
+}
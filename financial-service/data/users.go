@@ -0,0 +1,276 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const (
+	maxUserNameLength = 100
+	maxEmailLength    = 254
+)
+
+// User is a customer of the service. Balance mirrors the user's default DefaultCurrency wallet. Users
+// added before the users API existed may have no name or email
+type User struct {
+	ID        int             `json:"ID"`
+	Name      string          `json:"Name"`
+	Email     string          `json:"Email"`
+	Status    AccountStatus   `json:"Status"`
+	Balance   decimal.Decimal `json:"Balance"`
+	CreatedAt time.Time       `json:"CreatedAt"`
+	UpdatedAt time.Time       `json:"UpdatedAt"`
+}
+
+// UserUpdate changes the fields of a user that are set
+type UserUpdate struct {
+	Name  *string
+	Email *string
+}
+
+// userColumns are the columns scanned by scanUser
+const userColumns = `id, COALESCE(name, ''), COALESCE(email, ''), status, balance, created_at, updated_at`
+
+// scanUser reads a row selected with userColumns
+func scanUser(row rowScanner) (*User, error) {
+	var user User
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Status, &user.Balance, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// normalizeUserName trims a user name and checks its length
+func normalizeUserName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidUser)
+	}
+	if len(name) > maxUserNameLength {
+		return "", fmt.Errorf("%w: name is longer than %d characters", ErrInvalidUser, maxUserNameLength)
+	}
+
+	return name, nil
+}
+
+// normalizeEmail checks that email is a bare address and lowercases it
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", fmt.Errorf("%w: email is required", ErrInvalidUser)
+	}
+	if len(email) > maxEmailLength {
+		return "", fmt.Errorf("%w: email is longer than %d characters", ErrInvalidUser, maxEmailLength)
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", fmt.Errorf("%w: %q is not a valid email address", ErrInvalidUser, email)
+	}
+
+	return strings.ToLower(email), nil
+}
+
+// CreateUser adds a user with the given name and email
+func (u *PostgresRepository) CreateUser(ctx context.Context, user *User) (*User, error) {
+	name, err := normalizeUserName(user.Name)
+	if err != nil {
+		return nil, err
+	}
+	email, err := normalizeEmail(user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkEmailFree(ctx, tx, email, 0); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	stmt := `INSERT INTO users (name, email, created_at, updated_at) VALUES ($1, $2, $3, $3) RETURNING ` + userColumns
+	created, err := scanUser(tx.QueryRowContext(ctx, stmt, name, email, now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to add user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return created, nil
+}
+
+// GetUser returns a user that hasn't been deleted
+func (u *PostgresRepository) GetUser(ctx context.Context, id int) (*User, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	user, err := scanUser(db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1 AND deleted_at IS NULL`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("user %d: %w", id, ErrUserNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// ListUsers returns up to limit users that haven't been deleted, ordered by id and starting after afterID
+func (u *PostgresRepository) ListUsers(ctx context.Context, afterID, limit int) ([]*User, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE deleted_at IS NULL AND id > $1 ORDER BY id LIMIT $2`
+	rows, err := db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return users, nil
+}
+
+// UpdateUser changes the name or email of a user
+func (u *PostgresRepository) UpdateUser(ctx context.Context, id int, update *UserUpdate) (*User, error) {
+	var name, email sql.NullString
+	if update.Name != nil {
+		normalized, err := normalizeUserName(*update.Name)
+		if err != nil {
+			return nil, err
+		}
+		name = sql.NullString{String: normalized, Valid: true}
+	}
+	if update.Email != nil {
+		normalized, err := normalizeEmail(*update.Email)
+		if err != nil {
+			return nil, err
+		}
+		email = sql.NullString{String: normalized, Valid: true}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockAccountStatus(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	if email.Valid {
+		if err := checkEmailFree(ctx, tx, email.String, id); err != nil {
+			return nil, err
+		}
+	}
+
+	stmt := `
+        UPDATE users SET name = COALESCE($1, name), email = COALESCE($2, email), updated_at = $3
+        WHERE id = $4
+        RETURNING ` + userColumns
+	user, err := scanUser(tx.QueryRowContext(ctx, stmt, name, email, time.Now(), id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return user, nil
+}
+
+// DeleteUser soft-deletes a user. Only users that never moved money and hold none can be deleted; the
+// rest have to be closed instead so that their history stays attached to them
+func (u *PostgresRepository) DeleteUser(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var deleted bool
+	err = tx.QueryRowContext(ctx, `SELECT deleted_at IS NOT NULL FROM users WHERE id = $1 FOR UPDATE`, id).Scan(&deleted)
+	if errors.Is(err, sql.ErrNoRows) || deleted {
+		return fmt.Errorf("user %d: %w", id, ErrUserNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	var funded, history bool
+	query := `
+        SELECT
+            EXISTS (SELECT 1 FROM accounts WHERE user_id = $1 AND (balance <> 0 OR held <> 0)),
+            EXISTS (SELECT 1 FROM transactions WHERE useridsource = $1 OR useridendpoint = $1)
+    `
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&funded, &history); err != nil {
+		return fmt.Errorf("failed to check user: %w", err)
+	}
+	if funded {
+		return fmt.Errorf("%w: user %d has a non-zero balance", ErrUserNotDeletable, id)
+	}
+	if history {
+		return fmt.Errorf("%w: user %d has transaction history", ErrUserNotDeletable, id)
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET deleted_at = $1, updated_at = $1 WHERE id = $2`, now, id); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// checkEmailFree fails when a user other than userID that hasn't been deleted already uses email
+func checkEmailFree(ctx context.Context, tx *sql.Tx, email string, userID int) error {
+	var taken bool
+	query := `SELECT EXISTS (SELECT 1 FROM users WHERE lower(email) = $1 AND id <> $2 AND deleted_at IS NULL)`
+	if err := tx.QueryRowContext(ctx, query, email, userID).Scan(&taken); err != nil {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+	if taken {
+		return fmt.Errorf("%w: %s", ErrEmailTaken, email)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNormalizeEmail checks that emails are bare, lowercased addresses
+func TestNormalizeEmail(t *testing.T) {
+	email, err := normalizeEmail(" Anna@Example.com ")
+	assert.NoError(t, err)
+	assert.Equal(t, "anna@example.com", email)
+
+	for _, invalid := range []string{"", "anna", "Anna <anna@example.com>", "anna@", strings.Repeat("a", maxEmailLength) + "@example.com"} {
+		_, err := normalizeEmail(invalid)
+		assert.ErrorIs(t, err, ErrInvalidUser, invalid)
+	}
+}
+
+// TestNormalizeUserName checks that user names are trimmed and bounded
+func TestNormalizeUserName(t *testing.T) {
+	name, err := normalizeUserName("  Anna ")
+	assert.NoError(t, err)
+	assert.Equal(t, "Anna", name)
+
+	_, err = normalizeUserName(" ")
+	assert.ErrorIs(t, err, ErrInvalidUser)
+
+	_, err = normalizeUserName(strings.Repeat("x", maxUserNameLength+1))
+	assert.ErrorIs(t, err, ErrInvalidUser)
+}
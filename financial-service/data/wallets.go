@@ -0,0 +1,372 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WalletStatus is the lifecycle state of a wallet
+type WalletStatus string
+
+const (
+	WalletOpen   WalletStatus = "open"
+	WalletClosed WalletStatus = "closed"
+)
+
+// DefaultWalletName is the name of the wallet opened for a user on their first movement in a currency
+const DefaultWalletName = "main"
+
+const maxWalletNameLength = 64
+
+// Wallet is a named ledger account of a user. A user may hold several wallets per currency; the default
+// one is used by operations that don't name a wallet and can't be closed
+type Wallet struct {
+	ID        int          `json:"ID"`
+	UserID    int          `json:"UserID"`
+	Name      string       `json:"Name"`
+	Currency  string       `json:"Currency"`
+	Default   bool         `json:"Default"`
+	Status    WalletStatus `json:"Status"`
+	CreatedAt time.Time    `json:"CreatedAt"`
+	ClosedAt  *time.Time   `json:"ClosedAt"`
+	Balance   *Balance     `json:"Balance,omitempty"`
+}
+
+// walletColumns are the columns scanned by scanWallet
+const walletColumns = `
+    a.id, a.user_id, a.name, a.currency, a.is_default, a.status, a.created_at, a.closed_at,
+    a.balance, a.held, a.overdraft_limit, a.updated_at
+`
+
+// scanWallet reads a row selected with walletColumns
+func scanWallet(row rowScanner) (*Wallet, error) {
+	var w Wallet
+	var b Balance
+	err := row.Scan(&w.ID, &w.UserID, &w.Name, &w.Currency, &w.Default, &w.Status, &w.CreatedAt, &w.ClosedAt,
+		&b.Ledger, &b.Held, &b.OverdraftLimit, &b.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	b.UserID, b.Currency = w.UserID, w.Currency
+	b.Available = b.Ledger.Sub(b.Held).Add(b.OverdraftLimit)
+	w.Balance = &b
+
+	return &w, nil
+}
+
+// normalizeWalletName trims a wallet name and checks its length
+func normalizeWalletName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidWallet)
+	}
+	if len(name) > maxWalletNameLength {
+		return "", fmt.Errorf("%w: name is longer than %d characters", ErrInvalidWallet, maxWalletNameLength)
+	}
+
+	return name, nil
+}
+
+// CreateWallet opens a named wallet for a user. The first wallet a user has in a currency becomes its default
+func (u *PostgresRepository) CreateWallet(ctx context.Context, w *Wallet) (*Wallet, error) {
+	name, err := normalizeWalletName(w.Name)
+	if err != nil {
+		return nil, err
+	}
+	currency := NormalizeCurrency(w.Currency)
+	if _, err := CurrencyScale(currency); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locking the user serializes wallet creation, so two first wallets can't both become the default
+	if _, err := lockAccountStatus(ctx, tx, w.UserID); err != nil {
+		return nil, err
+	}
+	userID := w.UserID
+
+	var taken bool
+	query := `SELECT EXISTS (SELECT 1 FROM accounts WHERE user_id = $1 AND currency = $2 AND lower(name) = lower($3))`
+	if err := tx.QueryRowContext(ctx, query, userID, currency, name).Scan(&taken); err != nil {
+		return nil, fmt.Errorf("failed to check wallet name: %w", err)
+	}
+	if taken {
+		return nil, fmt.Errorf("%w: %q in %s", ErrWalletNameTaken, name, currency)
+	}
+
+	var id int
+	stmt := `
+        INSERT INTO accounts (user_id, currency, name, is_default)
+        VALUES ($1, $2, $3, NOT EXISTS (SELECT 1 FROM accounts WHERE user_id = $1 AND currency = $2 AND is_default))
+        RETURNING id
+    `
+	if err := tx.QueryRowContext(ctx, stmt, userID, currency, name).Scan(&id); err != nil {
+		return nil, fmt.Errorf("failed to open wallet: %w", err)
+	}
+
+	wallet, err := scanWallet(tx.QueryRowContext(ctx, `SELECT `+walletColumns+` FROM accounts a WHERE a.id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return wallet, nil
+}
+
+// ListWallets returns the wallets of a user, open and closed, with their balances
+func (u *PostgresRepository) ListWallets(ctx context.Context, userID int) ([]*Wallet, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)`, userID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to list wallets: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("user %d: %w", userID, ErrUserNotFound)
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT `+walletColumns+` FROM accounts a WHERE a.user_id = $1 ORDER BY a.id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list wallets: %w", err)
+	}
+	defer rows.Close()
+
+	wallets := []*Wallet{}
+	for rows.Next() {
+		wallet, err := scanWallet(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan wallet: %w", err)
+		}
+		wallets = append(wallets, wallet)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list wallets: %w", err)
+	}
+
+	return wallets, nil
+}
+
+// GetWallet returns a wallet with its balance
+func (u *PostgresRepository) GetWallet(ctx context.Context, id int) (*Wallet, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	query := `SELECT ` + walletColumns + ` FROM accounts a WHERE a.id = $1 AND a.user_id IS NOT NULL`
+	wallet, err := scanWallet(db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("wallet %d: %w", id, ErrWalletNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet: %w", err)
+	}
+
+	return wallet, nil
+}
+
+// RenameWallet changes the name of a wallet. Names are unique per user and currency, ignoring case
+func (u *PostgresRepository) RenameWallet(ctx context.Context, id int, name string) (*Wallet, error) {
+	name, err := normalizeWalletName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	wallet, err := lockWallet(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var taken bool
+	query := `SELECT EXISTS (SELECT 1 FROM accounts WHERE user_id = $1 AND currency = $2 AND lower(name) = lower($3) AND id <> $4)`
+	if err := tx.QueryRowContext(ctx, query, wallet.UserID, wallet.Currency, name, wallet.ID).Scan(&taken); err != nil {
+		return nil, fmt.Errorf("failed to check wallet name: %w", err)
+	}
+	if taken {
+		return nil, fmt.Errorf("%w: %q in %s", ErrWalletNameTaken, name, wallet.Currency)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE accounts SET name = $1 WHERE id = $2`, name, wallet.ID); err != nil {
+		return nil, fmt.Errorf("failed to rename wallet: %w", err)
+	}
+	wallet.Name = name
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return wallet, nil
+}
+
+// CloseWallet closes an empty wallet so that it can't be used any more. Default wallets can't be closed,
+// and a wallet with a balance or active holds has to be emptied first
+func (u *PostgresRepository) CloseWallet(ctx context.Context, id int) (*Wallet, error) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	wallet, err := lockWallet(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case wallet.Status == WalletClosed:
+		return nil, fmt.Errorf("wallet %d: %w", wallet.ID, ErrWalletClosed)
+	case wallet.Default:
+		return nil, fmt.Errorf("%w: wallet %d is the default %s wallet", ErrWalletNotClosable, wallet.ID, wallet.Currency)
+	case !wallet.Balance.Ledger.IsZero() || !wallet.Balance.Held.IsZero():
+		return nil, fmt.Errorf("%w: wallet %d holds %s %s", ErrWalletNotClosable, wallet.ID, wallet.Balance.Ledger.String(), wallet.Currency)
+	}
+
+	now := time.Now()
+	wallet.Status, wallet.ClosedAt = WalletClosed, &now
+	if _, err := tx.ExecContext(ctx, `UPDATE accounts SET status = $1, closed_at = $2 WHERE id = $3`, wallet.Status, now, wallet.ID); err != nil {
+		return nil, fmt.Errorf("failed to close wallet: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return wallet, nil
+}
+
+// GetWalletTransactions returns the last 10 transactions that moved money in or out of a wallet, newest first
+func (u *PostgresRepository) GetWalletTransactions(walletID int) ([]*Transactions, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM accounts WHERE id = $1 AND user_id IS NOT NULL)`
+	if err := db.QueryRowContext(ctx, query, walletID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("wallet %d: %w", walletID, ErrWalletNotFound)
+	}
+
+	query = `
+        SELECT ` + transactionColumns + `
+        FROM transactions
+        WHERE source_wallet_id = $1 OR destination_wallet_id = $1
+        ORDER BY createdat DESC
+        LIMIT 10
+    `
+	rows, err := db.QueryContext(ctx, query, walletID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []*Transactions
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		transactions = append(transactions, transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+
+	return transactions, nil
+}
+
+// lockWallet locks a user account inside tx and returns it as a wallet
+func lockWallet(ctx context.Context, tx *sql.Tx, id int) (*Wallet, error) {
+	query := `SELECT ` + walletColumns + ` FROM accounts a WHERE a.id = $1 AND a.user_id IS NOT NULL FOR UPDATE`
+	wallet, err := scanWallet(tx.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("wallet %d: %w", id, ErrWalletNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock wallet: %w", err)
+	}
+
+	return wallet, nil
+}
+
+// checkWalletsOpen fails when one of the accounts is a closed wallet. walletAccountID reads the status
+// before the account is locked, so movements check again once lockAccounts holds the lock and a wallet
+// closed in between can't be credited
+func checkWalletsOpen(ctx context.Context, tx *sql.Tx, ids ...int) error {
+	for _, id := range ids {
+		var status WalletStatus
+		if err := tx.QueryRowContext(ctx, `SELECT status FROM accounts WHERE id = $1`, id).Scan(&status); err != nil {
+			return fmt.Errorf("failed to get wallet status: %w", err)
+		}
+		if status != WalletOpen {
+			return fmt.Errorf("wallet %d: %w", id, ErrWalletClosed)
+		}
+	}
+
+	return nil
+}
+
+// walletAccountID resolves the account a movement debits or credits: the wallet walletID when it is set,
+// otherwise the default wallet of *userID in currency. An empty currency stands for the wallet's own
+// currency, or DefaultCurrency without a wallet. A wallet has to be open, hold currency and belong to
+// *userID unless that is zero; *userID is set to its owner. It returns the account id and its currency
+func walletAccountID(ctx context.Context, tx *sql.Tx, userID *int, walletID int, currency string) (int, string, error) {
+	if walletID == 0 {
+		currency = NormalizeCurrency(currency)
+		if _, err := CurrencyScale(currency); err != nil {
+			return 0, "", err
+		}
+		accountID, err := userAccountID(ctx, tx, *userID, currency)
+		return accountID, currency, err
+	}
+
+	var owner int
+	var walletCurrency string
+	var status WalletStatus
+	query := `SELECT user_id, currency, status FROM accounts WHERE id = $1 AND user_id IS NOT NULL`
+	err := tx.QueryRowContext(ctx, query, walletID).Scan(&owner, &walletCurrency, &status)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && *userID != 0 && *userID != owner) {
+		return 0, "", fmt.Errorf("wallet %d: %w", walletID, ErrWalletNotFound)
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get wallet: %w", err)
+	}
+	if status != WalletOpen {
+		return 0, "", fmt.Errorf("wallet %d: %w", walletID, ErrWalletClosed)
+	}
+	if currency != "" && NormalizeCurrency(currency) != walletCurrency {
+		return 0, "", fmt.Errorf("%w: wallet %d holds %s, not %s", ErrCurrencyMismatch, walletID, walletCurrency, NormalizeCurrency(currency))
+	}
+
+	*userID = owner
+
+	return walletID, walletCurrency, nil
+}
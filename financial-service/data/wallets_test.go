@@ -0,0 +1,21 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNormalizeWalletName checks that wallet names are trimmed and bounded
+func TestNormalizeWalletName(t *testing.T) {
+	name, err := normalizeWalletName("  savings ")
+	assert.NoError(t, err)
+	assert.Equal(t, "savings", name)
+
+	_, err = normalizeWalletName("   ")
+	assert.ErrorIs(t, err, ErrInvalidWallet)
+
+	_, err = normalizeWalletName(strings.Repeat("x", maxWalletNameLength+1))
+	assert.ErrorIs(t, err, ErrInvalidWallet)
+}